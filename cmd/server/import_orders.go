@@ -0,0 +1,94 @@
+/*
+Copyright © 2014–6 Brad Ackerman.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package main
+
+import (
+	"os"
+	"time"
+
+	"github.com/backerman/evego/pkg/dbaccess"
+	"github.com/backerman/evego/pkg/eveapi"
+	"github.com/backerman/eveindy/pkg/db"
+	"github.com/backerman/eveindy/pkg/orderbook"
+	"github.com/backerman/eveindy/pkg/server"
+	"github.com/backerman/eveindy/pkg/xmlapi"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func importOrdersCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "import-orders file...",
+		Short: "Import order-book snapshots (CSV or JSON) into the local market",
+	}
+	format := cmd.Flags().String("format", "",
+		"The format of the snapshot files (csv or json); guessed from the file extension if not set.")
+	cmd.Run = func(cmd *cobra.Command, args []string) {
+		importOrders(*format, args)
+	}
+	return cmd
+}
+
+func importOrders(format string, args []string) {
+	err := viper.Unmarshal(&c)
+	if err != nil {
+		log.Fatalf("Unable to marshal configuration: %v", err)
+	}
+	if !viper.IsSet("dbpath") {
+		log.Fatalf("Please set the dbpath configuration option or EVEINDY_DBPATH " +
+			"environment variable to the database's path.")
+	}
+	if len(args) == 0 {
+		log.Fatalf("Please specify one or more snapshot files to import.")
+	}
+
+	sde := dbaccess.SQLDatabase(c.DbDriver, c.DbPath)
+	myCache := server.InMemCache()
+	xmlAPI := eveapi.XML(c.XMLAPIEndpoint, sde, myCache)
+	charAPI := xmlapi.XML(c.XMLAPIEndpoint, myCache)
+	localdb, err := db.Interface(c.DbDriver, c.DbPath, xmlAPI, charAPI)
+	if err != nil {
+		log.Fatalf("Unable to connect to local database: %v", err)
+	}
+
+	for _, filename := range args {
+		fileFormat := format
+		if fileFormat == "" {
+			fileFormat, err = orderbook.FormatForFile(filename)
+			if err != nil {
+				log.Fatalf("%v; please specify --format.", err)
+			}
+		}
+		file, err := os.Open(filename)
+		if err != nil {
+			log.Fatalf("Unable to open %v: %v", filename, err)
+		}
+		orders, err := orderbook.ReadSnapshot(file, fileFormat, sde)
+		file.Close()
+		if err != nil {
+			log.Fatalf("Unable to read %v: %v", filename, err)
+		}
+		err = localdb.ImportMarketSnapshot(orders, time.Now(), filename)
+		if err != nil {
+			log.Fatalf("Unable to import %v: %v", filename, err)
+		}
+		log.Printf("Imported %d orders from %v", len(orders), filename)
+	}
+}
@@ -48,6 +48,48 @@ func main() {
 	// Routing
 	// Router: either "evecentral" or "sql".
 	viper.SetDefault("Router", "evecentral")
+	// Market data
+	// Market: either "local" (order-book snapshots imported with the
+	// import-orders command or the /market/snapshot endpoint) or "evecentral".
+	viper.SetDefault("Market", "local")
+	// HistoryItems and HistoryStations: the items whose prices are recorded
+	// daily, and where.
+	viper.SetDefault("HistoryItems", []string{"Tritanium", "Pyerite", "Mexallon",
+		"Isogen", "Nocxium", "Zydrine", "Megacyte", "Morphite"})
+	viper.SetDefault("HistoryStations", []int{60003760}) // Jita 4-4
+	// Tickers: named lists of items whose prices are shown together at a
+	// station, served by /market/ticker/:name.
+	viper.SetDefault("Tickers", map[string]interface{}{
+		"minerals": map[string]interface{}{
+			"station": 60003760,
+			"items": []string{"Tritanium", "Pyerite", "Mexallon", "Isogen",
+				"Nocxium", "Zydrine", "Megacyte", "Morphite"},
+		},
+		"ice": map[string]interface{}{
+			"station": 60003760,
+			"items": []string{"Heavy Water", "Liquid Ozone", "Strontium Clathrates",
+				"Helium Isotopes", "Hydrogen Isotopes", "Nitrogen Isotopes",
+				"Oxygen Isotopes"},
+		},
+		"moon": map[string]interface{}{
+			"station": 60003760,
+			"items": []string{"Atmospheric Gases", "Evaporite Deposits",
+				"Hydrocarbons", "Silicates", "Cobalt", "Scandium", "Titanium",
+				"Tungsten", "Cadmium", "Chromium", "Platinum", "Vanadium", "Caesium",
+				"Hafnium", "Mercury", "Technetium", "Dysprosium", "Neodymium",
+				"Promethium", "Thulium"},
+		},
+		"pi": map[string]interface{}{
+			"station": 60003760,
+			"items": []string{"Bacteria", "Biofuels", "Biomass", "Chiral Structures",
+				"Electrolytes", "Industrial Fibers", "Oxidizing Compound", "Oxygen",
+				"Plasmoids", "Precious Metals", "Proteins", "Reactive Metals",
+				"Silicon", "Toxic Metals", "Water"},
+		},
+	})
+
+	// BuybackQuoteLifetime: how long a buyback quote remains valid.
+	viper.SetDefault("BuybackQuoteLifetime", "24h")
 
 	// Session cookies - you must set these explicitly.
 	// viper.SetDefault("CookieDomain", "localhost")
@@ -74,6 +116,7 @@ func main() {
 	for _, flag := range flags {
 		viper.BindPFlag(flag, rootCmd.Flags().Lookup(flag))
 	}
+	rootCmd.AddCommand(importOrdersCommand())
 	log.SetFormatter(&log.TextFormatter{ForceColors: true})
 	rootCmd.Execute()
 }
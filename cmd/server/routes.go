@@ -19,6 +19,7 @@ package main
 
 import (
 	"net/http"
+	"time"
 
 	"github.com/backerman/evego"
 	"github.com/backerman/evego/pkg/evesso"
@@ -29,7 +30,9 @@ import (
 )
 
 func setRoutes(mux *web.Mux, sde evego.Database, localdb db.LocalDB, xmlAPI evego.XMLAPI,
-	eveCentral evego.Market, sessionizer server.Sessionizer, cache evego.Cache) {
+	mkt evego.Market, router evego.Router, sessionizer server.Sessionizer, cache evego.Cache,
+	marketItems []string, tickers map[string]api.Ticker, quoteLifetime time.Duration,
+	uploadKeys map[string]string, admins []int) {
 
 	if c.Dev {
 		bower := http.FileServer(http.Dir("bower_components"))
@@ -39,14 +42,57 @@ func setRoutes(mux *web.Mux, sde evego.Database, localdb db.LocalDB, xmlAPI eveg
 	mux.Get("/autocomplete/system/:name", api.AutocompleteSystems(sde))
 	mux.Get("/autocomplete/station/:name", api.AutocompleteStations(sde, localdb, xmlAPI))
 	mux.Post("/pastebin", api.ParseItems(sde))
-	marketHandler := api.ItemsMarketValue(sde, eveCentral, xmlAPI)
-	// For now these do the same thing. That may change.
-	mux.Post("/market/region/:location", marketHandler)
-	mux.Post("/market/system/:location", marketHandler)
+	marketHandler := api.ItemsMarketValue(sde, mkt, xmlAPI, router)
+	// The handler decides on the scope of the query from the parameter name.
+	mux.Post("/market/region/:region", marketHandler)
+	mux.Post("/market/system/:system", marketHandler)
 	mux.Post("/market/station/:id", marketHandler)
-	mux.Get("/market/jita", api.ReprocessOutputValues(sde, eveCentral, xmlAPI, cache))
+	mux.Post("/market/compare", api.CompareHubs(sde, mkt, xmlAPI, router))
+	mux.Get("/market/ticker/:name", api.MarketTicker(sde, localdb, mkt, xmlAPI, router, cache,
+		sessionizer, tickers))
+	listTickers, saveTicker, deleteTicker := api.TickerHandlers(sde, localdb, xmlAPI, sessionizer, tickers)
+	mux.Get("/market/tickers", listTickers)
+	mux.Post("/market/tickers", saveTicker)
+	mux.Post("/market/tickers/delete/:name", deleteTicker)
+	mux.Get("/market/history/:typeID", api.PriceHistory(localdb))
+	mux.Get("/market/margins/:stationID", api.MarketMargins(sde, localdb, mkt, xmlAPI, router,
+		sessionizer, marketItems))
+	mux.Get("/market/haul/:from/:to", api.HaulingArbitrage(sde, localdb, mkt, xmlAPI, router,
+		sessionizer, marketItems))
+	mux.Post("/market/snapshot", api.ImportMarketSnapshot(sde, localdb, sessionizer, admins, uploadKeys))
+	mux.Post("/market/upload", api.UploadMarketOrders(sde, localdb, uploadKeys))
+	mux.Get("/market/myorders/:charID", api.MyMarketOrders(sde, localdb, mkt, xmlAPI, sessionizer))
+	mux.Get("/contracts/:charID", api.Contracts(sde, localdb, mkt, xmlAPI, router, sessionizer))
+	piHandler := api.PlanetaryInteraction(sde, localdb, mkt, xmlAPI, router, sessionizer)
+	mux.Get("/planets", piHandler)
+	mux.Get("/planets/:charID", piHandler)
+
+	mux.Post("/reprocess", api.ReprocessItems(sde, localdb, mkt, xmlAPI, router, sessionizer))
+
+	// Watchlists and price alerts
+	listWatchlists, saveWatchlist, deleteWatchlist, listAlerts := api.WatchlistHandlers(sde, localdb, xmlAPI, sessionizer)
+	mux.Get("/watchlists", listWatchlists)
+	mux.Post("/watchlists", saveWatchlist)
+	mux.Post("/watchlists/delete/:id", deleteWatchlist)
+	mux.Get("/alerts", listAlerts)
+
+	// Appraisals
+	createAppraisal, getAppraisal, listAppraisals := api.AppraisalHandlers(sde, localdb, mkt, xmlAPI, router, sessionizer)
+	mux.Post("/appraisal", createAppraisal)
+	mux.Get("/appraisal/:id", getAppraisal)
+	mux.Get("/appraisals", listAppraisals)
+
+	// Buyback programs and quotes
+	listPrograms, getProgram, saveProgram, deleteProgram := api.BuybackProgramHandlers(sde, localdb, xmlAPI,
+		sessionizer, admins)
+	mux.Get("/buyback/programs", listPrograms)
+	mux.Get("/buyback/programs/:id", getProgram)
+	mux.Post("/buyback/programs", saveProgram)
+	mux.Post("/buyback/programs/delete/:id", deleteProgram)
+	createQuote, getQuote := api.BuybackQuoteHandlers(sde, localdb, mkt, xmlAPI, router, sessionizer, quoteLifetime)
+	mux.Post("/buyback/quote", createQuote)
+	mux.Get("/buyback/quote/:id", getQuote)
 
-	mux.Post("/reprocess", api.ReprocessItems(sde, eveCentral))
 	// SSO!
 	auth := evesso.MakeAuthenticator(evesso.Endpoint, c.ClientID, c.ClientSecret,
 		c.RedirectURL, evesso.PublicData)
@@ -64,12 +110,19 @@ func setRoutes(mux *web.Mux, sde evego.Database, localdb db.LocalDB, xmlAPI eveg
 
 	// Standings and skills
 	mux.Get("/standings/:charID/:npcCorpID", api.StandingsHandler(localdb, sessionizer))
+	mux.Get("/agents", api.AgentsHandler(localdb, sessionizer))
 	mux.Get("/skills/:charID/group/:skillGroupID", api.SkillsHandler(localdb, sessionizer))
+	mux.Get("/skills/:charID/queue", api.SkillQueueHandler(localdb, sessionizer))
+	mux.Get("/skills/:charID/plan/:typeID", api.SkillPlanHandler(localdb, sessionizer))
 
 	// Blueprints and industry
 	_, getBPs := api.BlueprintsHandlers(localdb, sde, sessionizer)
 	mux.Get("/blueprints/:charID", getBPs)
 	mux.Get("/assets/unusedSalvage/:charID", api.UnusedSalvage(localdb, sde, sessionizer))
+	mux.Get("/industry/jobs/:charID", api.IndustryJobs(localdb, sessionizer))
+
+	// Wallet
+	mux.Get("/wallet/:charID/pnl", api.ProfitAndLoss(localdb, sessionizer))
 
 	// Static assets
 	assets := http.FileServer(http.Dir("dist"))
@@ -20,6 +20,7 @@ package main
 import (
 	"net"
 	"net/http"
+	"time"
 
 	"github.com/backerman/evego"
 	"github.com/backerman/evego/pkg/cache"
@@ -27,8 +28,12 @@ import (
 	"github.com/backerman/evego/pkg/eveapi"
 	"github.com/backerman/evego/pkg/market"
 	"github.com/backerman/evego/pkg/routing"
+	"github.com/backerman/eveindy/pkg/api"
 	"github.com/backerman/eveindy/pkg/db"
+	"github.com/backerman/eveindy/pkg/notify"
+	"github.com/backerman/eveindy/pkg/orderbook"
 	"github.com/backerman/eveindy/pkg/server"
+	"github.com/backerman/eveindy/pkg/xmlapi"
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/spf13/cobra"
@@ -41,6 +46,9 @@ import (
 
 var c config
 
+// webhookTimeout is how long we wait for a user's webhook to accept alerts.
+const webhookTimeout = 10 * time.Second
+
 type config struct {
 	Dev                      bool
 	DbDriver, DbPath         string
@@ -48,6 +56,13 @@ type config struct {
 	BindProtocol             string
 	XMLAPIEndpoint           string
 	Router                   string
+	Market                   string
+	HistoryItems             []string
+	HistoryStations          []int
+	Tickers                  map[string]api.Ticker
+	BuybackQuoteLifetime     string
+	UploadKeys               map[string]string
+	Admins                   []int
 	Cache                    string
 	RedisHost, RedisPassword string
 	CookieDomain, CookiePath string
@@ -74,6 +89,10 @@ func mainCommand(cmd *cobra.Command, args []string) {
 		log.Fatalf("Please set the ClientID, ClientSecret, and RedirectURL configuration " +
 			"options as registered with CCP.")
 	}
+	quoteLifetime, err := time.ParseDuration(c.BuybackQuoteLifetime)
+	if err != nil || quoteLifetime <= 0 {
+		log.Fatalf("The BuybackQuoteLifetime configuration option must be a duration such as \"24h\".")
+	}
 	// workaround for viper bug
 	// c.Dev = viper.GetBool("Dev")
 
@@ -94,7 +113,8 @@ func mainCommand(cmd *cobra.Command, args []string) {
 	}
 
 	xmlAPI := eveapi.XML(c.XMLAPIEndpoint, sde, myCache)
-	localdb, err := db.Interface(c.DbDriver, c.DbPath, xmlAPI)
+	charAPI := xmlapi.XML(c.XMLAPIEndpoint, myCache)
+	localdb, err := db.Interface(c.DbDriver, c.DbPath, xmlAPI, charAPI)
 	if err != nil {
 		log.Fatalf("Unable to connect to local database: %v", err)
 	}
@@ -111,18 +131,46 @@ func mainCommand(cmd *cobra.Command, args []string) {
 			"The Router configuration option must be set to \"evecentral\" (default) or \"sql\".")
 	}
 
-	eveCentralMarket := market.EveCentral(sde, router, xmlAPI,
-		"http://api.eve-central.com/api/quicklook", myCache)
+	var mkt evego.Market
+	switch c.Market {
+	case "evecentral":
+		mkt = market.EveCentral(sde, router, xmlAPI,
+			"http://api.eve-central.com/api/quicklook", myCache)
+	case "local":
+		mkt = orderbook.LocalMarket(localdb, sde, router, xmlAPI)
+	default:
+		log.Fatalf(
+			"The Market configuration option must be set to \"local\" (default) or \"evecentral\".")
+	}
+
+	// Item resolution falls back to exact-name lookups if we can't build the
+	// index of names to match against.
+	err = api.IndexItems(localdb)
+	if err != nil {
+		log.Errorf("Unable to index item names: %v", err)
+	}
+	// Without the stargate map, hauling routes only know the security of
+	// their ends.
+	err = api.IndexStargates(localdb)
+	if err != nil {
+		log.Errorf("Unable to load stargate map: %v", err)
+	}
 
 	sessionizer := server.GetSessionizer(c.CookieDomain, c.CookiePath, !c.Dev, localdb)
 
 	mux := newMux()
-	setRoutes(mux, sde, localdb, xmlAPI, eveCentralMarket, sessionizer, myCache)
+	// The margin and hauling searches look at the items whose history we
+	// record unless asked to scan a market group.
+	setRoutes(mux, sde, localdb, xmlAPI, mkt, router, sessionizer, myCache, c.HistoryItems,
+		c.Tickers, quoteLifetime, c.UploadKeys, c.Admins)
 
 	// Set up internal bits.
 
 	// Start background jobs.
-	server.StartJobs(localdb)
+	server.StartJobs(localdb, sde, xmlAPI, mkt, server.PriceHistoryConfig{
+		Items:    c.HistoryItems,
+		Stations: c.HistoryStations,
+	}, notify.Webhook(webhookTimeout))
 
 	serve(mux, c.BindProtocol, c.Bind)
 }
@@ -0,0 +1,40 @@
+/*
+Copyright © 2014–6 Brad Ackerman.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package api
+
+import "github.com/backerman/eveindy/pkg/db"
+
+// isAdmin returns true iff one of a user's characters is among the
+// administrators, who may manage data shared by every user.
+func isAdmin(localdb db.LocalDB, admins []int, userID int) (bool, error) {
+	if userID == 0 || len(admins) == 0 {
+		return false, nil
+	}
+	charIDs, err := localdb.UserCharacterIDs(userID)
+	if err != nil {
+		return false, err
+	}
+	for _, charID := range charIDs {
+		for _, admin := range admins {
+			if charID == admin {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
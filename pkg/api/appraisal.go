@@ -0,0 +1,336 @@
+/*
+Copyright © 2014–6 Brad Ackerman.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/backerman/evego"
+	"github.com/backerman/evego/pkg/parsing"
+	"github.com/backerman/eveindy/pkg/db"
+	"github.com/backerman/eveindy/pkg/marketstats"
+	"github.com/backerman/eveindy/pkg/server"
+	"github.com/zenazn/goji/web"
+)
+
+// appraisalIDBytes is the number of random bytes in an appraisal's ID.
+const appraisalIDBytes = 6
+
+// appraisalIDAttempts is the number of IDs we try before giving up on saving
+// an appraisal, in the unlikely event that each is already taken.
+const appraisalIDAttempts = 3
+
+// maxAppraisalBytes is the largest appraisal request we accept.
+const maxAppraisalBytes = 1 << 20
+
+// unparsedReason is the reason given for pasted lines that aren't items.
+const unparsedReason = "Unable to read this line."
+
+type appraisalRequest struct {
+	Paste string `json:"paste"`
+	// Items are priced at StationID, or in Region if it's set, or in Jita 4-4
+	// if neither is.
+	StationID   int     `json:"stationID"`
+	Region      string  `json:"region"`
+	PriceMethod string  `json:"priceMethod"`
+	TopPercent  float64 `json:"topPercent"`
+}
+
+type appraisal struct {
+	ID        string                  `json:"id"`
+	Created   time.Time               `json:"created"`
+	StationID int                     `json:"stationID,omitempty"`
+	Region    string                  `json:"region,omitempty"`
+	Location  string                  `json:"location"`
+	Pricing   pricingOptions          `json:"pricing"`
+	Items     []queryItem             `json:"items,omitempty"`
+	Prices    map[string]responseItem `json:"prices,omitempty"`
+	Unmatched []unmatchedLine         `json:"unmatched,omitempty"`
+	BuyTotal  priceFloat              `json:"buyTotal"`
+	SellTotal priceFloat              `json:"sellTotal"`
+}
+
+// parsePaste reads a paste into its items and the lines that couldn't be read
+// as items. Each line is parsed separately, as parsing.ParseInventory
+// silently skips lines it doesn't understand.
+func parsePaste(paste string, sde evego.Database) ([]queryItem, []unmatchedLine) {
+	items := []queryItem{}
+	unparsed := []unmatchedLine{}
+	for _, line := range strings.Split(paste, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		parsed := parsing.ParseInventory(line, sde)
+		if len(parsed) == 0 {
+			unparsed = append(unparsed, unmatchedLine{Line: line, Reason: unparsedReason})
+			continue
+		}
+		for _, p := range parsed {
+			items = append(items, queryItem{Quantity: p.Quantity, ItemName: p.Item.Name})
+		}
+	}
+	return items, unparsed
+}
+
+// priceAppraisal prices an appraisal's items at its location and fills in its
+// prices and totals.
+func priceAppraisal(sde evego.Database, mkt evego.Market, xmlAPI evego.XMLAPI,
+	router evego.Router, a *appraisal) error {
+	var (
+		scope *marketScope
+		err   error
+	)
+	if a.Region == "" {
+		scope, err = stationScopeForID(sde, xmlAPI, router, a.StationID)
+	} else {
+		scope, err = regionScope(sde, a.Region)
+	}
+	if err != nil {
+		return err
+	}
+	a.Location = scope.Name
+	// Lines that couldn't be read stay unmatched whatever the prices.
+	unmatched := []unmatchedLine{}
+	for _, u := range a.Unmatched {
+		if u.Reason == unparsedReason {
+			unmatched = append(unmatched, u)
+		}
+	}
+	var unpriced []unmatchedLine
+	a.Prices, unpriced = splitUnmatched(getItemPrices(sde, mkt, &a.Items, scope, a.Pricing))
+	a.Unmatched = append(unmatched, unpriced...)
+	a.BuyTotal, a.SellTotal = 0, 0
+	for _, i := range a.Items {
+		price, found := a.Prices[i.ItemName]
+		if !found {
+			continue
+		}
+		a.BuyTotal += price.BuyPrice * priceFloat(i.Quantity)
+		a.SellTotal += price.SellPrice * priceFloat(i.Quantity)
+	}
+	return nil
+}
+
+// fromStored converts an appraisal as stored in the database into the form we
+// send to clients.
+func fromStored(stored *db.Appraisal) (*appraisal, error) {
+	a := &appraisal{
+		ID:        stored.ID,
+		Created:   stored.Created,
+		StationID: stored.StationID,
+		Region:    stored.Region,
+		Location:  stored.Location,
+		Pricing: pricingOptions{
+			Method:     marketstats.PriceMethod(stored.Method),
+			TopPercent: stored.TopPercent,
+		},
+		BuyTotal:  priceFloat(stored.BuyTotal),
+		SellTotal: priceFloat(stored.SellTotal),
+	}
+	if stored.Items != nil {
+		err := json.Unmarshal(stored.Items, &a.Items)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if stored.Prices != nil {
+		err := json.Unmarshal(stored.Prices, &a.Prices)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if stored.Unmatched != nil {
+		err := json.Unmarshal(stored.Unmatched, &a.Unmatched)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return a, nil
+}
+
+// AppraisalHandlers returns web handler functions that create a new appraisal
+// from a paste, retrieve a saved appraisal (re-pricing it at the current
+// market if the reprice query parameter is true), and list the current
+// user's appraisals.
+func AppraisalHandlers(sde evego.Database, localdb db.LocalDB, mkt evego.Market,
+	xmlAPI evego.XMLAPI, router evego.Router, sess server.Sessionizer) (create, get, list web.HandlerFunc) {
+	create = func(c web.C, w http.ResponseWriter, r *http.Request) {
+		s := sess.GetSession(&c, w, r)
+		r.Body = http.MaxBytesReader(w, r.Body, maxAppraisalBytes)
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil && len(body) >= maxAppraisalBytes {
+			http.Error(w, `{"status": "Error", "error": "Appraisal is too large"}`,
+				http.StatusRequestEntityTooLarge)
+			return
+		}
+		if err != nil {
+			http.Error(w, `{"status": "Error", "error": "Unable to process request body"}`,
+				http.StatusBadRequest)
+			return
+		}
+		var req appraisalRequest
+		err = json.Unmarshal(body, &req)
+		if err != nil {
+			http.Error(w, `{"status": "Error", "error": "Unable to process request JSON"}`,
+				http.StatusBadRequest)
+			return
+		}
+		pricing, err := makePricingOptions(req.PriceMethod, req.TopPercent)
+		if err != nil {
+			http.Error(w, `{"status": "Error", "error": "Invalid pricing method"}`,
+				http.StatusBadRequest)
+			return
+		}
+		a := &appraisal{
+			StationID: req.StationID,
+			Region:    req.Region,
+			Pricing:   pricing,
+		}
+		if a.Region == "" && a.StationID == 0 {
+			a.StationID = jitaStationID
+		}
+		a.Items, a.Unmatched = parsePaste(req.Paste, sde)
+		err = priceAppraisal(sde, mkt, xmlAPI, router, a)
+		if err != nil {
+			http.Error(w, `{"status": "Error", "error": "Unable to price items"}`,
+				http.StatusBadRequest)
+			log.Printf("Unable to price appraisal: %v", err)
+			return
+		}
+		itemsJSON, _ := json.Marshal(a.Items)
+		pricesJSON, _ := json.Marshal(a.Prices)
+		unmatchedJSON, _ := json.Marshal(a.Unmatched)
+		stored := &db.Appraisal{
+			UserID:     s.User,
+			StationID:  a.StationID,
+			Region:     a.Region,
+			Location:   a.Location,
+			Method:     string(a.Pricing.Method),
+			TopPercent: a.Pricing.TopPercent,
+			Items:      itemsJSON,
+			Prices:     pricesJSON,
+			Unmatched:  unmatchedJSON,
+			BuyTotal:   float64(a.BuyTotal),
+			SellTotal:  float64(a.SellTotal),
+		}
+		err = db.ErrDuplicateID
+		for attempt := 0; attempt < appraisalIDAttempts && err == db.ErrDuplicateID; attempt++ {
+			stored.ID, err = server.RandomID(appraisalIDBytes)
+			if err != nil {
+				break
+			}
+			err = localdb.SaveAppraisal(stored)
+		}
+		if err != nil {
+			http.Error(w, `{"status": "Error", "error": "Unable to save appraisal"}`,
+				http.StatusInternalServerError)
+			log.Printf("Unable to save appraisal: %v", err)
+			return
+		}
+		a.ID = stored.ID
+		a.Created = stored.Created
+		response := struct {
+			Status    string     `json:"status"`
+			Appraisal *appraisal `json:"appraisal"`
+		}{"OK", a}
+		responseJSON, _ := json.Marshal(&response)
+		w.Write(responseJSON)
+	}
+
+	get = func(c web.C, w http.ResponseWriter, r *http.Request) {
+		stored, err := localdb.Appraisal(c.URLParams["id"])
+		if err == sql.ErrNoRows {
+			http.Error(w, `{"status": "Error", "error": "No such appraisal."}`,
+				http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, `{"status": "Error", "error": "Unable to access database."}`,
+				http.StatusInternalServerError)
+			log.Printf("Unable to get appraisal %v: %v", c.URLParams["id"], err)
+			return
+		}
+		a, err := fromStored(stored)
+		if err != nil {
+			http.Error(w, `{"status": "Error", "error": "Unable to read appraisal."}`,
+				http.StatusInternalServerError)
+			log.Printf("Unable to unmarshal appraisal %v: %v", stored.ID, err)
+			return
+		}
+		response := struct {
+			Status    string     `json:"status"`
+			Appraisal *appraisal `json:"appraisal"`
+			// Current is the same appraisal at today's prices, if requested.
+			Current *appraisal `json:"current,omitempty"`
+		}{Status: "OK", Appraisal: a}
+		if r.URL.Query().Get("reprice") == "true" {
+			current := *a
+			err = priceAppraisal(sde, mkt, xmlAPI, router, &current)
+			if err != nil {
+				http.Error(w, `{"status": "Error", "error": "Unable to price items"}`,
+					http.StatusInternalServerError)
+				log.Printf("Unable to reprice appraisal %v: %v", a.ID, err)
+				return
+			}
+			current.Created = time.Now()
+			response.Current = &current
+		}
+		responseJSON, _ := json.Marshal(&response)
+		w.Write(responseJSON)
+	}
+
+	list = func(c web.C, w http.ResponseWriter, r *http.Request) {
+		s := sess.GetSession(&c, w, r)
+		if s.User == 0 {
+			http.Error(w, `{"status": "Error", "error": "You must be logged in to list appraisals."}`,
+				http.StatusUnauthorized)
+			return
+		}
+		stored, err := localdb.UserAppraisals(s.User)
+		if err != nil {
+			http.Error(w, `{"status": "Error", "error": "Unable to access database."}`,
+				http.StatusInternalServerError)
+			log.Printf("Unable to list appraisals for user %v: %v", s.User, err)
+			return
+		}
+		appraisals := make([]*appraisal, 0, len(stored))
+		for i := range stored {
+			a, err := fromStored(&stored[i])
+			if err != nil {
+				log.Printf("Unable to unmarshal appraisal %v: %v", stored[i].ID, err)
+				continue
+			}
+			appraisals = append(appraisals, a)
+		}
+		response := struct {
+			Status     string       `json:"status"`
+			Appraisals []*appraisal `json:"appraisals"`
+		}{"OK", appraisals}
+		responseJSON, _ := json.Marshal(&response)
+		w.Write(responseJSON)
+	}
+	return
+}
@@ -0,0 +1,200 @@
+/*
+Copyright © 2014–6 Brad Ackerman.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package api
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/backerman/evego"
+	"github.com/backerman/eveindy/pkg/db"
+	"github.com/zenazn/goji/web"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// appraisalSDE knows a single item and region.
+type appraisalSDE struct {
+	evego.Database
+}
+
+var tritanium = &evego.Item{ID: 34, Name: "Tritanium"}
+
+func (appraisalSDE) ItemForName(name string) (*evego.Item, error) {
+	if strings.ToLower(name) == "tritanium" {
+		return tritanium, nil
+	}
+	return nil, errNotFound
+}
+
+func (appraisalSDE) ItemForID(typeID int) (*evego.Item, error) {
+	if typeID == tritanium.ID {
+		return tritanium, nil
+	}
+	return nil, errNotFound
+}
+
+func (appraisalSDE) RegionForName(name string) (*evego.Region, error) {
+	if name == "The Forge" {
+		return &evego.Region{ID: 10000002, Name: name}, nil
+	}
+	return nil, errNotFound
+}
+
+// appraisalMarket has no orders for anything.
+type appraisalMarket struct {
+	evego.Market
+}
+
+func (appraisalMarket) OrdersForItem(item *evego.Item, location string,
+	orderType evego.OrderType) (*[]evego.Order, error) {
+	return &[]evego.Order{}, nil
+}
+
+// appraisalDB stores appraisals in memory; the first taken IDs generated are
+// treated as already in use.
+type appraisalDB struct {
+	db.LocalDB
+	saved map[string]db.Appraisal
+	taken int
+}
+
+func (d *appraisalDB) SaveAppraisal(a *db.Appraisal) error {
+	if d.taken > 0 {
+		d.taken--
+		return db.ErrDuplicateID
+	}
+	a.Created = time.Now()
+	d.saved[a.ID] = *a
+	return nil
+}
+
+func (d *appraisalDB) Appraisal(id string) (*db.Appraisal, error) {
+	a, found := d.saved[id]
+	if !found {
+		return nil, sql.ErrNoRows
+	}
+	return &a, nil
+}
+
+// anonymousSessions gives every request a logged-out session.
+type anonymousSessions struct{}
+
+func (anonymousSessions) GetSession(c *web.C, w http.ResponseWriter, r *http.Request) *db.Session {
+	return &db.Session{}
+}
+
+type appraisalResponse struct {
+	Status    string    `json:"status"`
+	Appraisal appraisal `json:"appraisal"`
+}
+
+func TestAppraisals(t *testing.T) {
+	Convey("Verify creating and fetching appraisals", t, func() {
+		localdb := &appraisalDB{saved: make(map[string]db.Appraisal)}
+		create, get, _ := AppraisalHandlers(appraisalSDE{}, localdb, appraisalMarket{},
+			nil, nil, anonymousSessions{})
+		post := func(body string) *httptest.ResponseRecorder {
+			w := httptest.NewRecorder()
+			r, _ := http.NewRequest("POST", "/appraisal", strings.NewReader(body))
+			create(web.C{}, w, r)
+			return w
+		}
+		fetch := func(id string) *httptest.ResponseRecorder {
+			w := httptest.NewRecorder()
+			r, _ := http.NewRequest("GET", "/appraisal/"+id, nil)
+			get(web.C{URLParams: map[string]string{"id": id}}, w, r)
+			return w
+		}
+		paste := func(lines ...string) string {
+			req, _ := json.Marshal(appraisalRequest{
+				Paste:  strings.Join(lines, "\n"),
+				Region: "The Forge",
+			})
+			return string(req)
+		}
+
+		Convey("An appraisal can be fetched once created", func() {
+			w := post(paste("Tritanium\t1,000"))
+			So(w.Code, ShouldEqual, http.StatusOK)
+			var created appraisalResponse
+			So(json.Unmarshal(w.Body.Bytes(), &created), ShouldBeNil)
+			So(created.Appraisal.ID, ShouldNotEqual, "")
+			So(created.Appraisal.Location, ShouldEqual, "The Forge")
+			So(created.Appraisal.Items, ShouldResemble,
+				[]queryItem{{Quantity: 1000, ItemName: "Tritanium"}})
+			So(created.Appraisal.Unmatched, ShouldBeEmpty)
+
+			w = fetch(created.Appraisal.ID)
+			So(w.Code, ShouldEqual, http.StatusOK)
+			var fetched appraisalResponse
+			So(json.Unmarshal(w.Body.Bytes(), &fetched), ShouldBeNil)
+			So(fetched.Appraisal.ID, ShouldEqual, created.Appraisal.ID)
+			So(fetched.Appraisal.Items, ShouldResemble, created.Appraisal.Items)
+		})
+
+		Convey("Lines that aren't items are unmatched", func() {
+			w := post(paste("Tritanium\t10", "Unobtainium\t5", "", "not an item at all"))
+			So(w.Code, ShouldEqual, http.StatusOK)
+			var created appraisalResponse
+			So(json.Unmarshal(w.Body.Bytes(), &created), ShouldBeNil)
+			So(created.Appraisal.Items, ShouldHaveLength, 1)
+			So(created.Appraisal.Unmatched, ShouldResemble, []unmatchedLine{
+				{Line: "Unobtainium\t5", Reason: unparsedReason},
+				{Line: "not an item at all", Reason: unparsedReason},
+			})
+
+			Convey("and stay unmatched when repriced", func() {
+				w := httptest.NewRecorder()
+				r, _ := http.NewRequest("GET", "/appraisal/"+created.Appraisal.ID+"?reprice=true", nil)
+				get(web.C{URLParams: map[string]string{"id": created.Appraisal.ID}}, w, r)
+				var fetched struct {
+					Current appraisal `json:"current"`
+				}
+				So(json.Unmarshal(w.Body.Bytes(), &fetched), ShouldBeNil)
+				So(fetched.Current.Unmatched, ShouldResemble, created.Appraisal.Unmatched)
+			})
+		})
+
+		Convey("A taken ID is replaced", func() {
+			localdb.taken = 2
+			before := len(localdb.saved)
+			w := post(paste("Tritanium\t10"))
+			So(w.Code, ShouldEqual, http.StatusOK)
+			So(localdb.taken, ShouldEqual, 0)
+			So(len(localdb.saved), ShouldEqual, before+1)
+		})
+
+		Convey("Unknown appraisals aren't found", func() {
+			So(fetch("nonesuch").Code, ShouldEqual, http.StatusNotFound)
+		})
+
+		Convey("Oversized appraisals are rejected", func() {
+			before := len(localdb.saved)
+			w := post(`{"paste": "` + string(bytes.Repeat([]byte("x"), maxAppraisalBytes)) + `"}`)
+			So(w.Code, ShouldEqual, http.StatusRequestEntityTooLarge)
+			So(len(localdb.saved), ShouldEqual, before)
+		})
+	})
+}
@@ -0,0 +1,637 @@
+/*
+Copyright © 2014–6 Brad Ackerman.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/backerman/evego"
+	"github.com/backerman/evego/pkg/industry"
+	"github.com/backerman/evego/pkg/parsing"
+	"github.com/backerman/eveindy/pkg/db"
+	"github.com/backerman/eveindy/pkg/server"
+	"github.com/zenazn/goji/web"
+)
+
+// quoteIDBytes is the number of random bytes in a buyback quote's ID.
+const quoteIDBytes = 6
+
+// The prices a buyback rule can pay a percentage of.
+const (
+	basisBuy         = "buy"
+	basisSell        = "sell"
+	basisReprocessed = "reprocessed"
+)
+
+// buybackRule sets the price paid for the items in a market group (including
+// its subgroups) or a category; a rule with neither set covers everything
+// else.
+type buybackRule struct {
+	MarketGroupID int    `json:"marketGroupID,omitempty"`
+	CategoryID    int    `json:"categoryID,omitempty"`
+	Basis         string `json:"basis"`
+	// Percent is the percentage of the basis price paid.
+	Percent float64 `json:"percent"`
+}
+
+// buybackReproSkills returns the skills of the refiner that buyback programs
+// price reprocessed items for: one with every skill affecting the yield of
+// item at level V.
+func buybackReproSkills(item *evego.Item) reproSkillSet {
+	return reproSkillSet{
+		Reprocessing:           5,
+		ReprocessingEfficiency: 5,
+		ScrapmetalProcessing:   5,
+		OreProcessing:          map[string]int{item.Group + processingSkillSuffix: 5},
+	}
+}
+
+// buybackProgram is a buyback program as sent to and from clients. Its
+// RefineYield is the base yield, in percent, of the facility where items
+// priced at their reprocessed value are refined.
+type buybackProgram struct {
+	ID          int           `json:"id"`
+	Name        string        `json:"name"`
+	StationID   int           `json:"stationID"`
+	StationName string        `json:"stationName"`
+	RefineYield float64       `json:"refineYield"`
+	Rules       []buybackRule `json:"rules"`
+}
+
+// quoteLine is an item priced under a buyback program.
+type quoteLine struct {
+	ItemName string  `json:"itemName"`
+	TypeID   int     `json:"typeID"`
+	Quantity int     `json:"quantity"`
+	Basis    string  `json:"basis"`
+	Percent  float64 `json:"percent"`
+	// UnitPrice is the basis price of a single unit, and Value what the
+	// program pays for the whole line.
+	UnitPrice priceFloat `json:"unitPrice"`
+	Value     priceFloat `json:"value"`
+}
+
+// buybackQuote is a buyback quote as sent to clients.
+type buybackQuote struct {
+	ID        string          `json:"id"`
+	ProgramID int             `json:"programID"`
+	Program   string          `json:"program"`
+	Location  string          `json:"location"`
+	Created   time.Time       `json:"created"`
+	Expires   time.Time       `json:"expires"`
+	Expired   bool            `json:"expired"`
+	Lines     []quoteLine     `json:"lines"`
+	Unmatched []unmatchedLine `json:"unmatched"`
+	Total     priceFloat      `json:"total"`
+}
+
+// ruleFor returns the rule that applies to an item, given the path of market
+// groups above it and its category. The rule for the nearest market group
+// wins over one for the category, which wins over the default rule; nil is
+// returned if none of them apply.
+func (p *buybackProgram) ruleFor(path []int, categoryID int) *buybackRule {
+	for _, groupID := range path {
+		for i := range p.Rules {
+			if p.Rules[i].MarketGroupID == groupID {
+				return &p.Rules[i]
+			}
+		}
+	}
+	var fallback *buybackRule
+	for i := range p.Rules {
+		rule := &p.Rules[i]
+		if rule.MarketGroupID != 0 {
+			continue
+		}
+		if rule.CategoryID != 0 && rule.CategoryID == categoryID {
+			return rule
+		}
+		if rule.CategoryID == 0 && fallback == nil {
+			fallback = rule
+		}
+	}
+	return fallback
+}
+
+// fromStoredProgram converts a buyback program from the database into the
+// form we send to clients.
+func fromStoredProgram(sde evego.Database, xmlAPI evego.XMLAPI, names map[int]string,
+	stored *db.BuybackProgram) (*buybackProgram, error) {
+	p := &buybackProgram{
+		ID:          stored.ID,
+		Name:        stored.Name,
+		StationID:   stored.StationID,
+		StationName: stationName(sde, xmlAPI, names, stored.StationID),
+		RefineYield: stored.RefineYield,
+		Rules:       []buybackRule{},
+	}
+	err := json.Unmarshal(stored.Rules, &p.Rules)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// toStoredProgram validates a buyback program sent by a client and converts
+// it into the form stored in the database. The returned string describes the
+// problem if the program is invalid.
+func toStoredProgram(sde evego.Database, xmlAPI evego.XMLAPI, userID int,
+	p *buybackProgram) (*db.BuybackProgram, string) {
+	if p.Name == "" {
+		return nil, "Buyback program must have a name."
+	}
+	if p.StationID == 0 {
+		p.StationID = jitaStationID
+	}
+	_, err := findStation(sde, xmlAPI, p.StationID)
+	if err != nil {
+		return nil, "Unknown station " + strconv.Itoa(p.StationID)
+	}
+	if p.RefineYield < 0 || p.RefineYield > 100 {
+		return nil, "Refine yield must be between 0 and 100 percent."
+	}
+	if len(p.Rules) == 0 {
+		return nil, "Buyback program must have at least one rule."
+	}
+	type target struct{ group, category int }
+	seen := make(map[target]bool)
+	for _, rule := range p.Rules {
+		if rule.MarketGroupID != 0 && rule.CategoryID != 0 {
+			return nil, "A rule may cover a market group or a category, not both."
+		}
+		t := target{rule.MarketGroupID, rule.CategoryID}
+		if seen[t] {
+			return nil, "Each market group or category may only have one rule."
+		}
+		seen[t] = true
+		switch rule.Basis {
+		case basisBuy, basisSell, basisReprocessed:
+		default:
+			return nil, "Rule basis must be buy, sell or reprocessed."
+		}
+		if rule.Percent < 0 || rule.Percent > 100 {
+			return nil, "Rule percentage must be between 0 and 100."
+		}
+	}
+	rulesJSON, _ := json.Marshal(p.Rules)
+	return &db.BuybackProgram{
+		ID:          p.ID,
+		UserID:      userID,
+		Name:        p.Name,
+		StationID:   p.StationID,
+		RefineYield: p.RefineYield,
+		Rules:       rulesJSON,
+	}, ""
+}
+
+// quoteItems prices the items in a paste under a buyback program at the
+// market in scope. Items that no rule covers, or that can't be priced, are
+// returned as unmatched.
+func quoteItems(sde evego.Database, localdb db.LocalDB, mkt evego.Market, scope *marketScope,
+	program *buybackProgram, paste []evego.InventoryLine) ([]quoteLine, []unmatchedLine, error) {
+	// Combine repeated items, keeping them in the order they were pasted.
+	var items []*evego.Item
+	quantities := make(map[int]int)
+	for _, line := range paste {
+		if _, found := quantities[line.Item.ID]; !found {
+			items = append(items, line.Item)
+		}
+		quantities[line.Item.ID] += line.Quantity
+	}
+
+	lines := []quoteLine{}
+	unmatched := []unmatchedLine{}
+	materials := make(map[int][]evego.InventoryLine)
+	var toPrice []queryItem
+	for _, item := range items {
+		quantity := quantities[item.ID]
+		path, err := localdb.MarketGroupPath(item.ID)
+		if err != nil {
+			return nil, nil, err
+		}
+		rule := program.ruleFor(path, item.CategoryID)
+		if rule == nil {
+			unmatched = append(unmatched, unmatchedLine{
+				Line:     item.Name,
+				Quantity: quantity,
+				Reason:   "No buyback rule covers this item.",
+			})
+			continue
+		}
+		if rule.Basis == basisReprocessed {
+			// As for /reprocess, the yield already includes the effect of
+			// skills (see reproSkillSet), so none are passed on.
+			skills := buybackReproSkills(item)
+			output, err := industry.ReprocessItem(sde, item, quantity,
+				skills.yield(item, program.RefineYield*0.01, 0), 0, industry.ReproSkills{})
+			if err != nil {
+				return nil, nil, err
+			}
+			materials[item.ID] = output
+			for _, m := range output {
+				toPrice = append(toPrice, queryItem{Quantity: m.Quantity, ItemName: m.Item.Name})
+			}
+		} else {
+			toPrice = append(toPrice, queryItem{Quantity: quantity, ItemName: item.Name})
+		}
+		lines = append(lines, quoteLine{
+			ItemName: item.Name,
+			TypeID:   item.ID,
+			Quantity: quantity,
+			Basis:    rule.Basis,
+			Percent:  rule.Percent,
+		})
+	}
+
+	prices := *getItemPrices(sde, mkt, &toPrice, scope, defaultPricing)
+	priced := lines[:0]
+	for _, line := range lines {
+		var value priceFloat
+		problem := ""
+		switch line.Basis {
+		case basisReprocessed:
+			for _, m := range materials[line.TypeID] {
+				price, found := prices[m.Item.Name]
+				if !found || price.Error != "" {
+					problem = "Unable to price " + m.Item.Name + "."
+					break
+				}
+				value += price.BuyPrice * priceFloat(m.Quantity)
+			}
+			if len(materials[line.TypeID]) == 0 {
+				problem = "Not enough to reprocess."
+			}
+			line.UnitPrice = value / priceFloat(line.Quantity)
+		default:
+			price, found := prices[line.ItemName]
+			if !found || price.Error != "" {
+				problem = "Unable to price item."
+				break
+			}
+			line.UnitPrice = price.BuyPrice
+			if line.Basis == basisSell {
+				line.UnitPrice = price.SellPrice
+			}
+			value = line.UnitPrice * priceFloat(line.Quantity)
+		}
+		if problem != "" {
+			unmatched = append(unmatched, unmatchedLine{
+				Line:     line.ItemName,
+				Quantity: line.Quantity,
+				Reason:   problem,
+			})
+			continue
+		}
+		line.Value = value * priceFloat(line.Percent*0.01)
+		priced = append(priced, line)
+	}
+	return priced, unmatched, nil
+}
+
+// fromStoredQuote converts a buyback quote as stored in the database into the
+// form we send to clients.
+func fromStoredQuote(stored *db.BuybackQuote) (*buybackQuote, error) {
+	q := &buybackQuote{
+		ID:        stored.ID,
+		ProgramID: stored.ProgramID,
+		Program:   stored.Program,
+		Location:  stored.Location,
+		Created:   stored.Created,
+		Expires:   stored.Expires,
+		Expired:   time.Now().After(stored.Expires),
+		Total:     priceFloat(stored.Total),
+	}
+	err := json.Unmarshal(stored.Lines, &q.Lines)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(stored.Unmatched, &q.Unmatched)
+	if err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// BuybackProgramHandlers returns web handler functions that list the buyback
+// programs the current user administers, retrieve any program (so that
+// sellers can see its rules), create or replace one of the user's programs,
+// and delete one. Only administrators may manage programs.
+func BuybackProgramHandlers(sde evego.Database, localdb db.LocalDB, xmlAPI evego.XMLAPI,
+	sess server.Sessionizer, admins []int) (list, get, save, remove web.HandlerFunc) {
+	// programAdmin checks that a user may manage buyback programs, sending an
+	// error response and returning false if not.
+	programAdmin := func(w http.ResponseWriter, userID int) bool {
+		if userID == 0 {
+			http.Error(w, `{"status": "Error", "error": "You must be logged in to manage buyback programs."}`,
+				http.StatusUnauthorized)
+			return false
+		}
+		admin, err := isAdmin(localdb, admins, userID)
+		if err != nil {
+			http.Error(w, `{"status": "Error", "error": "Unable to access database."}`,
+				http.StatusInternalServerError)
+			log.Printf("Unable to check whether user %v is an administrator: %v", userID, err)
+			return false
+		}
+		if !admin {
+			http.Error(w, `{"status": "Error", "error": "You must be an administrator to manage buyback programs."}`,
+				http.StatusForbidden)
+			return false
+		}
+		return true
+	}
+
+	list = func(c web.C, w http.ResponseWriter, r *http.Request) {
+		s := sess.GetSession(&c, w, r)
+		if !programAdmin(w, s.User) {
+			return
+		}
+		stored, err := localdb.BuybackPrograms(s.User)
+		if err != nil {
+			http.Error(w, `{"status": "Error", "error": "Unable to access database."}`,
+				http.StatusInternalServerError)
+			log.Printf("Unable to get buyback programs for user %v: %v", s.User, err)
+			return
+		}
+		names := make(map[int]string)
+		programs := make([]*buybackProgram, 0, len(stored))
+		for i := range stored {
+			p, err := fromStoredProgram(sde, xmlAPI, names, &stored[i])
+			if err != nil {
+				log.Printf("Unable to unmarshal buyback program %v: %v", stored[i].ID, err)
+				continue
+			}
+			programs = append(programs, p)
+		}
+		response := struct {
+			Status   string            `json:"status"`
+			Programs []*buybackProgram `json:"programs"`
+		}{"OK", programs}
+		responseJSON, _ := json.Marshal(&response)
+		w.Write(responseJSON)
+	}
+
+	get = func(c web.C, w http.ResponseWriter, r *http.Request) {
+		programID, err := strconv.Atoi(c.URLParams["id"])
+		if err != nil {
+			http.Error(w, `{"status": "Error", "error": "Invalid program ID supplied."}`,
+				http.StatusBadRequest)
+			return
+		}
+		stored, err := localdb.BuybackProgram(programID)
+		if err == sql.ErrNoRows {
+			http.Error(w, `{"status": "Error", "error": "No such buyback program."}`,
+				http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, `{"status": "Error", "error": "Unable to access database."}`,
+				http.StatusInternalServerError)
+			log.Printf("Unable to get buyback program %v: %v", programID, err)
+			return
+		}
+		p, err := fromStoredProgram(sde, xmlAPI, make(map[int]string), stored)
+		if err != nil {
+			http.Error(w, `{"status": "Error", "error": "Unable to read buyback program."}`,
+				http.StatusInternalServerError)
+			log.Printf("Unable to unmarshal buyback program %v: %v", programID, err)
+			return
+		}
+		response := struct {
+			Status  string          `json:"status"`
+			Program *buybackProgram `json:"program"`
+		}{"OK", p}
+		responseJSON, _ := json.Marshal(&response)
+		w.Write(responseJSON)
+	}
+
+	save = func(c web.C, w http.ResponseWriter, r *http.Request) {
+		s := sess.GetSession(&c, w, r)
+		if !programAdmin(w, s.User) {
+			return
+		}
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, `{"status": "Error", "error": "Unable to read request."}`,
+				http.StatusBadRequest)
+			return
+		}
+		var req buybackProgram
+		err = json.Unmarshal(body, &req)
+		if err != nil {
+			http.Error(w, `{"status": "Error", "error": "Unable to parse request."}`,
+				http.StatusBadRequest)
+			return
+		}
+		stored, problem := toStoredProgram(sde, xmlAPI, s.User, &req)
+		if stored == nil {
+			errJSON, _ := json.Marshal(struct {
+				Status string `json:"status"`
+				Error  string `json:"error"`
+			}{"Error", problem})
+			http.Error(w, string(errJSON), http.StatusBadRequest)
+			return
+		}
+		err = localdb.SaveBuybackProgram(stored)
+		if err == sql.ErrNoRows {
+			http.Error(w, `{"status": "Error", "error": "No such buyback program."}`,
+				http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, `{"status": "Error", "error": "Unable to save buyback program."}`,
+				http.StatusInternalServerError)
+			log.Printf("Unable to save buyback program for user %v: %v", s.User, err)
+			return
+		}
+		req.ID = stored.ID
+		req.StationName = stationName(sde, xmlAPI, make(map[int]string), req.StationID)
+		response := struct {
+			Status  string         `json:"status"`
+			Program buybackProgram `json:"program"`
+		}{"OK", req}
+		responseJSON, _ := json.Marshal(&response)
+		w.Write(responseJSON)
+	}
+
+	remove = func(c web.C, w http.ResponseWriter, r *http.Request) {
+		s := sess.GetSession(&c, w, r)
+		if !programAdmin(w, s.User) {
+			return
+		}
+		programID, err := strconv.Atoi(c.URLParams["id"])
+		if err != nil {
+			http.Error(w, `{"status": "Error", "error": "Invalid program ID supplied."}`,
+				http.StatusBadRequest)
+			return
+		}
+		err = localdb.DeleteBuybackProgram(s.User, programID)
+		if err != nil {
+			http.Error(w, `{"status": "Error", "error": "Unable to delete buyback program."}`,
+				http.StatusInternalServerError)
+			log.Printf("Unable to delete buyback program %v: %v", programID, err)
+			return
+		}
+		w.Write([]byte(`{"status": "OK"}`))
+	}
+
+	return
+}
+
+// BuybackQuoteHandlers returns web handler functions that price a paste under
+// a buyback program and save the result as a quote valid for lifetime, and
+// retrieve a saved quote by its ID.
+func BuybackQuoteHandlers(sde evego.Database, localdb db.LocalDB, mkt evego.Market,
+	xmlAPI evego.XMLAPI, router evego.Router, sess server.Sessionizer,
+	lifetime time.Duration) (create, get web.HandlerFunc) {
+	create = func(c web.C, w http.ResponseWriter, r *http.Request) {
+		s := sess.GetSession(&c, w, r)
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, `{"status": "Error", "error": "Unable to read request."}`,
+				http.StatusBadRequest)
+			return
+		}
+		var req struct {
+			Program int    `json:"program"`
+			Paste   string `json:"paste"`
+		}
+		err = json.Unmarshal(body, &req)
+		if err != nil {
+			http.Error(w, `{"status": "Error", "error": "Unable to parse request."}`,
+				http.StatusBadRequest)
+			return
+		}
+		stored, err := localdb.BuybackProgram(req.Program)
+		if err == sql.ErrNoRows {
+			http.Error(w, `{"status": "Error", "error": "No such buyback program."}`,
+				http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, `{"status": "Error", "error": "Unable to access database."}`,
+				http.StatusInternalServerError)
+			log.Printf("Unable to get buyback program %v: %v", req.Program, err)
+			return
+		}
+		program, err := fromStoredProgram(sde, xmlAPI, make(map[int]string), stored)
+		if err != nil {
+			http.Error(w, `{"status": "Error", "error": "Unable to read buyback program."}`,
+				http.StatusInternalServerError)
+			log.Printf("Unable to unmarshal buyback program %v: %v", req.Program, err)
+			return
+		}
+		scope, err := stationScopeForID(sde, xmlAPI, router, program.StationID)
+		if err != nil {
+			http.Error(w, `{"status": "Error", "error": "Unable to find the program's station."}`,
+				http.StatusInternalServerError)
+			log.Printf("Unable to get station %v for buyback program %v: %v",
+				program.StationID, program.ID, err)
+			return
+		}
+		lines, unmatched, err := quoteItems(sde, localdb, mkt, scope, program,
+			parsing.ParseInventory(req.Paste, sde))
+		if err != nil {
+			http.Error(w, `{"status": "Error", "error": "Unable to price items."}`,
+				http.StatusInternalServerError)
+			log.Printf("Unable to quote under buyback program %v: %v", program.ID, err)
+			return
+		}
+		q := &buybackQuote{
+			ProgramID: program.ID,
+			Program:   program.Name,
+			Location:  scope.Name,
+			Lines:     lines,
+			Unmatched: unmatched,
+		}
+		for _, line := range lines {
+			q.Total += line.Value
+		}
+		q.ID, err = server.RandomID(quoteIDBytes)
+		if err != nil {
+			http.Error(w, `{"status": "Error", "error": "Unable to save quote."}`,
+				http.StatusInternalServerError)
+			log.Printf("Unable to generate quote ID: %v", err)
+			return
+		}
+		linesJSON, _ := json.Marshal(q.Lines)
+		unmatchedJSON, _ := json.Marshal(q.Unmatched)
+		storedQuote := &db.BuybackQuote{
+			ID:        q.ID,
+			ProgramID: q.ProgramID,
+			UserID:    s.User,
+			Expires:   time.Now().Add(lifetime),
+			Location:  q.Location,
+			Lines:     linesJSON,
+			Unmatched: unmatchedJSON,
+			Total:     float64(q.Total),
+		}
+		err = localdb.SaveBuybackQuote(storedQuote)
+		if err != nil {
+			http.Error(w, `{"status": "Error", "error": "Unable to save quote."}`,
+				http.StatusInternalServerError)
+			log.Printf("Unable to save buyback quote: %v", err)
+			return
+		}
+		q.Created = storedQuote.Created
+		q.Expires = storedQuote.Expires
+		response := struct {
+			Status string        `json:"status"`
+			Quote  *buybackQuote `json:"quote"`
+		}{"OK", q}
+		responseJSON, _ := json.Marshal(&response)
+		w.Write(responseJSON)
+	}
+
+	get = func(c web.C, w http.ResponseWriter, r *http.Request) {
+		stored, err := localdb.BuybackQuote(c.URLParams["id"])
+		if err == sql.ErrNoRows {
+			http.Error(w, `{"status": "Error", "error": "No such quote."}`,
+				http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, `{"status": "Error", "error": "Unable to access database."}`,
+				http.StatusInternalServerError)
+			log.Printf("Unable to get buyback quote %v: %v", c.URLParams["id"], err)
+			return
+		}
+		q, err := fromStoredQuote(stored)
+		if err != nil {
+			http.Error(w, `{"status": "Error", "error": "Unable to read quote."}`,
+				http.StatusInternalServerError)
+			log.Printf("Unable to unmarshal buyback quote %v: %v", stored.ID, err)
+			return
+		}
+		response := struct {
+			Status string        `json:"status"`
+			Quote  *buybackQuote `json:"quote"`
+		}{"OK", q}
+		responseJSON, _ := json.Marshal(&response)
+		w.Write(responseJSON)
+	}
+
+	return
+}
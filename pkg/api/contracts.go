@@ -0,0 +1,240 @@
+/*
+Copyright © 2014–6 Brad Ackerman.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/backerman/evego"
+	"github.com/backerman/eveindy/pkg/db"
+	"github.com/backerman/eveindy/pkg/server"
+	"github.com/backerman/eveindy/pkg/xmlapi"
+	"github.com/zenazn/goji/web"
+)
+
+// jitaStationID is Jita IV - Moon 4 - Caldari Navy Assembly Plant, where we
+// price things unless told otherwise.
+const jitaStationID = 60003760
+
+// defaultMispricedThreshold is how far (as a fraction of market value) a
+// contract's price may be from market before we flag it.
+const defaultMispricedThreshold = 0.2
+
+type valuedContract struct {
+	db.Contract
+	StartStation string `json:"startStation"`
+	EndStation   string `json:"endStation,omitempty"`
+	// The value of the items the issuer is providing, less that of the items
+	// they're asking for, at the best buy and sell prices.
+	BuyValue  priceFloat `json:"buyValue"`
+	SellValue priceFloat `json:"sellValue"`
+	// PriceRatio is the contract's net price divided by its sell value; zero if
+	// the items have no sell value.
+	PriceRatio float64 `json:"priceRatio"`
+	// Mispriced is "above" or "below" if the contract's price is far enough
+	// from market value to be worth a look.
+	Mispriced string `json:"mispriced,omitempty"`
+}
+
+type courierSummary struct {
+	// Collateral is the total collateral of courier contracts this character
+	// has accepted and not yet delivered.
+	Collateral priceFloat `json:"collateral"`
+	// IssuedCollateral is the total collateral of courier contracts this
+	// character has issued that are outstanding or in progress.
+	IssuedCollateral priceFloat `json:"issuedCollateral"`
+	// Reward is the total reward of courier contracts this character has
+	// accepted and not yet delivered.
+	Reward    priceFloat       `json:"reward"`
+	Contracts []valuedContract `json:"contracts"`
+}
+
+// pricingLocation returns the market scope named in a request's query
+// parameters: either the region passed in the region parameter, or the
+// station passed in the station parameter (Jita 4-4 if neither is given).
+func pricingLocation(sde evego.Database, xmlAPI evego.XMLAPI, router evego.Router, r *http.Request) (*marketScope, error) {
+	query := r.URL.Query()
+	region := query.Get("region")
+	if region != "" {
+		return regionScope(sde, region)
+	}
+	stationID := jitaStationID
+	if stn := query.Get("station"); stn != "" {
+		var err error
+		stationID, err = strconv.Atoi(stn)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return stationScopeForID(sde, xmlAPI, router, stationID)
+}
+
+// stationName returns the name of a station or outpost, caching the result.
+func stationName(sde evego.Database, xmlAPI evego.XMLAPI, names map[int]string, stationID int) string {
+	if stationID == 0 {
+		return ""
+	}
+	name, found := names[stationID]
+	if !found {
+		stn, err := findStation(sde, xmlAPI, stationID)
+		if err != nil {
+			log.Printf("Unable to look up station/outpost ID %v: %v", stationID, err)
+		} else {
+			name = stn.Name
+		}
+		names[stationID] = name
+	}
+	return name
+}
+
+// valueContract fills in the market value of a contract's items from the
+// passed prices and flags it if its price is too far from that value.
+func valueContract(vc *valuedContract, prices map[string]responseItem, threshold float64) {
+	var buyValue, sellValue float64
+	for _, item := range vc.Items {
+		price, found := prices[item.TypeName]
+		if !found {
+			continue
+		}
+		qty := float64(item.Quantity)
+		if !item.Included {
+			// The issuer is asking for these, so they count against the
+			// contract's value.
+			qty = -qty
+		}
+		buyValue += qty * float64(price.BuyPrice)
+		sellValue += qty * float64(price.SellPrice)
+	}
+	vc.BuyValue = priceFloat(buyValue)
+	vc.SellValue = priceFloat(sellValue)
+	if sellValue <= 0 {
+		return
+	}
+	// Any reward is paid by the issuer to the acceptor, so it reduces the
+	// contract's effective price.
+	vc.PriceRatio = (vc.Price - vc.Reward) / sellValue
+	switch {
+	case vc.PriceRatio > 1+threshold:
+		vc.Mispriced = "above"
+	case vc.PriceRatio < 1-threshold:
+		vc.Mispriced = "below"
+	}
+}
+
+// Contracts returns a web handler function that lists a character's active
+// contracts. Item exchange contracts are valued at the station passed in the
+// station query parameter (Jita 4-4 by default) or the region passed in the
+// region parameter, and flagged if their price differs from market value by
+// more than the threshold parameter (a fraction; 0.2 by default). The method
+// and topPct parameters select how items are priced, as for ItemsMarketValue.
+// Courier contracts are listed separately along with the collateral at stake.
+func Contracts(sde evego.Database, localdb db.LocalDB, mkt evego.Market,
+	xmlAPI evego.XMLAPI, router evego.Router, sess server.Sessionizer) web.HandlerFunc {
+	return func(c web.C, w http.ResponseWriter, r *http.Request) {
+		s := sess.GetSession(&c, w, r)
+		myUserID := s.User
+		charID, err := strconv.Atoi(c.URLParams["charID"])
+		if err != nil {
+			http.Error(w, `{"status": "Error", "error": "Invalid character ID supplied."}`,
+				http.StatusBadRequest)
+			return
+		}
+		query := r.URL.Query()
+		threshold := defaultMispricedThreshold
+		if t := query.Get("threshold"); t != "" {
+			threshold, err = strconv.ParseFloat(t, 64)
+			if err != nil || threshold < 0 {
+				http.Error(w, `{"status": "Error", "error": "Invalid threshold supplied."}`,
+					http.StatusBadRequest)
+				return
+			}
+		}
+		scope, err := pricingLocation(sde, xmlAPI, router, r)
+		if err != nil {
+			http.Error(w, `{"status": "Error", "error": "Unable to identify location"}`,
+				http.StatusBadRequest)
+			return
+		}
+		pricing, err := requestPricing(r)
+		if err != nil {
+			http.Error(w, `{"status": "Error", "error": "Invalid pricing method"}`,
+				http.StatusBadRequest)
+			return
+		}
+
+		contracts, err := localdb.CharacterContracts(myUserID, charID)
+		if err != nil {
+			http.Error(w, `{"status": "Error", "error": "Unable to access database."}`,
+				http.StatusInternalServerError)
+			log.Printf("Error getting contracts with user %v, character %v: %v", myUserID, charID, err)
+			return
+		}
+
+		// Price every item in every contract in one go.
+		var toPrice []queryItem
+		seen := make(map[string]bool)
+		for _, contract := range contracts {
+			if contract.Type == xmlapi.ContractCourier {
+				continue
+			}
+			for _, item := range contract.Items {
+				if !seen[item.TypeName] {
+					seen[item.TypeName] = true
+					toPrice = append(toPrice, queryItem{Quantity: 1, ItemName: item.TypeName})
+				}
+			}
+		}
+		prices := getItemPrices(sde, mkt, &toPrice, scope, pricing)
+
+		names := make(map[int]string)
+		exchanges := make([]valuedContract, 0, len(contracts))
+		couriers := courierSummary{Contracts: []valuedContract{}}
+		for _, contract := range contracts {
+			vc := valuedContract{
+				Contract:     contract,
+				StartStation: stationName(sde, xmlAPI, names, contract.StartStationID),
+			}
+			if contract.Type == xmlapi.ContractCourier {
+				vc.EndStation = stationName(sde, xmlAPI, names, contract.EndStationID)
+				if contract.Status == xmlapi.ContractInProgress && contract.AcceptorID == charID {
+					couriers.Collateral += priceFloat(contract.Collateral)
+					couriers.Reward += priceFloat(contract.Reward)
+				}
+				if contract.IssuerID == charID {
+					couriers.IssuedCollateral += priceFloat(contract.Collateral)
+				}
+				couriers.Contracts = append(couriers.Contracts, vc)
+				continue
+			}
+			valueContract(&vc, *prices, threshold)
+			exchanges = append(exchanges, vc)
+		}
+
+		response := struct {
+			Status    string           `json:"status"`
+			Contracts []valuedContract `json:"contracts"`
+			Couriers  courierSummary   `json:"couriers"`
+		}{"OK", exchanges, couriers}
+		responseJSON, _ := json.Marshal(&response)
+		w.Write(responseJSON)
+	}
+}
@@ -22,6 +22,7 @@ import (
 	log "github.com/Sirupsen/logrus"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/backerman/evego"
 	"github.com/backerman/eveindy/pkg/db"
@@ -89,3 +90,72 @@ func UnusedSalvage(localdb db.LocalDB, sde evego.Database, sess server.Sessioniz
 
 	}
 }
+
+type industryJob struct {
+	db.IndustryJob
+
+	// Progress is the fraction of the job's duration that has elapsed, from 0
+	// to 1.
+	Progress float64 `json:"progress"`
+
+	// Completed is true iff the job has finished running (whether or not it
+	// has been delivered).
+	Completed bool `json:"completed"`
+}
+
+// jobProgress returns the fraction of a job's duration that has elapsed at
+// the passed time.
+func jobProgress(job *db.IndustryJob, now time.Time) float64 {
+	if !job.IsRunning() || !now.Before(job.EndDate) {
+		return 1.0
+	}
+	total := job.EndDate.Sub(job.StartDate)
+	if total <= 0 || now.Before(job.StartDate) {
+		return 0.0
+	}
+	return float64(now.Sub(job.StartDate)) / float64(total)
+}
+
+// IndustryJobs returns a web handler function that lists the industry jobs
+// installed by, or visible to, one of the user's characters.
+func IndustryJobs(localdb db.LocalDB, sess server.Sessionizer) web.HandlerFunc {
+	return func(c web.C, w http.ResponseWriter, r *http.Request) {
+		s := sess.GetSession(&c, w, r)
+		myUserID := s.User
+		charID, err := strconv.Atoi(c.URLParams["charID"])
+		if err != nil {
+			http.Error(w, `{"status": "Error", "error": "Invalid character ID supplied."}`,
+				http.StatusBadRequest)
+			return
+		}
+		jobs, err := localdb.CharacterIndustryJobs(myUserID, charID)
+		if err != nil {
+			http.Error(w, `{"status": "Error", "error": "Unable to access database."}`,
+				http.StatusInternalServerError)
+			log.Printf("Error accessing database with user %v, character %v: %v", myUserID, charID, err)
+			return
+		}
+		now := time.Now()
+		results := make([]industryJob, 0, len(jobs))
+		for i := range jobs {
+			job := &jobs[i]
+			progress := jobProgress(job, now)
+			results = append(results, industryJob{
+				IndustryJob: *job,
+				Progress:    progress,
+				Completed:   progress >= 1.0,
+			})
+		}
+		response := struct {
+			Jobs []industryJob `json:"jobs"`
+		}{results}
+		jobsJSON, err := json.Marshal(&response)
+		if err != nil {
+			http.Error(w, `{"status": "Error", "error": "Unable to marshal JSON."}`,
+				http.StatusInternalServerError)
+			log.Printf("Error marshalling JSON industry jobs with user %v, character %v: %v", myUserID, charID, err)
+			return
+		}
+		w.Write(jobsJSON)
+	}
+}
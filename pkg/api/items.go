@@ -0,0 +1,225 @@
+/*
+Copyright © 2014–6 Brad Ackerman.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package api
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/backerman/evego"
+	"github.com/backerman/eveindy/pkg/db"
+)
+
+// maxSuggestions is the number of close matches suggested for an item name
+// that can't be resolved.
+const maxSuggestions = 3
+
+// maxFuzzyLookups is the number of names in one request that are matched
+// against the index when they aren't exact; any more are simply unknown.
+const maxFuzzyLookups = 20
+
+// itemIndex holds the names of every marketable item, for resolving names
+// that don't exactly match one.
+type itemIndex struct {
+	// byLower maps lowercased names to the canonical ones.
+	byLower map[string]string
+	// lower is the lowercased names, sorted, for finding names by prefix.
+	lower []string
+	// byLength holds the lowercased names of each length in runes, so that
+	// only names of about the right length are compared when fuzzy matching.
+	byLength map[int][]string
+}
+
+// itemNameIndex is the index used by resolveItem; it's nil (and only exact
+// names and type IDs are resolved) until IndexItems is called.
+var itemNameIndex *itemIndex
+
+// IndexItems loads the names of the items in the local database so that item
+// lookups can ignore case and suggest close matches for unknown names.
+func IndexItems(localdb db.LocalDB) error {
+	names, err := localdb.ItemNames()
+	if err != nil {
+		return err
+	}
+	index := &itemIndex{
+		byLower:  make(map[string]string, len(names)),
+		lower:    make([]string, 0, len(names)),
+		byLength: make(map[int][]string),
+	}
+	for _, name := range names {
+		l := strings.ToLower(name)
+		index.byLower[l] = name
+		index.lower = append(index.lower, l)
+		length := utf8.RuneCountInString(l)
+		index.byLength[length] = append(index.byLength[length], l)
+	}
+	sort.Strings(index.lower)
+	itemNameIndex = index
+	log.Printf("Indexed %d item names", len(names))
+	return nil
+}
+
+// levenshtein returns the edit distance between two strings, by rune.
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(min(prev[j]+1, cur[j-1]+1), prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+// nameMatch is a candidate item name and its distance from the name sought.
+type nameMatch struct {
+	name     string
+	distance int
+}
+
+type byDistance []nameMatch
+
+func (m byDistance) Len() int      { return len(m) }
+func (m byDistance) Swap(i, j int) { m[i], m[j] = m[j], m[i] }
+func (m byDistance) Less(i, j int) bool {
+	if m[i].distance != m[j].distance {
+		return m[i].distance < m[j].distance
+	}
+	return m[i].name < m[j].name
+}
+
+// closeMatches returns the indexed names within a small edit distance of the
+// passed (lowercased) name, closest first. If there are none, names starting
+// with it are returned instead.
+func (idx *itemIndex) closeMatches(name string) []nameMatch {
+	length := utf8.RuneCountInString(name)
+	maxDistance := length / 3
+	if maxDistance < 2 {
+		maxDistance = 2
+	}
+	var matches byDistance
+	for l := length - maxDistance; l <= length+maxDistance; l++ {
+		for _, candidate := range idx.byLength[l] {
+			if d := levenshtein(name, candidate); d <= maxDistance {
+				matches = append(matches, nameMatch{idx.byLower[candidate], d})
+			}
+		}
+	}
+	if len(matches) == 0 {
+		for i := sort.SearchStrings(idx.lower, name); i < len(idx.lower); i++ {
+			candidate := idx.lower[i]
+			if !strings.HasPrefix(candidate, name) {
+				break
+			}
+			diff := utf8.RuneCountInString(candidate) - length
+			matches = append(matches, nameMatch{idx.byLower[candidate], diff})
+		}
+	}
+	sort.Sort(matches)
+	return matches
+}
+
+// autoCorrectDistance returns the largest edit distance at which a name of
+// the passed length is corrected to the closest item name without asking.
+func autoCorrectDistance(length int) int {
+	if length < 8 {
+		return 1
+	}
+	return 2
+}
+
+// unresolvedItem describes why an item name couldn't be resolved.
+type unresolvedItem struct {
+	Reason      string
+	Suggestions []string
+}
+
+// itemResolver resolves the item names in one request.
+type itemResolver struct {
+	sde evego.Database
+	// fuzzyLeft is the number of fuzzy lookups the request has left.
+	fuzzyLeft int
+}
+
+// newItemResolver returns a resolver for the names in a new request.
+func newItemResolver(sde evego.Database) *itemResolver {
+	return &itemResolver{sde: sde, fuzzyLeft: maxFuzzyLookups}
+}
+
+// resolveItem finds the item referred to by a line of user input: a type ID,
+// an item's name in any case, or a name close enough to exactly one item's
+// that it's probably a typo. If it can't be resolved, the returned
+// unresolvedItem says why, and suggests names it might have meant.
+func (r *itemResolver) resolveItem(name string) (*evego.Item, *unresolvedItem) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, &unresolvedItem{Reason: "No item name given"}
+	}
+	if typeID, err := strconv.Atoi(name); err == nil {
+		item, err := r.sde.ItemForID(typeID)
+		if err != nil {
+			return nil, &unresolvedItem{Reason: "Unknown type ID"}
+		}
+		return item, nil
+	}
+	item, err := r.sde.ItemForName(name)
+	if err == nil {
+		return item, nil
+	}
+	if itemNameIndex == nil {
+		return nil, &unresolvedItem{Reason: "Unknown item"}
+	}
+	lower := strings.ToLower(name)
+	if canonical, found := itemNameIndex.byLower[lower]; found {
+		item, err = r.sde.ItemForName(canonical)
+		if err == nil {
+			return item, nil
+		}
+	}
+	if r.fuzzyLeft <= 0 {
+		return nil, &unresolvedItem{Reason: "Unknown item"}
+	}
+	r.fuzzyLeft--
+	matches := itemNameIndex.closeMatches(lower)
+	if len(matches) > 0 && matches[0].distance <= autoCorrectDistance(utf8.RuneCountInString(lower)) &&
+		(len(matches) == 1 || matches[1].distance > matches[0].distance) {
+		item, err = r.sde.ItemForName(matches[0].name)
+		if err == nil {
+			return item, nil
+		}
+	}
+	unresolved := &unresolvedItem{Reason: "Unknown item"}
+	for i := 0; i < len(matches) && i < maxSuggestions; i++ {
+		unresolved.Suggestions = append(unresolved.Suggestions, matches[i].name)
+	}
+	return nil, unresolved
+}
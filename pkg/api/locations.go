@@ -109,7 +109,7 @@ func stationFromAPI(db evego.Database, s *evego.Station, isOutpost bool) station
 		stn.Outpost = true
 		// Reprocessing efficiency for outposts isn't provided in the SDE,
 		// so we default to a basic station.
-		stn.ReprocessingEfficiency = 0.50
+		stn.ReprocessingEfficiency = outpostBaseYield
 	}
 	return stn
 }
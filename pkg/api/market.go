@@ -25,9 +25,11 @@ import (
 	"mime"
 	"net/http"
 	"strconv"
-	"time"
+	"sync"
 
 	"github.com/backerman/evego"
+	"github.com/backerman/eveindy/pkg/marketstats"
+	"github.com/backerman/eveindy/pkg/server"
 	"github.com/zenazn/goji/web"
 )
 
@@ -60,6 +62,56 @@ type responseItem struct {
 	BuyInfo orderInfo `json:"buyInfo"`
 	// Details of the best sell order
 	SellInfo orderInfo `json:"sellInfo"`
+	// The quantity requested.
+	Quantity int `json:"quantity"`
+	// What selling the requested quantity to buy orders would fetch.
+	SellFill *depthFill `json:"sellFill,omitempty"`
+	// What buying the requested quantity from sell orders would cost.
+	BuyFill *depthFill `json:"buyFill,omitempty"`
+	// Statistics over the orders that survived filtering.
+	Stats marketstats.Summary `json:"stats"`
+	// The number of orders dropped as outliers or for having a minimum
+	// quantity larger than that requested.
+	FilteredOrders int `json:"filteredOrders"`
+	// The buy and sell prices per unit according to the selected pricing
+	// method.
+	Method    marketstats.PriceMethod `json:"method"`
+	BuyPrice  priceFloat              `json:"buyPrice"`
+	SellPrice priceFloat              `json:"sellPrice"`
+	// Error explains why this item couldn't be priced, if it couldn't, and
+	// Suggestions are item names that might have been meant instead.
+	Error       string   `json:"error,omitempty"`
+	Suggestions []string `json:"suggestions,omitempty"`
+	// MatchedFrom is the name as requested, if it had to be corrected.
+	MatchedFrom string `json:"matchedFrom,omitempty"`
+}
+
+// unmatchedLine is a line of a valuation request that couldn't be priced.
+type unmatchedLine struct {
+	Line        string   `json:"line"`
+	Quantity    int      `json:"quantity"`
+	Reason      string   `json:"reason"`
+	Suggestions []string `json:"suggestions,omitempty"`
+}
+
+// splitUnmatched separates the items returned by getItemPrices that couldn't
+// be priced from those that were.
+func splitUnmatched(prices *map[string]responseItem) (map[string]responseItem, []unmatchedLine) {
+	priced := make(map[string]responseItem)
+	unmatched := []unmatchedLine{}
+	for name, item := range *prices {
+		if item.Error == "" {
+			priced[name] = item
+			continue
+		}
+		unmatched = append(unmatched, unmatchedLine{
+			Line:        name,
+			Quantity:    item.Quantity,
+			Reason:      item.Error,
+			Suggestions: item.Suggestions,
+		})
+	}
+	return priced, unmatched
 }
 
 type orderInfo struct {
@@ -104,15 +156,23 @@ func makeOrderInfo(db evego.Database, order *evego.Order) orderInfo {
 	}
 }
 
-// summarizeOrders takes as input the orders for a given type in a region/station
-// and returns the corresponding responseItem struct filled out.
-func summarizeOrders(db evego.Database, orders []evego.Order, dbItem *evego.Item) responseItem {
+// summarizeOrders takes as input the orders for a given type within a market
+// scope and returns the corresponding responseItem struct filled out,
+// including the cost of filling the requested quantity against the order
+// book. Outliers and orders that can't accept the requested quantity are
+// ignored.
+func summarizeOrders(db evego.Database, orders []evego.Order, dbItem *evego.Item,
+	requested int, pricing pricingOptions) responseItem {
 	var (
 		quantity          int
 		bestBuy, bestSell float64
 		buyInfo, sellInfo orderInfo
 	)
 
+	orders, filtered := marketstats.Filter(orders, requested)
+	stats := marketstats.Summarize(orders, pricing.TopPercent)
+	buyPrice, sellPrice := stats.Prices(pricing.Method)
+
 	for _, ord := range orders {
 		if ord.Type == evego.Buy && ord.Price > bestBuy {
 			bestBuy = ord.Price
@@ -133,96 +193,195 @@ func summarizeOrders(db evego.Database, orders []evego.Order, dbItem *evego.Item
 		BuyInfo:           buyInfo,
 		BestSell:          priceFloat(bestSell),
 		SellInfo:          sellInfo,
+		Quantity:          requested,
+		SellFill:          fillQuantity(db, orders, evego.Buy, requested),
+		BuyFill:           fillQuantity(db, orders, evego.Sell, requested),
+		Stats:             stats,
+		FilteredOrders:    filtered,
+		Method:            pricing.Method,
+		BuyPrice:          priceFloat(buyPrice),
+		SellPrice:         priceFloat(sellPrice),
 	}
 	return result
 }
 
+// priceLookup is one item whose orders are being looked up by getItemPrices.
+type priceLookup struct {
+	item        *evego.Item
+	quantity    int
+	matchedFrom string
+	result      responseItem
+}
+
+// getItemPrices looks up the market for each of the requested items within
+// the passed scope. Items may be given by type ID or by a name that's only
+// close to the real one (see itemResolver). Lookups are done in parallel on
+// the global thread pool, and an item listed more than once is looked up once
+// with the total quantity. Items that can't be priced are returned, under the
+// name requested, with their Error field set rather than failing the whole
+// request.
 func getItemPrices(
 	db evego.Database,
 	mkt evego.Market,
 	req *[]queryItem,
-	station *evego.Station,
-	loc string) (*map[string]responseItem, error) {
+	scope *marketScope,
+	pricing pricingOptions) *map[string]responseItem {
 	respItems := make(map[string]responseItem)
+	lookups := make(map[int]*priceLookup)
+	resolver := newItemResolver(db)
 	for _, i := range *req {
-		dbItem, err := db.ItemForName(i.ItemName)
-		if err != nil {
+		dbItem, unresolved := resolver.resolveItem(i.ItemName)
+		if unresolved != nil {
+			prev := respItems[i.ItemName]
+			respItems[i.ItemName] = responseItem{
+				ItemName:    i.ItemName,
+				Quantity:    prev.Quantity + i.Quantity,
+				Error:       unresolved.Reason,
+				Suggestions: unresolved.Suggestions,
+			}
 			continue
 		}
-		var (
-			item   responseItem
-			orders *[]evego.Order
-		)
-		if station != nil {
-			orders, err = mkt.OrdersInStation(dbItem, station)
-		} else {
-			orders, err = mkt.OrdersForItem(dbItem, loc, evego.AllOrders)
+		lookup, found := lookups[dbItem.ID]
+		if !found {
+			lookup = &priceLookup{item: dbItem}
+			lookups[dbItem.ID] = lookup
 		}
-		if err != nil {
-			return nil, fmt.Errorf("Unable to retrieve order information for %v: %v", dbItem.Name, err)
+		if i.ItemName != dbItem.Name && lookup.matchedFrom == "" {
+			lookup.matchedFrom = i.ItemName
 		}
-		item = summarizeOrders(db, *orders, dbItem)
-		respItems[item.ItemName] = item
+		lookup.quantity += i.Quantity
 	}
-	return &respItems, nil
+
+	var wg sync.WaitGroup
+	for _, lookup := range lookups {
+		wg.Add(1)
+		l := lookup
+		server.Submit(func() {
+			defer wg.Done()
+			orders, err := scope.orders(mkt, l.item)
+			if err != nil {
+				log.Printf("Unable to retrieve order information for %v: %v", l.item.Name, err)
+				l.result = responseItem{
+					ItemID:   l.item.ID,
+					ItemName: l.item.Name,
+					Quantity: l.quantity,
+					Error:    "Unable to retrieve order information",
+				}
+				return
+			}
+			l.result = summarizeOrders(db, orders, l.item, l.quantity, pricing)
+		})
+	}
+	wg.Wait()
+
+	for _, l := range lookups {
+		l.result.MatchedFrom = l.matchedFrom
+		respItems[l.result.ItemName] = l.result
+	}
+	return &respItems
+}
+
+// findStation returns the station or outpost with the passed ID.
+func findStation(db evego.Database, xmlAPI evego.XMLAPI, stationID int) (*evego.Station, error) {
+	return server.FindStation(db, xmlAPI, stationID)
+}
+
+// stationScopeForID returns the market scope of the station or outpost with
+// the passed ID.
+func stationScopeForID(db evego.Database, xmlAPI evego.XMLAPI, router evego.Router, stationID int) (*marketScope, error) {
+	station, err := findStation(db, xmlAPI, stationID)
+	if err != nil {
+		return nil, err
+	}
+	return stationScope(db, router, station)
+}
+
+// readQueryItems parses a JSON array of items and their quantities from a
+// request body. If it's unable to, it sends an error response and returns
+// false.
+func readQueryItems(w http.ResponseWriter, r *http.Request) ([]queryItem, bool) {
+	contentType := r.Header.Get("Content-Type")
+	contentType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		http.Error(w, `{"status": "Error", "error": "Bad request content type"}`,
+			http.StatusBadRequest)
+		return nil, false
+	}
+	if contentType != "application/json" {
+		http.Error(w, `{"status": "Error", "error": "Request must be of type application/json"}`,
+			http.StatusUnsupportedMediaType)
+		return nil, false
+	}
+	reqBody, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, `{"status": "Error", "error": "Unable to process request body"}`,
+			http.StatusBadRequest)
+		return nil, false
+	}
+	var req []queryItem
+	err = json.Unmarshal(reqBody, &req)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Unable to process request JSON: %v", err), http.StatusBadRequest)
+		w.Write([]byte(`{"status": "Error"}`))
+		return nil, false
+	}
+	return req, true
 }
 
 // ItemsMarketValue returns a handler that takes as input a JSON
-// array of items and their quantities, plus a specified station
-// or region, and computes the items' value.
+// array of items and their quantities, plus a specified station, system
+// or region, and computes the items' value. The method and topPct query
+// parameters select how the buyPrice and sellPrice of each item are derived
+// from its order book.
 //
-// FIXME: Should return all buy orders within range for the queried system.
-func ItemsMarketValue(db evego.Database, mkt evego.Market, xmlAPI evego.XMLAPI) web.HandlerFunc {
+// A region query covers every order in the region and a system query the
+// orders placed in that system. A station query covers the station's sell
+// orders and every buy order whose range reaches it, wherever it was placed;
+// the router is used to find out which do. The response names the scope
+// that was applied, and lists the lines that couldn't be priced separately
+// from the items that were.
+func ItemsMarketValue(db evego.Database, mkt evego.Market, xmlAPI evego.XMLAPI, router evego.Router) web.HandlerFunc {
 	return func(c web.C, w http.ResponseWriter, r *http.Request) {
-		contentType := r.Header.Get("Content-Type")
-		contentType, _, err := mime.ParseMediaType(contentType)
-		if err != nil {
-			http.Error(w, `{"status": "Error", "error": "Bad request content type"}`,
-				http.StatusBadRequest)
-			return
-		}
-		if contentType != "application/json" {
-			http.Error(w, `{"status": "Error", "error": "Request must be of type application/json"}`,
-				http.StatusUnsupportedMediaType)
+		req, ok := readQueryItems(w, r)
+		if !ok {
 			return
 		}
-		reqBody, err := ioutil.ReadAll(r.Body)
+		pricing, err := requestPricing(r)
 		if err != nil {
-			http.Error(w, `{"status": "Error", "error": "Unable to process request body"}`,
+			http.Error(w, `{"status": "Error", "error": "Invalid pricing method"}`,
 				http.StatusBadRequest)
 			return
 		}
-		var req []queryItem
-		err = json.Unmarshal(reqBody, &req)
-		if err != nil {
-			http.Error(w, fmt.Sprintf("Unable to process request JSON: %v", err), http.StatusBadRequest)
-			w.Write([]byte(`{"status": "Error"}`))
-			return
-		}
-		loc := c.URLParams["location"]
-		stationIDStr, isStation := c.URLParams["id"]
-		var station *evego.Station
-		if isStation {
-			// Get station / outpost object.
-			stationID, _ := strconv.Atoi(stationIDStr)
-			station, err = db.StationForID(stationID)
-			if err != nil {
-				// Not a station; should be an outpost.
-				station, err = xmlAPI.OutpostForID(stationID)
-				if err != nil {
-					http.Error(w, `{"status": "Error", "error": "Unable to identify location"}`,
-						http.StatusBadRequest)
-					return
-				}
+		var scope *marketScope
+		if stationIDStr, isStation := c.URLParams["id"]; isStation {
+			var stationID int
+			stationID, err = strconv.Atoi(stationIDStr)
+			if err == nil {
+				scope, err = stationScopeForID(db, xmlAPI, router, stationID)
 			}
+		} else if system, isSystem := c.URLParams["system"]; isSystem {
+			scope, err = systemScope(db, system)
+		} else {
+			scope, err = regionScope(db, c.URLParams["region"])
 		}
-		respItems, err := getItemPrices(db, mkt, &req, station, loc)
 		if err != nil {
-			http.Error(w, `{"status": "Error", "error": "Unable to retrieve order information"}`,
+			http.Error(w, `{"status": "Error", "error": "Unable to identify location"}`,
 				http.StatusBadRequest)
 			return
 		}
-		respJSON, _ := json.Marshal(respItems)
+		priced, unmatched := splitUnmatched(getItemPrices(db, mkt, &req, scope, pricing))
+		response := struct {
+			Status    string                  `json:"status"`
+			Scope     *marketScope            `json:"scope"`
+			Items     map[string]responseItem `json:"items"`
+			Unmatched []unmatchedLine         `json:"unmatched"`
+		}{
+			Status:    "OK",
+			Scope:     scope,
+			Items:     priced,
+			Unmatched: unmatched,
+		}
+		respJSON, _ := json.Marshal(response)
 		w.Write(respJSON)
 	}
 }
@@ -234,48 +393,3 @@ func min(a, b int) int {
 	}
 	return b
 }
-
-const reprocessCacheKey = "reprocess-output-values"
-const reprocessCacheTTL = time.Hour
-
-// ReprocessOutputValues returns a web handler function that generates a list of
-// possible output from reprocessing, along with the Jita sell and buy price of each.
-func ReprocessOutputValues(db evego.Database, mkt evego.Market, xmlAPI evego.XMLAPI, cache evego.Cache) web.HandlerFunc {
-	tickerboard := []queryItem{
-		{Quantity: 1, ItemName: "Tritanium"},
-		{Quantity: 1, ItemName: "Pyerite"},
-		{Quantity: 1, ItemName: "Mexallon"},
-		{Quantity: 1, ItemName: "Isogen"},
-		{Quantity: 1, ItemName: "Nocxium"},
-		{Quantity: 1, ItemName: "Megacyte"},
-		{Quantity: 1, ItemName: "Zydrine"},
-		{Quantity: 1, ItemName: "Megacyte"},
-	}
-	return func(c web.C, w http.ResponseWriter, r *http.Request) {
-		// Check cached and use that instead if it's available.
-		cached, found := cache.Get(reprocessCacheKey)
-		if found {
-			w.Write(cached)
-			return
-		}
-		jita, err := db.StationForID(60003760) // Jita IV - Moon 4 - Caldari Navy Assembly Plant
-		if err != nil {
-			http.Error(w, `{"status": "Error", "error": "Unable to retrieve ticker prices"}`,
-				http.StatusInternalServerError)
-			log.Printf("Error looking up Jita station info (???): %v", err)
-			return
-		}
-		results, err := getItemPrices(db, mkt, &tickerboard, jita, "")
-		if err != nil {
-			http.Error(w, `{"status": "Error", "error": "Unable to retrieve ticker prices"}`,
-				http.StatusInternalServerError)
-			log.Printf("Error getting Jita item prices: %v", err)
-			return
-		}
-
-		resultsJSON, err := json.Marshal(results)
-		// Write output to cache as well.
-		cache.Put(reprocessCacheKey, resultsJSON, time.Now().Add(reprocessCacheTTL))
-		w.Write(resultsJSON)
-	}
-}
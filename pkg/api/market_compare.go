@@ -0,0 +1,222 @@
+/*
+Copyright © 2014–6 Brad Ackerman.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/backerman/evego"
+	"github.com/zenazn/goji/web"
+)
+
+// tradeHubs are the stations compared by default: Jita 4-4, Amarr VIII,
+// Dodixie IX-20, Rens VI-8, and Hek VIII-12.
+var tradeHubs = []int{60003760, 60008494, 60011866, 60004588, 60005686}
+
+type hub struct {
+	StationID   int    `json:"stationID"`
+	StationName string `json:"stationName"`
+}
+
+// hubValue is the value of a quantity of items at one hub.
+type hubValue struct {
+	BuyPrice  priceFloat `json:"buyPrice"`
+	SellPrice priceFloat `json:"sellPrice"`
+	BuyValue  priceFloat `json:"buyValue"`
+	SellValue priceFloat `json:"sellValue"`
+	// SellShortfall is how much less selling here (to buy orders) would get
+	// than selling at the best hub, in ISK and as a percentage.
+	SellShortfall    priceFloat `json:"sellShortfall"`
+	SellShortfallPct float64    `json:"sellShortfallPct"`
+	// BuyPremium is how much more buying here (from sell orders) would cost
+	// than buying at the best hub, in ISK and as a percentage.
+	BuyPremium    priceFloat `json:"buyPremium"`
+	BuyPremiumPct float64    `json:"buyPremiumPct"`
+}
+
+type comparedItem struct {
+	ItemID   int    `json:"itemID"`
+	ItemName string `json:"itemName"`
+	Quantity int    `json:"quantity"`
+	// Values is indexed by station ID.
+	Values map[int]*hubValue `json:"values"`
+	// BestSellHub is the station with the highest buy orders; BestBuyHub is
+	// the one with the lowest sell orders. Zero if no hub has any.
+	BestSellHub int `json:"bestSellHub"`
+	BestBuyHub  int `json:"bestBuyHub"`
+}
+
+// parseStationList parses a comma-separated list of station IDs.
+func parseStationList(list string) ([]int, error) {
+	var stations []int
+	for _, s := range strings.Split(list, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		id, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, err
+		}
+		stations = append(stations, id)
+	}
+	return stations, nil
+}
+
+// pctOf returns diff as a percentage of base, or zero if base is zero.
+func pctOf(diff, base priceFloat) float64 {
+	if base == 0 {
+		return 0
+	}
+	return float64(diff/base) * 100.0
+}
+
+// compareHubs picks the best hubs for selling and buying and fills in how far
+// behind each of the others is.
+func compareHubs(values map[int]*hubValue) (bestSellHub, bestBuyHub int) {
+	var bestSell, bestBuy *hubValue
+	for id, v := range values {
+		if v.BuyValue > 0 && (bestSell == nil || v.BuyValue > bestSell.BuyValue) {
+			bestSell, bestSellHub = v, id
+		}
+		if v.SellValue > 0 && (bestBuy == nil || v.SellValue < bestBuy.SellValue) {
+			bestBuy, bestBuyHub = v, id
+		}
+	}
+	for _, v := range values {
+		if bestSell != nil && v.BuyValue > 0 {
+			v.SellShortfall = bestSell.BuyValue - v.BuyValue
+			v.SellShortfallPct = pctOf(v.SellShortfall, bestSell.BuyValue)
+		}
+		if bestBuy != nil && v.SellValue > 0 {
+			v.BuyPremium = v.SellValue - bestBuy.SellValue
+			v.BuyPremiumPct = pctOf(v.BuyPremium, bestBuy.SellValue)
+		}
+	}
+	return
+}
+
+// CompareHubs returns a web handler function that takes the same JSON array
+// of items and quantities as ItemsMarketValue and values them at each of the
+// stations in the comma-separated stations query parameter (by default, the
+// five main trade hubs). The method and topPct query parameters select how
+// items are priced, as for ItemsMarketValue, and each hub's buy orders include
+// those placed nearby whose range reaches it.
+func CompareHubs(db evego.Database, mkt evego.Market, xmlAPI evego.XMLAPI, router evego.Router) web.HandlerFunc {
+	return func(c web.C, w http.ResponseWriter, r *http.Request) {
+		req, ok := readQueryItems(w, r)
+		if !ok {
+			return
+		}
+		pricing, err := requestPricing(r)
+		if err != nil {
+			http.Error(w, `{"status": "Error", "error": "Invalid pricing method"}`,
+				http.StatusBadRequest)
+			return
+		}
+		stationIDs := tradeHubs
+		if list := r.URL.Query().Get("stations"); list != "" {
+			stationIDs, err = parseStationList(list)
+			if err != nil || len(stationIDs) == 0 {
+				http.Error(w, `{"status": "Error", "error": "Invalid station list supplied."}`,
+					http.StatusBadRequest)
+				return
+			}
+		}
+
+		// Combine repeated items and set aside those we don't recognize.
+		items := make(map[string]*comparedItem)
+		unmatched := []unmatchedLine{}
+		resolver := newItemResolver(db)
+		for _, i := range req {
+			dbItem, unresolved := resolver.resolveItem(i.ItemName)
+			if unresolved != nil {
+				unmatched = append(unmatched, unmatchedLine{
+					Line:        i.ItemName,
+					Quantity:    i.Quantity,
+					Reason:      unresolved.Reason,
+					Suggestions: unresolved.Suggestions,
+				})
+				continue
+			}
+			item, found := items[dbItem.Name]
+			if !found {
+				item = &comparedItem{
+					ItemID:   dbItem.ID,
+					ItemName: dbItem.Name,
+					Values:   make(map[int]*hubValue),
+				}
+				items[dbItem.Name] = item
+			}
+			item.Quantity += i.Quantity
+		}
+		// Only resolve names once, rather than at every hub.
+		resolved := make([]queryItem, 0, len(items))
+		for name, item := range items {
+			resolved = append(resolved, queryItem{Quantity: item.Quantity, ItemName: name})
+		}
+
+		hubs := make([]hub, 0, len(stationIDs))
+		totals := make(map[int]*hubValue)
+		for _, stationID := range stationIDs {
+			scope, err := stationScopeForID(db, xmlAPI, router, stationID)
+			if err != nil {
+				http.Error(w, `{"status": "Error", "error": "Unable to identify location"}`,
+					http.StatusBadRequest)
+				return
+			}
+			hubs = append(hubs, hub{scope.ID, scope.Name})
+			prices := getItemPrices(db, mkt, &resolved, scope, pricing)
+			total := &hubValue{}
+			totals[stationID] = total
+			for name, item := range items {
+				price := (*prices)[name]
+				qty := priceFloat(item.Quantity)
+				v := &hubValue{
+					BuyPrice:  price.BuyPrice,
+					SellPrice: price.SellPrice,
+					BuyValue:  price.BuyPrice * qty,
+					SellValue: price.SellPrice * qty,
+				}
+				item.Values[stationID] = v
+				total.BuyValue += v.BuyValue
+				total.SellValue += v.SellValue
+			}
+		}
+
+		for _, item := range items {
+			item.BestSellHub, item.BestBuyHub = compareHubs(item.Values)
+		}
+		bestSellHub, bestBuyHub := compareHubs(totals)
+
+		response := struct {
+			Status      string                   `json:"status"`
+			Hubs        []hub                    `json:"hubs"`
+			Items       map[string]*comparedItem `json:"items"`
+			Unmatched   []unmatchedLine          `json:"unmatched"`
+			Totals      map[int]*hubValue        `json:"totals"`
+			BestSellHub int                      `json:"bestSellHub"`
+			BestBuyHub  int                      `json:"bestBuyHub"`
+		}{"OK", hubs, items, unmatched, totals, bestSellHub, bestBuyHub}
+		responseJSON, _ := json.Marshal(&response)
+		w.Write(responseJSON)
+	}
+}
@@ -0,0 +1,109 @@
+/*
+Copyright © 2014–6 Brad Ackerman.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package api
+
+import (
+	"sort"
+
+	"github.com/backerman/evego"
+)
+
+// filledOrder is part of an order used to fill a quantity.
+type filledOrder struct {
+	Price    priceFloat `json:"price"`
+	Quantity int        `json:"quantity"`
+	Info     orderInfo  `json:"info"`
+}
+
+// depthFill describes what it would take to buy or sell a quantity of an item
+// against the order book, rather than just at the best order.
+type depthFill struct {
+	// AveragePrice is the volume-weighted price per unit of the filled
+	// quantity.
+	AveragePrice priceFloat `json:"averagePrice"`
+	// WorstPrice is the price of the last order touched.
+	WorstPrice priceFloat `json:"worstPrice"`
+	// QuantityFilled is how much of the requested quantity the book could
+	// absorb, and FillRatio the same as a fraction of the request.
+	QuantityFilled int           `json:"quantityFilled"`
+	FillRatio      float64       `json:"fillRatio"`
+	Orders         []filledOrder `json:"orders"`
+}
+
+// sortedOrders sorts orders by price, best first: ascending for sell orders
+// and descending for buy orders.
+type sortedOrders []*evego.Order
+
+func (o sortedOrders) Len() int      { return len(o) }
+func (o sortedOrders) Swap(i, j int) { o[i], o[j] = o[j], o[i] }
+func (o sortedOrders) Less(i, j int) bool {
+	if o[i].Type == evego.Buy {
+		return o[i].Price > o[j].Price
+	}
+	return o[i].Price < o[j].Price
+}
+
+// fillQuantity walks the order book of the given type, best price first, to
+// fill quantity units. The orders should already be limited to those usable
+// in the market scope being priced. Buy orders whose minimum quantity exceeds
+// what's left to sell are skipped.
+func fillQuantity(db evego.Database, orders []evego.Order, orderType evego.OrderType,
+	quantity int) *depthFill {
+	if quantity <= 0 {
+		return nil
+	}
+	book := make(sortedOrders, 0, len(orders))
+	for i := range orders {
+		ord := &orders[i]
+		if ord.Type != orderType {
+			continue
+		}
+		book = append(book, ord)
+	}
+	sort.Sort(book)
+
+	fill := &depthFill{Orders: []filledOrder{}}
+	var total float64
+	remaining := quantity
+	for _, ord := range book {
+		if remaining == 0 {
+			break
+		}
+		if orderType == evego.Buy && ord.MinQuantity > remaining {
+			continue
+		}
+		qty := min(remaining, ord.Quantity)
+		if qty <= 0 {
+			continue
+		}
+		remaining -= qty
+		total += float64(qty) * ord.Price
+		fill.WorstPrice = priceFloat(ord.Price)
+		fill.Orders = append(fill.Orders, filledOrder{
+			Price:    priceFloat(ord.Price),
+			Quantity: qty,
+			Info:     makeOrderInfo(db, ord),
+		})
+	}
+	fill.QuantityFilled = quantity - remaining
+	fill.FillRatio = float64(fill.QuantityFilled) / float64(quantity)
+	if fill.QuantityFilled > 0 {
+		fill.AveragePrice = priceFloat(total / float64(fill.QuantityFilled))
+	}
+	return fill
+}
@@ -0,0 +1,109 @@
+/*
+Copyright © 2014–6 Brad Ackerman.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package api
+
+import (
+	"testing"
+
+	"github.com/backerman/evego"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// depthSDE answers the solar system lookups made when describing orders.
+type depthSDE struct {
+	evego.Database
+}
+
+func (depthSDE) SolarSystemForID(systemID int) (*evego.SolarSystem, error) {
+	return &evego.SolarSystem{ID: systemID, Name: "Jita", Security: 0.946}, nil
+}
+
+func depthOrder(t evego.OrderType, price float64, quantity, minQuantity int) evego.Order {
+	return evego.Order{
+		Type:        t,
+		Price:       price,
+		Quantity:    quantity,
+		MinQuantity: minQuantity,
+		Station:     &evego.Station{ID: 60003760, SystemID: 30000142},
+		JumpRange:   evego.BuyStation,
+	}
+}
+
+func TestFillQuantity(t *testing.T) {
+	Convey("Verify filling a quantity against the order book", t, func() {
+		sde := depthSDE{}
+
+		Convey("Nothing is filled for no quantity", func() {
+			orders := []evego.Order{depthOrder(evego.Sell, 10, 4, 1)}
+			So(fillQuantity(sde, orders, evego.Sell, 0), ShouldBeNil)
+		})
+
+		Convey("Orders are used best first, the last one only in part", func() {
+			orders := []evego.Order{
+				depthOrder(evego.Sell, 12, 10, 1),
+				depthOrder(evego.Sell, 10, 4, 1),
+				depthOrder(evego.Buy, 11, 100, 1),
+			}
+			fill := fillQuantity(sde, orders, evego.Sell, 10)
+			So(fill.QuantityFilled, ShouldEqual, 10)
+			So(fill.FillRatio, ShouldEqual, 1.0)
+			So(fill.WorstPrice, ShouldEqual, priceFloat(12))
+			So(float64(fill.AveragePrice), ShouldAlmostEqual, 11.2)
+			So(fill.Orders, ShouldHaveLength, 2)
+			So(fill.Orders[0].Quantity, ShouldEqual, 4)
+			So(fill.Orders[1].Quantity, ShouldEqual, 6)
+			So(fill.Orders[1].Info.Station.SystemName, ShouldEqual, "Jita")
+		})
+
+		Convey("A book too thin for the quantity is partly filled", func() {
+			orders := []evego.Order{
+				depthOrder(evego.Sell, 10, 4, 1),
+				depthOrder(evego.Sell, 12, 3, 1),
+			}
+			fill := fillQuantity(sde, orders, evego.Sell, 10)
+			So(fill.QuantityFilled, ShouldEqual, 7)
+			So(fill.FillRatio, ShouldAlmostEqual, 0.7)
+			So(fill.WorstPrice, ShouldEqual, priceFloat(12))
+			So(float64(fill.AveragePrice), ShouldAlmostEqual, 76.0/7.0)
+		})
+
+		Convey("Buy orders with a minimum above what's left are skipped", func() {
+			orders := []evego.Order{
+				depthOrder(evego.Buy, 100, 3, 1),
+				depthOrder(evego.Buy, 90, 5, 5),
+				depthOrder(evego.Buy, 80, 5, 1),
+			}
+			fill := fillQuantity(sde, orders, evego.Buy, 7)
+			So(fill.QuantityFilled, ShouldEqual, 7)
+			So(fill.Orders, ShouldHaveLength, 2)
+			So(fill.Orders[1].Price, ShouldEqual, priceFloat(80))
+			So(fill.WorstPrice, ShouldEqual, priceFloat(80))
+			So(float64(fill.AveragePrice), ShouldAlmostEqual, 620.0/7.0)
+		})
+
+		Convey("An empty side fills nothing", func() {
+			orders := []evego.Order{depthOrder(evego.Buy, 100, 3, 1)}
+			fill := fillQuantity(sde, orders, evego.Sell, 5)
+			So(fill.QuantityFilled, ShouldEqual, 0)
+			So(fill.FillRatio, ShouldEqual, 0.0)
+			So(fill.WorstPrice, ShouldEqual, priceFloat(0))
+			So(fill.Orders, ShouldBeEmpty)
+		})
+	})
+}
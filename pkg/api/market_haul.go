@@ -0,0 +1,381 @@
+/*
+Copyright © 2014–6 Brad Ackerman.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package api
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/backerman/evego"
+	"github.com/backerman/eveindy/pkg/db"
+	"github.com/backerman/eveindy/pkg/server"
+	"github.com/zenazn/goji/web"
+)
+
+// defaultHaulRows is the number of items returned by the hauling search
+// unless more are requested.
+const defaultHaulRows = 50
+
+// haulLoad is a quantity of an item bought from sell orders at one station
+// and sold into buy orders at another.
+type haulLoad struct {
+	Units int        `json:"units"`
+	Cost  priceFloat `json:"cost"`
+	// Revenue is after sales tax.
+	Revenue priceFloat `json:"revenue"`
+	Profit  priceFloat `json:"profit"`
+	Volume  float64    `json:"volume"`
+}
+
+// haulRow is the result of hauling one item between the stations.
+type haulRow struct {
+	ItemID     int     `json:"itemID"`
+	ItemName   string  `json:"itemName"`
+	UnitVolume float64 `json:"unitVolume"`
+	// Trip is the most profitable load that fits in the cargo hold, and
+	// ProfitPerM3 its profit per cubic metre of cargo.
+	Trip        haulLoad   `json:"trip"`
+	ProfitPerM3 priceFloat `json:"profitPerM3"`
+	// Total is everything that can be hauled at a profit before the order
+	// books run dry, and Trips the number of trips needed to haul it.
+	Total haulLoad `json:"total"`
+	Trips int      `json:"trips"`
+}
+
+type haulRows []haulRow
+
+func (h haulRows) Len() int      { return len(h) }
+func (h haulRows) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+// byTripProfit sorts rows by descending profit per trip.
+type byTripProfit struct{ haulRows }
+
+func (h byTripProfit) Less(i, j int) bool {
+	return h.haulRows[i].Trip.Profit > h.haulRows[j].Trip.Profit
+}
+
+// byProfitPerM3 sorts rows by descending profit per cubic metre.
+type byProfitPerM3 struct{ haulRows }
+
+func (h byProfitPerM3) Less(i, j int) bool {
+	return h.haulRows[i].ProfitPerM3 > h.haulRows[j].ProfitPerM3
+}
+
+// haulRoute describes the route between the two stations. Jump counts are -1
+// if the router couldn't find a route.
+type haulRoute struct {
+	Jumps int `json:"jumps"`
+	// HighSecJumps is the length of the route preferring high-security
+	// space; if it's longer than Jumps, the shortest route leaves high-sec.
+	HighSecJumps        int     `json:"highSecJumps"`
+	SourceSecurity      float64 `json:"sourceSecurity"`
+	DestinationSecurity float64 `json:"destinationSecurity"`
+	// LowestSecurity is the security of the least secure system on the
+	// shortest route (the safest, if there are several), ends included.
+	LowestSecurity       float64 `json:"lowestSecurity"`
+	LowestSecuritySystem string  `json:"lowestSecuritySystem"`
+}
+
+// routeBetween looks up the route between two stations.
+func routeBetween(sde evego.Database, router evego.Router, from, to *evego.Station) (haulRoute, error) {
+	route := haulRoute{Jumps: -1, HighSecJumps: -1}
+	fromSystem, err := sde.SolarSystemForID(from.SystemID)
+	if err != nil {
+		return route, err
+	}
+	toSystem, err := sde.SolarSystemForID(to.SystemID)
+	if err != nil {
+		return route, err
+	}
+	route.SourceSecurity = fromSystem.Security
+	route.DestinationSecurity = toSystem.Security
+	lowest := fromSystem
+	if toSystem.Security < lowest.Security {
+		lowest = toSystem
+	}
+	if stargates != nil {
+		if systemID, found := stargates.leastSecure(fromSystem.ID, toSystem.ID); found {
+			system, err := sde.SolarSystemForID(systemID)
+			if err != nil {
+				return route, err
+			}
+			if system.Security < lowest.Security {
+				lowest = system
+			}
+		}
+	}
+	route.LowestSecurity = lowest.Security
+	route.LowestSecuritySystem = lowest.Name
+	jumps, err := router.NumJumps(fromSystem, toSystem, evego.PreferShortest)
+	if err != nil {
+		log.Printf("Unable to find route from %v to %v: %v", fromSystem.Name, toSystem.Name, err)
+	} else {
+		route.Jumps = jumps
+	}
+	jumps, err = router.NumJumps(fromSystem, toSystem, evego.PreferHighSec)
+	if err != nil {
+		log.Printf("Unable to find high-sec route from %v to %v: %v", fromSystem.Name, toSystem.Name, err)
+	} else {
+		route.HighSecJumps = jumps
+	}
+	return route, nil
+}
+
+// matchOrders buys from the sell orders and sells into the buy orders, best
+// prices first, for as long as each unit can be sold at a profit after tax.
+// No more than maxUnits are hauled if it's positive. A buy order may be
+// filled from several sell orders; one whose minimum quantity is more than
+// can be sold into it at once is skipped, and the sell orders are left for
+// the buy orders after it.
+func matchOrders(sells, buys sortedOrders, salesTax float64, maxUnits int) haulLoad {
+	var (
+		load          haulLoad
+		cost, revenue float64
+	)
+	sellLeft := make([]int, len(sells))
+	for i, o := range sells {
+		sellLeft[i] = o.Quantity
+	}
+	s := 0
+	for _, buy := range buys {
+		net := buy.Price * (1.0 - salesTax)
+		want := buy.Quantity
+		if maxUnits > 0 {
+			want = min(want, maxUnits-load.Units)
+		}
+		// Count what the sell orders can supply at a profit.
+		available := 0
+		for i := s; i < len(sells) && sells[i].Price < net && available < want; i++ {
+			available += sellLeft[i]
+		}
+		qty := min(available, want)
+		if qty == 0 {
+			break
+		}
+		if buy.MinQuantity > qty {
+			continue
+		}
+		load.Units += qty
+		revenue += float64(qty) * net
+		for qty > 0 {
+			n := min(qty, sellLeft[s])
+			cost += float64(n) * sells[s].Price
+			sellLeft[s] -= n
+			qty -= n
+			if sellLeft[s] == 0 {
+				s++
+			}
+		}
+	}
+	load.Cost = priceFloat(cost)
+	load.Revenue = priceFloat(revenue)
+	load.Profit = priceFloat(revenue - cost)
+	return load
+}
+
+// haulItem works out the profit from hauling an item, given the orders for it
+// at the source and destination.
+func haulItem(item *evego.Item, source, destination []evego.Order,
+	capacity, salesTax float64) haulRow {
+	var sells, buys sortedOrders
+	for i := range source {
+		if source[i].Type == evego.Sell {
+			sells = append(sells, &source[i])
+		}
+	}
+	for i := range destination {
+		if destination[i].Type == evego.Buy {
+			buys = append(buys, &destination[i])
+		}
+	}
+	sort.Sort(sells)
+	sort.Sort(buys)
+
+	row := haulRow{
+		ItemID:     item.ID,
+		ItemName:   item.Name,
+		UnitVolume: item.Volume,
+		Total:      matchOrders(sells, buys, salesTax, 0),
+	}
+	row.Total.Volume = float64(row.Total.Units) * item.Volume
+	if item.Volume <= 0 {
+		// Takes up no space, so everything fits in one trip.
+		row.Trip = row.Total
+		if row.Trip.Units > 0 {
+			row.Trips = 1
+		}
+		return row
+	}
+	perTrip := int(capacity / item.Volume)
+	if perTrip == 0 {
+		return row
+	}
+	row.Trip = matchOrders(sells, buys, salesTax, perTrip)
+	row.Trip.Volume = float64(row.Trip.Units) * item.Volume
+	if row.Trip.Volume > 0 {
+		row.ProfitPerM3 = row.Trip.Profit / priceFloat(row.Trip.Volume)
+	}
+	row.Trips = int(math.Ceil(float64(row.Total.Units) / float64(perTrip)))
+	return row
+}
+
+// HaulingArbitrage returns a web handler function that finds items that can
+// be bought from sell orders at the station in the from URL parameter and
+// sold into buy orders reaching the station in the to parameter at a profit,
+// after the sales tax paid by the character given in the charID query
+// parameter. The capacity parameter is the cargo hold size in m³. The items
+// scanned are chosen as for MarketMargins. Rows are ranked by profit per
+// trip, or by profit per m³ if the sort parameter is "m3".
+func HaulingArbitrage(sde evego.Database, localdb db.LocalDB, mkt evego.Market, xmlAPI evego.XMLAPI,
+	router evego.Router, sess server.Sessionizer, defaultItems []string) web.HandlerFunc {
+	return func(c web.C, w http.ResponseWriter, r *http.Request) {
+		s := sess.GetSession(&c, w, r)
+		query := r.URL.Query()
+		fromID, err := strconv.Atoi(c.URLParams["from"])
+		if err != nil {
+			http.Error(w, `{"status": "Error", "error": "Invalid source station ID supplied."}`,
+				http.StatusBadRequest)
+			return
+		}
+		toID, err := strconv.Atoi(c.URLParams["to"])
+		if err != nil {
+			http.Error(w, `{"status": "Error", "error": "Invalid destination station ID supplied."}`,
+				http.StatusBadRequest)
+			return
+		}
+		capacity, err := strconv.ParseFloat(query.Get("capacity"), 64)
+		if err != nil || capacity <= 0 {
+			http.Error(w, `{"status": "Error", "error": "Invalid cargo capacity supplied."}`,
+				http.StatusBadRequest)
+			return
+		}
+		charID, ok := queryCharID(w, query)
+		if !ok {
+			return
+		}
+		limit, ok := queryLimit(w, query, defaultHaulRows)
+		if !ok {
+			return
+		}
+		itemNames, ok := scanItems(w, localdb, s.User, query, defaultItems)
+		if !ok {
+			return
+		}
+
+		source, err := stationScopeForID(sde, xmlAPI, router, fromID)
+		if err != nil {
+			http.Error(w, `{"status": "Error", "error": "Unable to identify source station"}`,
+				http.StatusBadRequest)
+			return
+		}
+		destination, err := stationScopeForID(sde, xmlAPI, router, toID)
+		if err != nil {
+			http.Error(w, `{"status": "Error", "error": "Unable to identify destination station"}`,
+				http.StatusBadRequest)
+			return
+		}
+		fees, err := characterFees(localdb, s.User, charID, destination.station)
+		if err != nil {
+			http.Error(w, `{"status": "Error", "error": "Unable to get character skills and standings."}`,
+				http.StatusInternalServerError)
+			log.Printf("Unable to get market fees for character %v: %v", charID, err)
+			return
+		}
+		route, err := routeBetween(sde, router, source.station, destination.station)
+		if err != nil {
+			http.Error(w, `{"status": "Error", "error": "Unable to identify location"}`,
+				http.StatusInternalServerError)
+			log.Printf("Unable to look up systems for route from %v to %v: %v", fromID, toID, err)
+			return
+		}
+
+		items := make(map[int]*evego.Item)
+		for _, name := range itemNames {
+			item, err := sde.ItemForName(name)
+			if err != nil {
+				continue
+			}
+			items[item.ID] = item
+		}
+		var (
+			wg       sync.WaitGroup
+			rowsLock sync.Mutex
+		)
+		rows := make(haulRows, 0, len(items))
+		for _, item := range items {
+			wg.Add(1)
+			i := item
+			server.Submit(func() {
+				defer wg.Done()
+				sourceOrders, err := source.orders(mkt, i)
+				if err != nil {
+					log.Printf("Unable to retrieve order information for %v: %v", i.Name, err)
+					return
+				}
+				destOrders, err := destination.orders(mkt, i)
+				if err != nil {
+					log.Printf("Unable to retrieve order information for %v: %v", i.Name, err)
+					return
+				}
+				row := haulItem(i, sourceOrders, destOrders, capacity, fees.SalesTax)
+				if row.Trip.Profit <= 0 {
+					return
+				}
+				rowsLock.Lock()
+				rows = append(rows, row)
+				rowsLock.Unlock()
+			})
+		}
+		wg.Wait()
+
+		if query.Get("sort") == "m3" {
+			sort.Sort(byProfitPerM3{rows})
+		} else {
+			sort.Sort(byTripProfit{rows})
+		}
+		if len(rows) > limit {
+			rows = rows[:limit]
+		}
+
+		response := struct {
+			Status      string       `json:"status"`
+			Source      *marketScope `json:"source"`
+			Destination *marketScope `json:"destination"`
+			Capacity    float64      `json:"capacity"`
+			Route       haulRoute    `json:"route"`
+			SalesTax    float64      `json:"salesTax"`
+			Items       haulRows     `json:"items"`
+		}{
+			Status:      "OK",
+			Source:      source,
+			Destination: destination,
+			Capacity:    capacity,
+			Route:       route,
+			SalesTax:    fees.SalesTax,
+			Items:       rows,
+		}
+		responseJSON, _ := json.Marshal(&response)
+		w.Write(responseJSON)
+	}
+}
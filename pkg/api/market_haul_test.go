@@ -0,0 +1,184 @@
+/*
+Copyright © 2014–6 Brad Ackerman.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package api
+
+import (
+	"testing"
+
+	"github.com/backerman/evego"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// sorted returns the addresses of orders, which must already be sorted best
+// price first.
+func sorted(orders ...evego.Order) sortedOrders {
+	result := make(sortedOrders, len(orders))
+	for i := range orders {
+		result[i] = &orders[i]
+	}
+	return result
+}
+
+func TestMatchOrders(t *testing.T) {
+	Convey("Verify that hauled orders are matched best first", t, func() {
+		cases := []struct {
+			desc     string
+			sells    sortedOrders
+			buys     sortedOrders
+			tax      float64
+			maxUnits int
+			units    int
+			cost     float64
+			revenue  float64
+		}{
+			{
+				desc:    "Units are hauled while they sell at a profit after tax",
+				sells:   sorted(depthOrder(evego.Sell, 100, 10, 1), depthOrder(evego.Sell, 110, 10, 1)),
+				buys:    sorted(depthOrder(evego.Buy, 130, 5, 1), depthOrder(evego.Buy, 115, 10, 1)),
+				tax:     0.1,
+				units:   10,
+				cost:    1000,
+				revenue: 5*117 + 5*103.5,
+			},
+			{
+				desc:  "Nothing is hauled at break-even",
+				sells: sorted(depthOrder(evego.Sell, 90, 10, 1)),
+				buys:  sorted(depthOrder(evego.Buy, 100, 10, 1)),
+				tax:   0.1,
+			},
+			{
+				desc:     "No more than maxUnits are hauled",
+				sells:    sorted(depthOrder(evego.Sell, 100, 10, 1), depthOrder(evego.Sell, 110, 10, 1)),
+				buys:     sorted(depthOrder(evego.Buy, 130, 5, 1), depthOrder(evego.Buy, 115, 10, 1)),
+				tax:      0.1,
+				maxUnits: 7,
+				units:    7,
+				cost:     700,
+				revenue:  5*117 + 2*103.5,
+			},
+			{
+				desc:    "Buy orders with a minimum above what's available are skipped",
+				sells:   sorted(depthOrder(evego.Sell, 100, 10, 1)),
+				buys:    sorted(depthOrder(evego.Buy, 200, 10, 20), depthOrder(evego.Buy, 150, 5, 1)),
+				units:   5,
+				cost:    500,
+				revenue: 750,
+			},
+			{
+				desc:     "Buy orders with a minimum above the hold's share are skipped",
+				sells:    sorted(depthOrder(evego.Sell, 100, 10, 1)),
+				buys:     sorted(depthOrder(evego.Buy, 200, 10, 5)),
+				maxUnits: 3,
+			},
+			{
+				desc:    "A buy order is filled from several sell orders",
+				sells:   sorted(depthOrder(evego.Sell, 100, 5, 1), depthOrder(evego.Sell, 101, 5, 1)),
+				buys:    sorted(depthOrder(evego.Buy, 200, 10, 8)),
+				units:   10,
+				cost:    1005,
+				revenue: 2000,
+			},
+			{
+				desc:  "Sell orders skipped by a buy order are left for the next",
+				sells: sorted(depthOrder(evego.Sell, 100, 5, 1), depthOrder(evego.Sell, 101, 5, 1)),
+				buys: sorted(depthOrder(evego.Buy, 200, 10, 20),
+					depthOrder(evego.Buy, 150, 10, 8)),
+				units:   10,
+				cost:    1005,
+				revenue: 1500,
+			},
+		}
+		for _, tc := range cases {
+			Convey(tc.desc, func() {
+				load := matchOrders(tc.sells, tc.buys, tc.tax, tc.maxUnits)
+				So(load.Units, ShouldEqual, tc.units)
+				So(float64(load.Cost), ShouldAlmostEqual, tc.cost)
+				So(float64(load.Revenue), ShouldAlmostEqual, tc.revenue)
+				So(float64(load.Profit), ShouldAlmostEqual, tc.revenue-tc.cost)
+			})
+		}
+	})
+}
+
+func TestHaulItem(t *testing.T) {
+	Convey("Verify hauling an item between two stations", t, func() {
+		// 10 units can be bought at 100 and sold at 200, untaxed, for a profit
+		// of 100 each. The source's buy order and the destination's sell
+		// order aren't used.
+		source := []evego.Order{
+			depthOrder(evego.Sell, 100, 10, 1),
+			depthOrder(evego.Buy, 300, 10, 1),
+		}
+		destination := []evego.Order{
+			depthOrder(evego.Buy, 200, 10, 1),
+			depthOrder(evego.Sell, 50, 10, 1),
+		}
+		cases := []struct {
+			desc        string
+			volume      float64
+			capacity    float64
+			tripUnits   int
+			trips       int
+			profitPerM3 float64
+		}{
+			{
+				desc:      "An item with no volume is hauled in one trip",
+				volume:    0,
+				capacity:  5,
+				tripUnits: 10,
+				trips:     1,
+			},
+			{
+				desc:     "An item too big for the hold isn't hauled",
+				volume:   10,
+				capacity: 5,
+			},
+			{
+				desc:        "A partly filled last trip is counted",
+				volume:      1,
+				capacity:    4,
+				tripUnits:   4,
+				trips:       3,
+				profitPerM3: 100,
+			},
+			{
+				desc:        "Full trips aren't rounded up",
+				volume:      2,
+				capacity:    10,
+				tripUnits:   5,
+				trips:       2,
+				profitPerM3: 50,
+			},
+		}
+		for _, tc := range cases {
+			Convey(tc.desc, func() {
+				item := &evego.Item{ID: 34, Name: "Tritanium", Volume: tc.volume}
+				row := haulItem(item, source, destination, tc.capacity, 0)
+				So(row.Total.Units, ShouldEqual, 10)
+				So(row.Total.Volume, ShouldAlmostEqual, 10*tc.volume)
+				So(float64(row.Total.Profit), ShouldAlmostEqual, 1000.0)
+				So(row.Trip.Units, ShouldEqual, tc.tripUnits)
+				So(row.Trip.Volume, ShouldAlmostEqual, float64(tc.tripUnits)*tc.volume)
+				So(float64(row.Trip.Profit), ShouldAlmostEqual, 100*float64(tc.tripUnits))
+				So(row.Trips, ShouldEqual, tc.trips)
+				So(float64(row.ProfitPerM3), ShouldAlmostEqual, tc.profitPerM3)
+			})
+		}
+	})
+}
@@ -0,0 +1,82 @@
+/*
+Copyright © 2014–6 Brad Ackerman.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/backerman/eveindy/pkg/db"
+	"github.com/zenazn/goji/web"
+)
+
+// Defaults and limits for the number of days of history returned.
+const (
+	defaultHistoryDays = 30
+	maxHistoryDays     = 365
+)
+
+// PriceHistory returns a web handler function that provides the recorded
+// daily market snapshots of an item at a station (Jita 4-4 unless the station
+// query parameter is given) over the number of days in the days parameter.
+func PriceHistory(localdb db.LocalDB) web.HandlerFunc {
+	return func(c web.C, w http.ResponseWriter, r *http.Request) {
+		typeID, err := strconv.Atoi(c.URLParams["typeID"])
+		if err != nil {
+			http.Error(w, `{"status": "Error", "error": "Invalid type ID supplied."}`,
+				http.StatusBadRequest)
+			return
+		}
+		query := r.URL.Query()
+		stationID := jitaStationID
+		if stn := query.Get("station"); stn != "" {
+			stationID, err = strconv.Atoi(stn)
+			if err != nil {
+				http.Error(w, `{"status": "Error", "error": "Invalid station ID supplied."}`,
+					http.StatusBadRequest)
+				return
+			}
+		}
+		days := defaultHistoryDays
+		if d := query.Get("days"); d != "" {
+			days, err = strconv.Atoi(d)
+			if err != nil || days <= 0 {
+				http.Error(w, `{"status": "Error", "error": "Invalid number of days supplied."}`,
+					http.StatusBadRequest)
+				return
+			}
+			days = min(days, maxHistoryDays)
+		}
+		history, err := localdb.PriceHistory(typeID, stationID, days)
+		if err != nil {
+			http.Error(w, `{"status": "Error", "error": "Unable to access database."}`,
+				http.StatusInternalServerError)
+			log.Printf("Error getting price history for type %v at %v: %v", typeID, stationID, err)
+			return
+		}
+		response := struct {
+			Status  string                 `json:"status"`
+			History []db.PriceHistoryEntry `json:"history"`
+		}{"OK", history}
+		responseJSON, _ := json.Marshal(&response)
+		w.Write(responseJSON)
+	}
+}
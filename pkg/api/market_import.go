@@ -0,0 +1,96 @@
+/*
+Copyright © 2014–6 Brad Ackerman.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package api
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/backerman/evego"
+	"github.com/backerman/eveindy/pkg/db"
+	"github.com/backerman/eveindy/pkg/orderbook"
+	"github.com/backerman/eveindy/pkg/server"
+	"github.com/zenazn/goji/web"
+)
+
+// maxUploadBytes is the largest order-book snapshot or upload we accept.
+const maxUploadBytes = 64 << 20
+
+// ImportMarketSnapshot returns a web handler function that imports an
+// order-book snapshot, sent as the request body in CSV (text/csv) or JSON
+// (application/json) form, into the local market. As the market is shared by
+// every user, only administrators and holders of an upload key (in the
+// X-Upload-Key header) may import orders.
+func ImportMarketSnapshot(sde evego.Database, localdb db.LocalDB, sess server.Sessionizer,
+	admins []int, uploadKeys map[string]string) web.HandlerFunc {
+	return func(c web.C, w http.ResponseWriter, r *http.Request) {
+		uploader := uploaderForKey(uploadKeys, r.Header.Get("X-Upload-Key"))
+		if uploader == "" {
+			s := sess.GetSession(&c, w, r)
+			admin, err := isAdmin(localdb, admins, s.User)
+			if err != nil {
+				http.Error(w, `{"status": "Error", "error": "Unable to access database."}`,
+					http.StatusInternalServerError)
+				log.Printf("Unable to check whether user %v is an administrator: %v", s.User, err)
+				return
+			}
+			if !admin {
+				http.Error(w, `{"status": "Error", "error": "You must be an administrator or use an upload key to import orders."}`,
+					http.StatusForbidden)
+				return
+			}
+			uploader = fmt.Sprintf("user %d", s.User)
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, maxUploadBytes)
+		contentType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil {
+			http.Error(w, `{"status": "Error", "error": "Bad request content type"}`,
+				http.StatusBadRequest)
+			return
+		}
+		var format string
+		switch contentType {
+		case "text/csv":
+			format = orderbook.FormatCSV
+		case "application/json":
+			format = orderbook.FormatJSON
+		default:
+			http.Error(w, `{"status": "Error", "error": "Request must be of type text/csv or application/json"}`,
+				http.StatusUnsupportedMediaType)
+			return
+		}
+		orders, err := orderbook.ReadSnapshot(r.Body, format, sde)
+		if err != nil {
+			uploadError(w, err)
+			return
+		}
+		err = localdb.ImportMarketSnapshot(orders, time.Now(), uploader)
+		if err != nil {
+			http.Error(w, `{"status": "Error", "error": "Unable to store orders."}`,
+				http.StatusInternalServerError)
+			log.Printf("Unable to import market snapshot from %v: %v", uploader, err)
+			return
+		}
+		log.Printf("%v imported %d market orders", uploader, len(orders))
+		w.Write([]byte(fmt.Sprintf(`{"status": "OK", "imported": %d}`, len(orders))))
+	}
+}
@@ -0,0 +1,361 @@
+/*
+Copyright © 2014–6 Brad Ackerman.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/backerman/evego"
+	"github.com/backerman/eveindy/pkg/db"
+	"github.com/backerman/eveindy/pkg/server"
+	"github.com/zenazn/goji/web"
+)
+
+// Skill IDs for use in our market fee calculations.
+const (
+	accountingSkillID      = 16622
+	brokerRelationsSkillID = 3446
+)
+
+const (
+	// defaultMarginRows is the number of items returned by the margin scanner
+	// unless more are requested.
+	defaultMarginRows = 50
+	// maxMarginItems is the largest item universe a market scan will price.
+	maxMarginItems = 1000
+	// depletionDays is the number of days of history over which estimated
+	// depletion is averaged.
+	depletionDays = 7
+)
+
+// marketFees are the rates (as fractions of order value) a character pays to
+// trade at a station.
+type marketFees struct {
+	BrokerFee float64 `json:"brokerFee"`
+	SalesTax  float64 `json:"salesTax"`
+}
+
+// brokerFeeRate returns the broker fee charged for placing an order, given
+// the character's Broker Relations skill and standings with the station
+// owner's corporation and faction.
+func brokerFeeRate(brokerRelations int, corpStanding, factionStanding float64) float64 {
+	return (0.01 - 0.0005*float64(brokerRelations)) /
+		math.Exp(0.1*factionStanding+0.04*corpStanding)
+}
+
+// salesTaxRate returns the sales tax charged when a sell order is filled,
+// given the character's Accounting skill.
+func salesTaxRate(accounting int) float64 {
+	return 0.02 * (1.0 - 0.1*float64(accounting))
+}
+
+// characterFees returns the fees paid by one of the user's characters at a
+// station. A character ID of zero gets the fees of an untrained character
+// with no standings.
+func characterFees(localdb db.LocalDB, userID, charID int, station *evego.Station) (marketFees, error) {
+	if charID == 0 {
+		return marketFees{brokerFeeRate(0, 0, 0), salesTaxRate(0)}, nil
+	}
+	accounting, err := localdb.CharacterSkill(userID, charID, accountingSkillID)
+	if err != nil {
+		return marketFees{}, err
+	}
+	brokerRelations, err := localdb.CharacterSkill(userID, charID, brokerRelationsSkillID)
+	if err != nil {
+		return marketFees{}, err
+	}
+	corpStanding, facStanding, err := localdb.CharacterStandings(userID, charID, station.CorporationID)
+	if err != nil && err != sql.ErrNoRows {
+		return marketFees{}, err
+	}
+	// Standings that aren't known (including all of them, if the station
+	// owner isn't an NPC corporation) count as zero.
+	return marketFees{
+		BrokerFee: brokerFeeRate(brokerRelations, corpStanding.Float64, facStanding.Float64),
+		SalesTax:  salesTaxRate(accounting),
+	}, nil
+}
+
+// marginRow is the result of station trading one unit of an item: buying it
+// with a buy order at the best buy price and reselling it with a sell order at
+// the best sell price.
+type marginRow struct {
+	ItemID   int        `json:"itemID"`
+	ItemName string     `json:"itemName"`
+	BestBuy  priceFloat `json:"bestBuy"`
+	BestSell priceFloat `json:"bestSell"`
+	// BuyCost is what it costs to buy one unit, including the broker fee;
+	// SellProceeds what is left of the sale price after fees and tax.
+	BuyCost      priceFloat `json:"buyCost"`
+	SellProceeds priceFloat `json:"sellProceeds"`
+	// Spread is the profit per unit after fees, and MarginPct the same as a
+	// percentage of BuyCost.
+	Spread    priceFloat `json:"spread"`
+	MarginPct float64    `json:"marginPct"`
+	// EstimatedDepletion is the average number of units by which the
+	// station's order book shrinks each day, if the item's price history is
+	// recorded there. It's only an estimate of traded volume: it can't see
+	// units that were traded and replaced by new orders between snapshots,
+	// and it counts cancelled and expired orders as trades.
+	EstimatedDepletion *float64 `json:"estimatedDepletion,omitempty"`
+}
+
+type marginRows []marginRow
+
+func (m marginRows) Len() int      { return len(m) }
+func (m marginRows) Swap(i, j int) { m[i], m[j] = m[j], m[i] }
+
+// bySpread sorts rows by descending spread.
+type bySpread struct{ marginRows }
+
+func (m bySpread) Less(i, j int) bool { return m.marginRows[i].Spread > m.marginRows[j].Spread }
+
+// byMarginPct sorts rows by descending margin.
+type byMarginPct struct{ marginRows }
+
+func (m byMarginPct) Less(i, j int) bool {
+	return m.marginRows[i].MarginPct > m.marginRows[j].MarginPct
+}
+
+// marginFor computes the margin row for an item priced at a station.
+func marginFor(price *responseItem, fees marketFees) marginRow {
+	cost := float64(price.BestBuy) * (1.0 + fees.BrokerFee)
+	proceeds := float64(price.BestSell) * (1.0 - fees.BrokerFee - fees.SalesTax)
+	row := marginRow{
+		ItemID:       price.ItemID,
+		ItemName:     price.ItemName,
+		BestBuy:      price.BestBuy,
+		BestSell:     price.BestSell,
+		BuyCost:      priceFloat(cost),
+		SellProceeds: priceFloat(proceeds),
+		Spread:       priceFloat(proceeds - cost),
+	}
+	if cost > 0 {
+		row.MarginPct = (proceeds - cost) / cost * 100.0
+	}
+	return row
+}
+
+// queryCharID returns the character ID passed in the charID query parameter,
+// or zero if there isn't one. If it's invalid, an error is returned to the
+// client and ok is false.
+func queryCharID(w http.ResponseWriter, query url.Values) (charID int, ok bool) {
+	charIDStr := query.Get("charID")
+	if charIDStr == "" {
+		return 0, true
+	}
+	charID, err := strconv.Atoi(charIDStr)
+	if err != nil {
+		http.Error(w, `{"status": "Error", "error": "Invalid character ID supplied."}`,
+			http.StatusBadRequest)
+		return 0, false
+	}
+	return charID, true
+}
+
+// queryLimit returns the number of results requested in the limit query
+// parameter, or defaultLimit if there isn't one. If it's invalid, an error is
+// returned to the client and ok is false.
+func queryLimit(w http.ResponseWriter, query url.Values, defaultLimit int) (limit int, ok bool) {
+	l := query.Get("limit")
+	if l == "" {
+		return defaultLimit, true
+	}
+	limit, err := strconv.Atoi(l)
+	if err != nil || limit <= 0 {
+		http.Error(w, `{"status": "Error", "error": "Invalid limit supplied."}`,
+			http.StatusBadRequest)
+		return 0, false
+	}
+	return limit, true
+}
+
+// watchlistItems returns the names of the distinct items in one of a user's
+// watchlists, or nil if the user has no such list.
+func watchlistItems(localdb db.LocalDB, userID, watchlistID int) ([]string, error) {
+	lists, err := localdb.Watchlists(userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, list := range lists {
+		if list.ID != watchlistID {
+			continue
+		}
+		items := []string{}
+		seen := make(map[int]bool)
+		for _, i := range list.Items {
+			if !seen[i.TypeID] {
+				seen[i.TypeID] = true
+				items = append(items, i.TypeName)
+			}
+		}
+		return items, nil
+	}
+	return nil, nil
+}
+
+// scanItems returns the names of the items to be scanned by a market search:
+// those in the market group given by the marketGroup query parameter or the
+// user's watchlist given by the watchlist parameter, or the passed default
+// items if neither is given. If they can't be determined, an error is
+// returned to the client and ok is false.
+func scanItems(w http.ResponseWriter, localdb db.LocalDB, userID int, query url.Values,
+	defaultItems []string) (items []string, ok bool) {
+	items = defaultItems
+	if list := query.Get("watchlist"); list != "" {
+		listID, err := strconv.Atoi(list)
+		if err != nil {
+			http.Error(w, `{"status": "Error", "error": "Invalid watchlist supplied."}`,
+				http.StatusBadRequest)
+			return nil, false
+		}
+		items, err = watchlistItems(localdb, userID, listID)
+		if err != nil {
+			http.Error(w, `{"status": "Error", "error": "Unable to access database."}`,
+				http.StatusInternalServerError)
+			log.Printf("Unable to get items in watchlist %v: %v", listID, err)
+			return nil, false
+		}
+		if items == nil {
+			http.Error(w, `{"status": "Error", "error": "No such watchlist."}`,
+				http.StatusNotFound)
+			return nil, false
+		}
+	} else if group := query.Get("marketGroup"); group != "" {
+		groupID, err := strconv.Atoi(group)
+		if err != nil {
+			http.Error(w, `{"status": "Error", "error": "Invalid market group supplied."}`,
+				http.StatusBadRequest)
+			return nil, false
+		}
+		items, err = localdb.MarketGroupItems(groupID)
+		if err != nil {
+			http.Error(w, `{"status": "Error", "error": "Unable to access database."}`,
+				http.StatusInternalServerError)
+			log.Printf("Unable to get items in market group %v: %v", groupID, err)
+			return nil, false
+		}
+	}
+	if len(items) > maxMarginItems {
+		http.Error(w, `{"status": "Error", "error": "Too many items to scan."}`,
+			http.StatusBadRequest)
+		return nil, false
+	}
+	return items, true
+}
+
+// MarketMargins returns a web handler function that ranks items by the profit
+// to be made station trading them at a station, after broker fees and sales
+// tax. The items scanned are those in the market group given by the
+// marketGroup query parameter or the watchlist given by the watchlist
+// parameter, or the passed default items if neither is given.
+// Fees are those paid by the user's character given in the charID parameter.
+// Rows are ranked by spread, or by percentage margin if the sort parameter is
+// "margin"; the limit parameter sets how many are returned.
+func MarketMargins(sde evego.Database, localdb db.LocalDB, mkt evego.Market, xmlAPI evego.XMLAPI,
+	router evego.Router, sess server.Sessionizer, defaultItems []string) web.HandlerFunc {
+	return func(c web.C, w http.ResponseWriter, r *http.Request) {
+		s := sess.GetSession(&c, w, r)
+		query := r.URL.Query()
+		stationID, err := strconv.Atoi(c.URLParams["stationID"])
+		if err != nil {
+			http.Error(w, `{"status": "Error", "error": "Invalid station ID supplied."}`,
+				http.StatusBadRequest)
+			return
+		}
+		charID, ok := queryCharID(w, query)
+		if !ok {
+			return
+		}
+		limit, ok := queryLimit(w, query, defaultMarginRows)
+		if !ok {
+			return
+		}
+
+		itemNames, ok := scanItems(w, localdb, s.User, query, defaultItems)
+		if !ok {
+			return
+		}
+
+		scope, err := stationScopeForID(sde, xmlAPI, router, stationID)
+		if err != nil {
+			http.Error(w, `{"status": "Error", "error": "Unable to identify location"}`,
+				http.StatusBadRequest)
+			return
+		}
+		fees, err := characterFees(localdb, s.User, charID, scope.station)
+		if err != nil {
+			http.Error(w, `{"status": "Error", "error": "Unable to get character skills and standings."}`,
+				http.StatusInternalServerError)
+			log.Printf("Unable to get market fees for character %v: %v", charID, err)
+			return
+		}
+		depletion, err := localdb.DailyDepletion(stationID, depletionDays)
+		if err != nil {
+			// The estimates are nice to have, so carry on without them.
+			log.Printf("Unable to get estimated depletion at %v: %v", stationID, err)
+		}
+
+		req := make([]queryItem, len(itemNames))
+		for i, name := range itemNames {
+			req[i] = queryItem{Quantity: 1, ItemName: name}
+		}
+		prices := getItemPrices(sde, mkt, &req, scope, defaultPricing)
+		rows := make(marginRows, 0, len(*prices))
+		for _, price := range *prices {
+			if price.Error != "" || price.BestBuy == 0 || price.BestSell == 0 {
+				continue
+			}
+			row := marginFor(&price, fees)
+			if units, found := depletion[price.ItemID]; found {
+				row.EstimatedDepletion = &units
+			}
+			rows = append(rows, row)
+		}
+		if query.Get("sort") == "margin" {
+			sort.Sort(byMarginPct{rows})
+		} else {
+			sort.Sort(bySpread{rows})
+		}
+		if len(rows) > limit {
+			rows = rows[:limit]
+		}
+
+		response := struct {
+			Status  string       `json:"status"`
+			Station *marketScope `json:"station"`
+			Fees    marketFees   `json:"fees"`
+			Items   marginRows   `json:"items"`
+		}{
+			Status:  "OK",
+			Station: scope,
+			Fees:    fees,
+			Items:   rows,
+		}
+		responseJSON, _ := json.Marshal(&response)
+		w.Write(responseJSON)
+	}
+}
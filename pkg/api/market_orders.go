@@ -0,0 +1,178 @@
+/*
+Copyright © 2014–6 Brad Ackerman.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/backerman/evego"
+	"github.com/backerman/eveindy/pkg/db"
+	"github.com/backerman/eveindy/pkg/server"
+	"github.com/backerman/eveindy/pkg/xmlapi"
+	"github.com/zenazn/goji/web"
+)
+
+// priceTick is the smallest amount by which an order's price can be changed.
+const priceTick = 0.01
+
+type myOrder struct {
+	OrderID      int64      `json:"orderID"`
+	ItemID       int        `json:"itemID"`
+	ItemName     string     `json:"itemName"`
+	IsBuy        bool       `json:"isBuy"`
+	Price        priceFloat `json:"price"`
+	VolEntered   int        `json:"volEntered"`
+	VolRemaining int        `json:"volRemaining"`
+	Issued       time.Time  `json:"issued"`
+	// Where the order is and how far it reaches.
+	Info orderInfo `json:"info"`
+	// BestCompetitor is the best price offered by anyone else (lowest sell in
+	// the same station, or highest buy reaching it); zero if there is no
+	// competition.
+	BestCompetitor priceFloat `json:"bestCompetitor"`
+	// Undercut is true iff a sell order has been undercut or a buy order
+	// outbid.
+	Undercut bool `json:"undercut"`
+	// SuggestedPrice is the price that would put this order back on top.
+	SuggestedPrice priceFloat `json:"suggestedPrice,omitempty"`
+}
+
+// apiRangeString converts the range of an order returned by the XML API into
+// the same form as makeRangeString.
+func apiRangeString(r int) string {
+	switch r {
+	case xmlapi.RangeStation:
+		return "station"
+	case xmlapi.RangeSystem:
+		return "system"
+	case xmlapi.RangeRegion:
+		return "region"
+	}
+	return fmt.Sprintf("%d jumps", r)
+}
+
+// compareOrder checks a character's order against the current order book and
+// fills in the competition fields.
+func compareOrder(mine *myOrder, stationID int, book []evego.Order) {
+	var best float64
+	for _, ord := range book {
+		// We don't get order IDs from the market, so skip anything that looks
+		// like our own order.
+		if ord.Station != nil && ord.Station.ID == stationID &&
+			ord.Price == float64(mine.Price) && ord.Quantity == mine.VolRemaining {
+			continue
+		}
+		if mine.IsBuy {
+			if ord.Type == evego.Buy && ord.Price > best {
+				best = ord.Price
+			}
+		} else if ord.Type == evego.Sell && ord.Station != nil && ord.Station.ID == stationID &&
+			(best == 0 || ord.Price < best) {
+			best = ord.Price
+		}
+	}
+	mine.BestCompetitor = priceFloat(best)
+	if best == 0 {
+		return
+	}
+	if mine.IsBuy && best >= float64(mine.Price) {
+		mine.Undercut = true
+		mine.SuggestedPrice = priceFloat(best + priceTick)
+	} else if !mine.IsBuy && best <= float64(mine.Price) {
+		mine.Undercut = true
+		mine.SuggestedPrice = priceFloat(best - priceTick)
+	}
+}
+
+// MyMarketOrders returns a web handler function that lists a character's open
+// market orders and flags those that have been undercut or outbid.
+func MyMarketOrders(sde evego.Database, localdb db.LocalDB, mkt evego.Market,
+	xmlAPI evego.XMLAPI, sess server.Sessionizer) web.HandlerFunc {
+	return func(c web.C, w http.ResponseWriter, r *http.Request) {
+		s := sess.GetSession(&c, w, r)
+		myUserID := s.User
+		charID, err := strconv.Atoi(c.URLParams["charID"])
+		if err != nil {
+			http.Error(w, `{"status": "Error", "error": "Invalid character ID supplied."}`,
+				http.StatusBadRequest)
+			return
+		}
+		orders, err := localdb.CharacterMarketOrders(myUserID, charID)
+		if err != nil {
+			http.Error(w, `{"status": "Error", "error": "Unable to access database."}`,
+				http.StatusInternalServerError)
+			log.Printf("Error getting market orders with user %v, character %v: %v", myUserID, charID, err)
+			return
+		}
+		stations := make(map[int]*evego.Station)
+		results := make([]myOrder, 0, len(orders))
+		for _, o := range orders {
+			stn, found := stations[o.StationID]
+			if !found {
+				stn, err = findStation(sde, xmlAPI, o.StationID)
+				if err != nil {
+					log.Printf("Unable to look up station/outpost ID %v: %v", o.StationID, err)
+					continue
+				}
+				stations[o.StationID] = stn
+			}
+			item, err := sde.ItemForID(o.TypeID)
+			if err != nil {
+				log.Printf("Unable to look up item ID %v: %v", o.TypeID, err)
+				continue
+			}
+			mine := myOrder{
+				OrderID:      o.OrderID,
+				ItemID:       o.TypeID,
+				ItemName:     o.TypeName,
+				IsBuy:        o.IsBuy,
+				Price:        priceFloat(o.Price),
+				VolEntered:   o.VolEntered,
+				VolRemaining: o.VolRemaining,
+				Issued:       o.Issued,
+				Info: orderInfo{
+					Quantity:    o.VolRemaining,
+					MinQuantity: o.MinVolume,
+					Station:     stationFromAPI(sde, stn, stn.ReprocessingEfficiency == 0.0),
+					Within:      apiRangeString(o.Range),
+				},
+			}
+			book, err := mkt.OrdersInStation(item, stn)
+			if err != nil {
+				http.Error(w, `{"status": "Error", "error": "Unable to retrieve order information"}`,
+					http.StatusInternalServerError)
+				log.Printf("Unable to retrieve orders for %v in %v: %v", item.Name, stn.Name, err)
+				return
+			}
+			compareOrder(&mine, o.StationID, *book)
+			results = append(results, mine)
+		}
+		response := struct {
+			Status string    `json:"status"`
+			Orders []myOrder `json:"orders"`
+		}{"OK", results}
+		responseJSON, _ := json.Marshal(&response)
+		w.Write(responseJSON)
+	}
+}
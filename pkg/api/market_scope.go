@@ -0,0 +1,125 @@
+/*
+Copyright © 2014–6 Brad Ackerman.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package api
+
+import (
+	"github.com/backerman/evego"
+	"github.com/backerman/eveindy/pkg/orderbook"
+)
+
+// The scopes a market query can cover.
+const (
+	scopeRegion  = "region"
+	scopeSystem  = "system"
+	scopeStation = "station"
+)
+
+// marketScope is the part of the market that a price lookup covers.
+type marketScope struct {
+	// Kind is one of the scope constants above; Name and ID identify the
+	// region, system or station.
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+	ID   int    `json:"id"`
+	// Region is the region searched to find the scope's orders.
+	Region string `json:"region"`
+
+	station *evego.Station
+	// reach decides which buy orders can be filled at the scope's station.
+	reach *orderbook.Reach
+}
+
+// regionScope returns a scope covering every order in the named region.
+func regionScope(db evego.Database, name string) (*marketScope, error) {
+	region, err := db.RegionForName(name)
+	if err != nil {
+		return nil, err
+	}
+	return &marketScope{
+		Kind:   scopeRegion,
+		Name:   region.Name,
+		ID:     region.ID,
+		Region: region.Name,
+	}, nil
+}
+
+// systemScope returns a scope covering the orders placed in the named solar
+// system.
+func systemScope(db evego.Database, name string) (*marketScope, error) {
+	system, err := db.SolarSystemForName(name)
+	if err != nil {
+		return nil, err
+	}
+	return &marketScope{
+		Kind:   scopeSystem,
+		Name:   system.Name,
+		ID:     system.ID,
+		Region: system.Region,
+	}, nil
+}
+
+// stationScope returns a scope covering the sell orders in a station and
+// every buy order that can be filled there, including those placed in other
+// systems whose range reaches it. The router is used to work out the
+// distance to those systems.
+func stationScope(db evego.Database, router evego.Router, station *evego.Station) (*marketScope, error) {
+	system, err := db.SolarSystemForID(station.SystemID)
+	if err != nil {
+		return nil, err
+	}
+	return &marketScope{
+		Kind:    scopeStation,
+		Name:    station.Name,
+		ID:      station.ID,
+		Region:  system.Region,
+		station: station,
+		reach:   orderbook.NewReach(station, router),
+	}, nil
+}
+
+// includes returns true iff an order belongs in the scope.
+func (s *marketScope) includes(order *evego.Order) bool {
+	if order.Station == nil {
+		return s.Kind == scopeRegion
+	}
+	switch s.Kind {
+	case scopeSystem:
+		return order.Station.SystemID == s.ID
+	case scopeStation:
+		if order.Type == evego.Buy {
+			return s.reach.BuyOrderReaches(order)
+		}
+		return order.Station.ID == s.ID
+	}
+	return true
+}
+
+// orders returns the orders for an item that fall within the scope.
+func (s *marketScope) orders(mkt evego.Market, item *evego.Item) ([]evego.Order, error) {
+	orders, err := mkt.OrdersForItem(item, s.Region, evego.AllOrders)
+	if err != nil {
+		return nil, err
+	}
+	scoped := make([]evego.Order, 0, len(*orders))
+	for i := range *orders {
+		if s.includes(&(*orders)[i]) {
+			scoped = append(scoped, (*orders)[i])
+		}
+	}
+	return scoped, nil
+}
@@ -0,0 +1,312 @@
+/*
+Copyright © 2014–6 Brad Ackerman.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/backerman/evego"
+	"github.com/backerman/eveindy/pkg/db"
+	"github.com/backerman/eveindy/pkg/server"
+	"github.com/zenazn/goji/web"
+)
+
+// tickerCacheTTL is how long a ticker's prices are cached.
+const tickerCacheTTL = time.Hour
+
+// Ticker is a list of items whose prices are shown together at a market hub.
+type Ticker struct {
+	// Station is the ID of the station whose market is used.
+	Station int      `json:"station"`
+	Items   []string `json:"items"`
+}
+
+// tickerInfo is a ticker as sent to clients.
+type tickerInfo struct {
+	Name string `json:"name"`
+	Ticker
+	StationName string `json:"stationName"`
+	// Configured is true for tickers defined in the server's configuration
+	// rather than by the user.
+	Configured bool `json:"configured"`
+}
+
+type tickerInfos []tickerInfo
+
+func (t tickerInfos) Len() int           { return len(t) }
+func (t tickerInfos) Swap(i, j int)      { t[i], t[j] = t[j], t[i] }
+func (t tickerInfos) Less(i, j int) bool { return t[i].Name < t[j].Name }
+
+// tickerCacheKey returns the key under which a ticker's prices are cached.
+// It's derived from the ticker's definition so that a changed ticker isn't
+// served stale prices.
+func tickerCacheKey(name string, t *Ticker) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s:%d:%s", name, t.Station, strings.Join(t.Items, "\n"))))
+	return "ticker:" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// userTicker returns one of a user's tickers, or nil if there's no such
+// ticker.
+func userTicker(localdb db.LocalDB, userID int, name string) (*Ticker, error) {
+	if userID == 0 {
+		return nil, nil
+	}
+	stored, err := localdb.UserTickers(userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range stored {
+		if t.Name == name {
+			ticker := &Ticker{Station: t.StationID}
+			err = json.Unmarshal(t.Items, &ticker.Items)
+			if err != nil {
+				return nil, err
+			}
+			return ticker, nil
+		}
+	}
+	return nil, nil
+}
+
+// MarketTicker returns a web handler function that provides the prices of the
+// items in the ticker named in the URL, which is either one of the passed
+// configured tickers or one defined by the current user. Prices are cached for
+// an hour.
+func MarketTicker(sde evego.Database, localdb db.LocalDB, mkt evego.Market, xmlAPI evego.XMLAPI,
+	router evego.Router, cache evego.Cache, sess server.Sessionizer, tickers map[string]Ticker) web.HandlerFunc {
+	return func(c web.C, w http.ResponseWriter, r *http.Request) {
+		name := c.URLParams["name"]
+		var ticker *Ticker
+		if t, found := tickers[name]; found {
+			ticker = &t
+		} else {
+			s := sess.GetSession(&c, w, r)
+			var err error
+			ticker, err = userTicker(localdb, s.User, name)
+			if err != nil {
+				http.Error(w, `{"status": "Error", "error": "Unable to access database."}`,
+					http.StatusInternalServerError)
+				log.Printf("Unable to get tickers for user %v: %v", s.User, err)
+				return
+			}
+		}
+		if ticker == nil {
+			http.Error(w, `{"status": "Error", "error": "No such ticker."}`,
+				http.StatusNotFound)
+			return
+		}
+
+		// Check cached and use that instead if it's available.
+		key := tickerCacheKey(name, ticker)
+		cached, found := cache.Get(key)
+		if found {
+			w.Write(cached)
+			return
+		}
+		scope, err := stationScopeForID(sde, xmlAPI, router, ticker.Station)
+		if err != nil {
+			http.Error(w, `{"status": "Error", "error": "Unable to retrieve ticker prices"}`,
+				http.StatusInternalServerError)
+			log.Printf("Error looking up station %v for ticker %v: %v", ticker.Station, name, err)
+			return
+		}
+		req := make([]queryItem, len(ticker.Items))
+		for i, item := range ticker.Items {
+			req[i] = queryItem{Quantity: 1, ItemName: item}
+		}
+		response := struct {
+			Status string                   `json:"status"`
+			Ticker tickerInfo               `json:"ticker"`
+			Prices *map[string]responseItem `json:"prices"`
+		}{
+			Status: "OK",
+			Ticker: tickerInfo{
+				Name:        name,
+				Ticker:      *ticker,
+				StationName: scope.Name,
+			},
+			Prices: getItemPrices(sde, mkt, &req, scope, defaultPricing),
+		}
+		responseJSON, _ := json.Marshal(&response)
+		// Write output to cache as well.
+		cache.Put(key, responseJSON, time.Now().Add(tickerCacheTTL))
+		w.Write(responseJSON)
+	}
+}
+
+// TickerHandlers returns web handler functions that list the tickers available
+// to the current user (the passed configured tickers and the user's own),
+// create or replace one of the user's tickers from the request body, and
+// delete one. Users can't define tickers with the same name as a configured
+// one.
+func TickerHandlers(sde evego.Database, localdb db.LocalDB, xmlAPI evego.XMLAPI,
+	sess server.Sessionizer, tickers map[string]Ticker) (list, save, remove web.HandlerFunc) {
+	list = func(c web.C, w http.ResponseWriter, r *http.Request) {
+		s := sess.GetSession(&c, w, r)
+		names := make(map[int]string)
+		infos := make(tickerInfos, 0, len(tickers))
+		for name, t := range tickers {
+			infos = append(infos, tickerInfo{
+				Name:        name,
+				Ticker:      t,
+				StationName: stationName(sde, xmlAPI, names, t.Station),
+				Configured:  true,
+			})
+		}
+		if s.User != 0 {
+			stored, err := localdb.UserTickers(s.User)
+			if err != nil {
+				http.Error(w, `{"status": "Error", "error": "Unable to access database."}`,
+					http.StatusInternalServerError)
+				log.Printf("Unable to get tickers for user %v: %v", s.User, err)
+				return
+			}
+			for _, t := range stored {
+				info := tickerInfo{
+					Name:        t.Name,
+					Ticker:      Ticker{Station: t.StationID},
+					StationName: stationName(sde, xmlAPI, names, t.StationID),
+				}
+				err = json.Unmarshal(t.Items, &info.Items)
+				if err != nil {
+					log.Printf("Unable to parse items of ticker %v: %v", t.Name, err)
+					continue
+				}
+				infos = append(infos, info)
+			}
+		}
+		sort.Sort(infos)
+		response := struct {
+			Status  string      `json:"status"`
+			Tickers tickerInfos `json:"tickers"`
+		}{"OK", infos}
+		responseJSON, _ := json.Marshal(&response)
+		w.Write(responseJSON)
+	}
+
+	save = func(c web.C, w http.ResponseWriter, r *http.Request) {
+		s := sess.GetSession(&c, w, r)
+		if s.User == 0 {
+			http.Error(w, `{"status": "Error", "error": "You must be logged in to save tickers."}`,
+				http.StatusUnauthorized)
+			return
+		}
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, `{"status": "Error", "error": "Unable to read request."}`,
+				http.StatusBadRequest)
+			return
+		}
+		var req tickerInfo
+		err = json.Unmarshal(body, &req)
+		if err != nil || req.Name == "" || len(req.Items) == 0 {
+			http.Error(w, `{"status": "Error", "error": "A ticker needs a name and items."}`,
+				http.StatusBadRequest)
+			return
+		}
+		if _, found := tickers[req.Name]; found {
+			http.Error(w, `{"status": "Error", "error": "That ticker name is reserved."}`,
+				http.StatusBadRequest)
+			return
+		}
+		if len(req.Items) > maxMarginItems {
+			http.Error(w, `{"status": "Error", "error": "Too many items in ticker."}`,
+				http.StatusBadRequest)
+			return
+		}
+		if req.Station == 0 {
+			req.Station = jitaStationID
+		}
+		stn, err := findStation(sde, xmlAPI, req.Station)
+		if err != nil {
+			http.Error(w, `{"status": "Error", "error": "Unable to identify location"}`,
+				http.StatusBadRequest)
+			return
+		}
+		// Store canonical item names.
+		items := make([]string, 0, len(req.Items))
+		for _, name := range req.Items {
+			item, err := sde.ItemForName(name)
+			if err != nil {
+				errJSON, _ := json.Marshal(struct {
+					Status string `json:"status"`
+					Error  string `json:"error"`
+				}{"Error", "Unknown item " + name})
+				http.Error(w, string(errJSON), http.StatusBadRequest)
+				return
+			}
+			items = append(items, item.Name)
+		}
+		itemsJSON, _ := json.Marshal(items)
+		err = localdb.SaveTicker(&db.UserTicker{
+			UserID:    s.User,
+			Name:      req.Name,
+			StationID: req.Station,
+			Items:     itemsJSON,
+		})
+		if err != nil {
+			http.Error(w, `{"status": "Error", "error": "Unable to save ticker."}`,
+				http.StatusInternalServerError)
+			log.Printf("Unable to save ticker for user %v: %v", s.User, err)
+			return
+		}
+		response := struct {
+			Status string     `json:"status"`
+			Ticker tickerInfo `json:"ticker"`
+		}{
+			Status: "OK",
+			Ticker: tickerInfo{
+				Name:        req.Name,
+				Ticker:      Ticker{Station: req.Station, Items: items},
+				StationName: stn.Name,
+			},
+		}
+		responseJSON, _ := json.Marshal(&response)
+		w.Write(responseJSON)
+	}
+
+	remove = func(c web.C, w http.ResponseWriter, r *http.Request) {
+		s := sess.GetSession(&c, w, r)
+		if s.User == 0 {
+			http.Error(w, `{"status": "Error", "error": "You must be logged in to delete tickers."}`,
+				http.StatusUnauthorized)
+			return
+		}
+		name := c.URLParams["name"]
+		err := localdb.DeleteTicker(s.User, name)
+		if err != nil {
+			http.Error(w, `{"status": "Error", "error": "Unable to delete ticker."}`,
+				http.StatusInternalServerError)
+			log.Printf("Unable to delete ticker %v: %v", name, err)
+			return
+		}
+		w.Write([]byte(`{"status": "OK"}`))
+	}
+
+	return
+}
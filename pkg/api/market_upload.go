@@ -0,0 +1,129 @@
+/*
+Copyright © 2014–6 Brad Ackerman.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/backerman/evego"
+	"github.com/backerman/eveindy/pkg/db"
+	"github.com/backerman/eveindy/pkg/orderbook"
+	"github.com/zenazn/goji/web"
+)
+
+// uploadKeyService is the name under which uploaders list our key among the
+// upload keys in their uploads.
+const uploadKeyService = "eveindy"
+
+// uploaderForKey returns the name of the uploader a key belongs to, or the
+// empty string if it isn't one of ours.
+func uploaderForKey(keys map[string]string, key string) string {
+	if key == "" {
+		return ""
+	}
+	for name, k := range keys {
+		if subtle.ConstantTimeCompare([]byte(k), []byte(key)) == 1 {
+			return name
+		}
+	}
+	return ""
+}
+
+// uploadError reports an upload or snapshot that couldn't be read.
+func uploadError(w http.ResponseWriter, err error) {
+	errJSON, _ := json.Marshal(struct {
+		Status string `json:"status"`
+		Error  string `json:"error"`
+	}{"Error", err.Error()})
+	http.Error(w, string(errJSON), http.StatusBadRequest)
+}
+
+// UploadMarketOrders returns a web handler function that accepts order uploads
+// from uploader tools in the unified uploader format (see the README). The
+// uploader's key, one of those in keys, must be given either in the
+// X-Upload-Key header or as the upload key named "eveindy" in the upload; a
+// key in the header is checked before the upload is read, and one in the
+// upload before its orders are checked.
+// Each rowset replaces the local market's orders for its type and region,
+// unless we already have orders generated at the same time or later. The
+// upload is stored as a whole or not at all.
+func UploadMarketOrders(sde evego.Database, localdb db.LocalDB, keys map[string]string) web.HandlerFunc {
+	return func(c web.C, w http.ResponseWriter, r *http.Request) {
+		var uploader string
+		if key := r.Header.Get("X-Upload-Key"); key != "" {
+			uploader = uploaderForKey(keys, key)
+			if uploader == "" {
+				http.Error(w, `{"status": "Error", "error": "A valid upload key is required."}`,
+					http.StatusUnauthorized)
+				return
+			}
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, maxUploadBytes)
+		upload, err := orderbook.DecodeUpload(r.Body)
+		if err != nil {
+			uploadError(w, err)
+			return
+		}
+		for _, k := range upload.Keys {
+			if uploader == "" && k.Name == uploadKeyService {
+				uploader = uploaderForKey(keys, k.Key)
+			}
+		}
+		if uploader == "" {
+			http.Error(w, `{"status": "Error", "error": "A valid upload key is required."}`,
+				http.StatusUnauthorized)
+			return
+		}
+		rowsets, err := upload.Rowsets(sde)
+		if err != nil {
+			uploadError(w, err)
+			return
+		}
+
+		replaced, err := localdb.ReplaceMarketOrders(rowsets, uploader)
+		if err != nil {
+			http.Error(w, `{"status": "Error", "error": "Unable to store orders."}`,
+				http.StatusInternalServerError)
+			log.Printf("Unable to store %d rowsets from %v: %v", len(rowsets), uploader, err)
+			return
+		}
+		var accepted, stale, orders int
+		for i, rs := range rowsets {
+			if replaced[i] {
+				accepted++
+				orders += len(rs.Orders)
+			} else {
+				stale++
+			}
+		}
+		log.Printf("Uploader %v sent %d orders in %d rowsets (%d stale)",
+			uploader, orders, accepted, stale)
+		response := struct {
+			Status   string `json:"status"`
+			Accepted int    `json:"accepted"`
+			Stale    int    `json:"stale"`
+			Orders   int    `json:"orders"`
+		}{"OK", accepted, stale, orders}
+		responseJSON, _ := json.Marshal(&response)
+		w.Write(responseJSON)
+	}
+}
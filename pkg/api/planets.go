@@ -0,0 +1,218 @@
+/*
+Copyright © 2014–6 Brad Ackerman.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/backerman/evego"
+	"github.com/backerman/eveindy/pkg/db"
+	"github.com/backerman/eveindy/pkg/server"
+	"github.com/zenazn/goji/web"
+)
+
+// planetFlow is the hourly movement of one commodity in a colony.
+type planetFlow struct {
+	TypeID    int     `json:"typeID"`
+	TypeName  string  `json:"typeName"`
+	Extracted float64 `json:"extracted"`
+	Produced  float64 `json:"produced"`
+	Consumed  float64 `json:"consumed"`
+	// Net is the quantity left over each hour after feeding the colony's
+	// factories; negative if they need more than the colony makes.
+	Net float64 `json:"net"`
+	// Value is the value of the net output at the selected sell price.
+	Value priceFloat `json:"value"`
+}
+
+type planetFlows []planetFlow
+
+func (f planetFlows) Len() int           { return len(f) }
+func (f planetFlows) Swap(i, j int)      { f[i], f[j] = f[j], f[i] }
+func (f planetFlows) Less(i, j int) bool { return f[i].TypeName < f[j].TypeName }
+
+type colonySummary struct {
+	CharacterName   string `json:"characterName"`
+	PlanetID        int    `json:"planetID"`
+	PlanetName      string `json:"planetName"`
+	PlanetTypeName  string `json:"planetTypeName"`
+	SolarSystemName string `json:"solarSystemName"`
+	// ActiveExtractors and ExpiredExtractors count the extractors that are
+	// and aren't running programs.
+	ActiveExtractors  int `json:"activeExtractors"`
+	ExpiredExtractors int `json:"expiredExtractors"`
+	// NextExpiry is when the first running extraction program will end.
+	NextExpiry *time.Time  `json:"nextExpiry,omitempty"`
+	Factories  int         `json:"factories"`
+	Flows      planetFlows `json:"flows"`
+	// HourlyValue is the value of the colony's net output per hour.
+	HourlyValue priceFloat `json:"hourlyValue"`
+}
+
+// summarizeColony works out the hourly extraction and factory throughput of a
+// colony. Factories are assumed to be fully supplied.
+func summarizeColony(colony *db.Colony, schematics map[int][]db.SchematicFlow, now time.Time) colonySummary {
+	summary := colonySummary{
+		CharacterName:   colony.CharacterName,
+		PlanetID:        colony.PlanetID,
+		PlanetName:      colony.PlanetName,
+		PlanetTypeName:  colony.PlanetTypeName,
+		SolarSystemName: colony.SolarSystemName,
+	}
+	flows := make(map[int]*planetFlow)
+	flowFor := func(typeID int, typeName string) *planetFlow {
+		f, found := flows[typeID]
+		if !found {
+			f = &planetFlow{TypeID: typeID, TypeName: typeName}
+			flows[typeID] = f
+		}
+		return f
+	}
+	for _, pin := range colony.Pins {
+		switch {
+		case pin.IsExtractor():
+			if pin.ExpiryTime == nil || !pin.ExpiryTime.After(now) {
+				summary.ExpiredExtractors++
+				continue
+			}
+			summary.ActiveExtractors++
+			if summary.NextExpiry == nil || pin.ExpiryTime.Before(*summary.NextExpiry) {
+				summary.NextExpiry = pin.ExpiryTime
+			}
+			// Extractor cycle times are in minutes.
+			perHour := float64(pin.QuantityPerCycle) * 60.0 / float64(pin.CycleTime)
+			flowFor(pin.ContentTypeID, pin.ContentTypeName).Extracted += perHour
+		case pin.SchematicID != 0:
+			schematic, found := schematics[pin.SchematicID]
+			if !found {
+				log.Printf("Unknown planetary schematic %v on pin %v", pin.SchematicID, pin.PinID)
+				continue
+			}
+			summary.Factories++
+			for _, sf := range schematic {
+				// Schematic cycle times are in seconds.
+				perHour := float64(sf.Quantity) * 3600.0 / float64(sf.CycleTime)
+				if sf.IsInput {
+					flowFor(sf.TypeID, sf.TypeName).Consumed += perHour
+				} else {
+					flowFor(sf.TypeID, sf.TypeName).Produced += perHour
+				}
+			}
+		}
+	}
+	summary.Flows = make(planetFlows, 0, len(flows))
+	for _, f := range flows {
+		f.Net = f.Extracted + f.Produced - f.Consumed
+		summary.Flows = append(summary.Flows, *f)
+	}
+	sort.Sort(summary.Flows)
+	return summary
+}
+
+// PlanetaryInteraction returns a web handler function that summarizes the
+// hourly output of the planetary colonies of one of the user's characters (or
+// all of them, if no character is specified). Output is valued at the
+// location given by the station or region query parameter and with the
+// pricing method given by the method and topPct parameters, as with
+// contracts.
+func PlanetaryInteraction(sde evego.Database, localdb db.LocalDB, mkt evego.Market,
+	xmlAPI evego.XMLAPI, router evego.Router, sess server.Sessionizer) web.HandlerFunc {
+	return func(c web.C, w http.ResponseWriter, r *http.Request) {
+		s := sess.GetSession(&c, w, r)
+		myUserID := s.User
+		var charID int
+		if charIDStr, found := c.URLParams["charID"]; found {
+			var err error
+			charID, err = strconv.Atoi(charIDStr)
+			if err != nil {
+				http.Error(w, `{"status": "Error", "error": "Invalid character ID supplied."}`,
+					http.StatusBadRequest)
+				return
+			}
+		}
+		scope, err := pricingLocation(sde, xmlAPI, router, r)
+		if err != nil {
+			http.Error(w, `{"status": "Error", "error": "Unable to identify location"}`,
+				http.StatusBadRequest)
+			return
+		}
+		pricing, err := requestPricing(r)
+		if err != nil {
+			http.Error(w, `{"status": "Error", "error": "Invalid pricing method"}`,
+				http.StatusBadRequest)
+			return
+		}
+		colonies, err := localdb.CharacterColonies(myUserID, charID)
+		if err != nil {
+			http.Error(w, `{"status": "Error", "error": "Unable to access database."}`,
+				http.StatusInternalServerError)
+			log.Printf("Error getting colonies with user %v, character %v: %v", myUserID, charID, err)
+			return
+		}
+		schematics, err := localdb.PlanetSchematics()
+		if err != nil {
+			http.Error(w, `{"status": "Error", "error": "Unable to access database."}`,
+				http.StatusInternalServerError)
+			log.Printf("Error getting planetary schematics: %v", err)
+			return
+		}
+
+		now := time.Now()
+		summaries := make([]colonySummary, 0, len(colonies))
+		var toPrice []queryItem
+		seen := make(map[string]bool)
+		for i := range colonies {
+			summary := summarizeColony(&colonies[i], schematics, now)
+			for _, f := range summary.Flows {
+				if f.Net > 0 && !seen[f.TypeName] {
+					seen[f.TypeName] = true
+					toPrice = append(toPrice, queryItem{Quantity: 1, ItemName: f.TypeName})
+				}
+			}
+			summaries = append(summaries, summary)
+		}
+		prices := getItemPrices(sde, mkt, &toPrice, scope, pricing)
+		var total priceFloat
+		for i := range summaries {
+			summary := &summaries[i]
+			for j := range summary.Flows {
+				f := &summary.Flows[j]
+				if f.Net <= 0 {
+					continue
+				}
+				f.Value = priceFloat(f.Net) * (*prices)[f.TypeName].SellPrice
+				summary.HourlyValue += f.Value
+			}
+			total += summary.HourlyValue
+		}
+
+		response := struct {
+			Status      string          `json:"status"`
+			Colonies    []colonySummary `json:"colonies"`
+			HourlyValue priceFloat      `json:"hourlyValue"`
+		}{"OK", summaries, total}
+		responseJSON, _ := json.Marshal(&response)
+		w.Write(responseJSON)
+	}
+}
@@ -0,0 +1,71 @@
+/*
+Copyright © 2014–6 Brad Ackerman.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/backerman/eveindy/pkg/marketstats"
+)
+
+// pricingOptions selects how a single buy and sell price is derived from an
+// item's order book.
+type pricingOptions struct {
+	Method     marketstats.PriceMethod `json:"method"`
+	TopPercent float64                 `json:"topPercent"`
+}
+
+var defaultPricing = pricingOptions{
+	Method:     marketstats.MethodBest,
+	TopPercent: marketstats.DefaultTopPercent,
+}
+
+// makePricingOptions validates a pricing method name and top percentage as
+// passed by a client; empty or zero values select the defaults.
+func makePricingOptions(method string, topPct float64) (pricingOptions, error) {
+	opts := defaultPricing
+	var err error
+	opts.Method, err = marketstats.ParseMethod(method)
+	if err != nil {
+		return opts, err
+	}
+	if topPct != 0 {
+		if topPct < 0 || topPct > 100 {
+			return opts, fmt.Errorf("Invalid top percentage %v", topPct)
+		}
+		opts.TopPercent = topPct
+	}
+	return opts, nil
+}
+
+// requestPricing reads the pricing options from a request's method and topPct
+// query parameters.
+func requestPricing(r *http.Request) (pricingOptions, error) {
+	query := r.URL.Query()
+	var topPct float64
+	if t := query.Get("topPct"); t != "" {
+		var err error
+		topPct, err = strconv.ParseFloat(t, 64)
+		if err != nil {
+			return defaultPricing, err
+		}
+	}
+	return makePricingOptions(query.Get("method"), topPct)
+}
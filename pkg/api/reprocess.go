@@ -27,6 +27,8 @@ import (
 
 	"github.com/backerman/evego"
 	"github.com/backerman/evego/pkg/industry"
+	"github.com/backerman/eveindy/pkg/db"
+	"github.com/backerman/eveindy/pkg/server"
 	"github.com/zenazn/goji/web"
 )
 
@@ -36,21 +38,50 @@ type reproItem struct {
 }
 
 type reproQuery struct {
-	StationYield    float64     `json:"stationYield"`
-	TaxRate         float64     `json:"taxRate"`
-	ScrapmetalSkill int         `json:"scrapmetalReprocessingSkill"`
-	Items           []reproItem `json:"items"`
+	// The base yield is that of the station or outpost FacilityID, or of
+	// Facility if it's set; StationYield is only used if neither is.
+	FacilityID   int       `json:"facilityID"`
+	Facility     *facility `json:"facility"`
+	StationYield float64   `json:"stationYield"`
+	TaxRate      float64   `json:"taxRate"`
+	// Skills are the reprocessing skills to use, unless CharacterID is set, in
+	// which case that stored character's skills are. If neither is,
+	// ScrapmetalSkill is used on its own.
+	Skills          *reproSkillSet `json:"skills"`
+	CharacterID     int            `json:"characterID"`
+	ScrapmetalSkill int            `json:"scrapmetalReprocessingSkill"`
+	// Implant is the bonus in percent of the reprocessing implant worn, if
+	// any.
+	Implant float64     `json:"implant"`
+	Items   []reproItem `json:"items"`
+	// PriceMethod and TopPercent select how output is priced; see
+	// marketstats.PriceMethod.
+	PriceMethod string  `json:"priceMethod"`
+	TopPercent  float64 `json:"topPercent"`
 }
 
 type reproResults struct {
 	Items  map[string][]evego.InventoryLine `json:"items"`
 	Prices map[string]responseItem          `json:"prices"`
+	// Yields are the fraction of each item's materials recovered, and Skills
+	// the skills used to compute them.
+	Yields map[string]float64 `json:"yields"`
+	Skills reproSkillSet      `json:"skills"`
+	// Facility is where the items were reprocessed, if one was given.
+	Facility *facility `json:"facility,omitempty"`
 }
 
 // ReprocessItems returns a handler function that takes as input an item list
-// and returns the reprocessing output of each inventory line.
-func ReprocessItems(db evego.Database, mkt evego.Market) web.HandlerFunc {
-	jita, err := db.StationForID(60003760) // Jita IV - Moon 4 - Caldari Navy Assembly Plant
+// and returns the reprocessing output of each inventory line, with yields
+// computed from the skills supplied or those of one of the current user's
+// characters.
+func ReprocessItems(db evego.Database, localdb db.LocalDB, mkt evego.Market, xmlAPI evego.XMLAPI,
+	router evego.Router, sess server.Sessionizer) web.HandlerFunc {
+	jitaStation, err := db.StationForID(jitaStationID)
+	if err != nil {
+		log.Fatalf("Seriously, guys, something's gone wrong with the database!")
+	}
+	jita, err := stationScope(db, router, jitaStation)
 	if err != nil {
 		log.Fatalf("Seriously, guys, something's gone wrong with the database!")
 	}
@@ -81,19 +112,82 @@ func ReprocessItems(db evego.Database, mkt evego.Market) web.HandlerFunc {
 			return
 		}
 
+		pricing, err := makePricingOptions(req.PriceMethod, req.TopPercent)
+		if err != nil {
+			http.Error(w, `{"status": "Error", "error": "Invalid pricing method"}`,
+				http.StatusBadRequest)
+			return
+		}
+
+		var skills reproSkillSet
+		switch {
+		case req.CharacterID != 0:
+			s := sess.GetSession(&c, w, r)
+			if s.User == 0 {
+				http.Error(w, `{"status": "Error", "error": "You must be logged in to use a character's skills."}`,
+					http.StatusUnauthorized)
+				return
+			}
+			skills, err = characterReproSkills(localdb, s.User, req.CharacterID)
+			if err != nil {
+				http.Error(w, `{"status": "Error", "error": "Unable to access database."}`,
+					http.StatusInternalServerError)
+				log.Printf("Unable to get reprocessing skills for character %v: %v", req.CharacterID, err)
+				return
+			}
+		case req.Skills != nil:
+			skills = *req.Skills
+		default:
+			skills.ScrapmetalProcessing = req.ScrapmetalSkill
+		}
+		if err = skills.validate(); err != nil {
+			errJSON, _ := json.Marshal(struct {
+				Status string `json:"status"`
+				Error  string `json:"error"`
+			}{"Error", err.Error()})
+			http.Error(w, string(errJSON), http.StatusBadRequest)
+			return
+		}
+		switch req.Implant {
+		case 0, 1, 2, 4:
+		default:
+			http.Error(w, `{"status": "Error", "error": "Implant bonus must be 0, 1, 2, or 4 percent."}`,
+				http.StatusBadRequest)
+			return
+		}
+
 		// Convert 0..100 scale to 0..1.
 		stationYield := math.Max(math.Min(1, req.StationYield*0.01), 0)
-		taxRate := math.Max(math.Min(1, req.TaxRate*0.01), 0)
-		reproSkills := industry.ReproSkills{
-			ScrapmetalProcessing: req.ScrapmetalSkill,
+		fac := req.Facility
+		if fac == nil && req.FacilityID != 0 {
+			fac, err = facilityForStation(db, xmlAPI, req.FacilityID)
+		} else if fac != nil {
+			err = fac.resolve(db, xmlAPI)
 		}
+		if err != nil {
+			errJSON, _ := json.Marshal(struct {
+				Status string `json:"status"`
+				Error  string `json:"error"`
+			}{"Error", err.Error()})
+			http.Error(w, string(errJSON), http.StatusBadRequest)
+			return
+		}
+		if fac != nil {
+			stationYield = fac.BaseYield
+		}
+		taxRate := math.Max(math.Min(1, req.TaxRate*0.01), 0)
 		results := make(map[string][]evego.InventoryLine)
+		yields := make(map[string]float64)
 		for _, i := range req.Items {
 			item, err := db.ItemForName(i.ItemName)
 			if err != nil {
 				continue
 			}
-			itemResults, err := industry.ReprocessItem(db, item, i.Quantity, stationYield, taxRate, reproSkills)
+			// The yield already includes the effect of skills and implant
+			// (see reproSkillSet), so none are passed on.
+			yields[item.Name] = skills.yield(item, stationYield, req.Implant)
+			itemResults, err := industry.ReprocessItem(db, item, i.Quantity, yields[item.Name],
+				taxRate, industry.ReproSkills{})
 			if err != nil {
 				http.Error(w, "Unable to compute reprocessing output", http.StatusInternalServerError)
 				w.Write([]byte(`{"status": "Error"}`))
@@ -101,31 +195,26 @@ func ReprocessItems(db evego.Database, mkt evego.Market) web.HandlerFunc {
 			}
 			results[item.Name] = itemResults
 		}
-		prices := make(map[string]responseItem)
-
-		// Loop over each item that was reprocessed.
+		// Get the Jita price of each distinct material produced, all at once.
+		var toPrice []queryItem
+		seen := make(map[string]bool)
 		for _, itemOut := range results {
-			// For each item in its component materials,
 			for _, item := range itemOut {
-				// Check if we already know its price in Jita
 				itemName := item.Item.Name
-				_, found := prices[itemName]
-				if !found {
-					// If not there, get its price.
-					myPrices, err := getItemPrices(db, mkt, &[]queryItem{{Quantity: 1, ItemName: itemName}}, jita, "")
-					if err != nil {
-						http.Error(w, `{"status": "Error", "error": "Unable to look up prices (reprocessing)"}`,
-							http.StatusInternalServerError)
-						return
-					}
-					prices[itemName] = (*myPrices)[itemName]
+				if !seen[itemName] {
+					seen[itemName] = true
+					toPrice = append(toPrice, queryItem{Quantity: 1, ItemName: itemName})
 				}
 			}
 		}
+		prices := getItemPrices(db, mkt, &toPrice, jita, pricing)
 
 		response := reproResults{
-			Items:  results,
-			Prices: prices,
+			Items:    results,
+			Prices:   *prices,
+			Yields:   yields,
+			Skills:   skills,
+			Facility: fac,
 		}
 		resultsJSON, _ := json.Marshal(response)
 		w.Write(resultsJSON)
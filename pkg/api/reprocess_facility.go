@@ -0,0 +1,180 @@
+/*
+Copyright © 2014–6 Brad Ackerman.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package api
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/backerman/evego"
+)
+
+// The kinds of facility items can be reprocessed at.
+const (
+	facilityStation   = "station"
+	facilityOutpost   = "outpost"
+	facilityStructure = "structure"
+)
+
+// The security classes of the system a facility is in.
+const (
+	securityHigh = "highsec"
+	securityLow  = "lowsec"
+	securityNull = "nullsec"
+)
+
+// Outposts' yield isn't in the SDE; they start at a basic station's and gain
+// for each level of refinery upgrade installed.
+const (
+	outpostBaseYield       = 0.50
+	outpostUpgradeYield    = 0.02
+	maxOutpostUpgradeLevel = 3
+)
+
+// structureBaseYield is the yield of a player structure before its rig and
+// bonuses.
+const structureBaseYield = 0.50
+
+// structureRigYield is the yield added by a reprocessing rig of each tech
+// level (with none fitted at 0).
+var structureRigYield = []float64{0, 0.01, 0.03}
+
+// structureBonus is the yield bonus of each type of structure that can fit a
+// reprocessing facility: the citadels have none, and the refineries, built
+// for the job, have one.
+var structureBonus = map[string]float64{
+	"astrahus": 0,
+	"fortizar": 0,
+	"keepstar": 0,
+	"athanor":  0.02,
+	"tatara":   0.055,
+}
+
+// securityBonus is the yield bonus of a structure in a system of each
+// security class.
+var securityBonus = map[string]float64{
+	securityHigh: 0,
+	securityLow:  0.06,
+	securityNull: 0.12,
+}
+
+// facility is a place to reprocess items. Clients send its kind and the
+// fields that apply to it; the rest are filled in by resolve.
+type facility struct {
+	Kind string `json:"kind"`
+	// StationID is the station or outpost, and SystemID the system a
+	// structure is in.
+	StationID int `json:"stationID,omitempty"`
+	SystemID  int `json:"systemID,omitempty"`
+	// RefineryUpgrade is the level (0 to 3) of an outpost's refinery upgrade.
+	RefineryUpgrade int `json:"refineryUpgrade,omitempty"`
+	// Structure is the type of a structure, as in "tatara"; Rig is the tech
+	// level of its reprocessing rig, or 0 if none is fitted.
+	Structure string `json:"structure,omitempty"`
+	Rig       int    `json:"rig,omitempty"`
+
+	Name               string  `json:"name"`
+	SystemName         string  `json:"systemName"`
+	Security           float64 `json:"security"`
+	SecurityClass      string  `json:"securityClass"`
+	SecurityMultiplier float64 `json:"securityMultiplier"`
+	BaseYield          float64 `json:"baseYield"`
+}
+
+// securityClass returns the security class of a system, going by its
+// security status as displayed in the client.
+func securityClass(system *evego.SolarSystem) (float64, string) {
+	security := roundSecurity(system.Security)
+	if system.Security > 0.0 && system.Security < 0.05 {
+		// lowsec, not nullsec—rounds up.
+		security = 0.1
+	}
+	switch {
+	case security >= 0.5:
+		return security, securityHigh
+	case security > 0.0:
+		return security, securityLow
+	}
+	return security, securityNull
+}
+
+// facilityForStation returns the facility for an NPC station or outpost.
+func facilityForStation(sde evego.Database, xmlAPI evego.XMLAPI, stationID int) (*facility, error) {
+	f := &facility{Kind: facilityStation, StationID: stationID}
+	stn, err := sde.StationForID(stationID)
+	if err != nil || stn.ReprocessingEfficiency == 0.0 {
+		f.Kind = facilityOutpost
+	}
+	err = f.resolve(sde, xmlAPI)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// resolve validates a facility and works out its location and base yield.
+// Security applies to a structure's yield; stations and outposts carry their
+// security class but aren't affected by it.
+func (f *facility) resolve(sde evego.Database, xmlAPI evego.XMLAPI) error {
+	systemID := f.SystemID
+	switch f.Kind {
+	case facilityStation:
+		stn, err := sde.StationForID(f.StationID)
+		if err != nil {
+			return fmt.Errorf("Unknown station %v", f.StationID)
+		}
+		f.Name, systemID = stn.Name, stn.SystemID
+		f.BaseYield = stn.ReprocessingEfficiency
+	case facilityOutpost:
+		stn, err := xmlAPI.OutpostForID(f.StationID)
+		if err != nil {
+			return fmt.Errorf("Unknown outpost %v", f.StationID)
+		}
+		if f.RefineryUpgrade < 0 || f.RefineryUpgrade > maxOutpostUpgradeLevel {
+			return fmt.Errorf("Refinery upgrade level must be between 0 and %d", maxOutpostUpgradeLevel)
+		}
+		f.Name, systemID = stn.Name, stn.SystemID
+		f.BaseYield = outpostBaseYield + outpostUpgradeYield*float64(f.RefineryUpgrade)
+	case facilityStructure:
+		f.Structure = strings.ToLower(f.Structure)
+		if _, found := structureBonus[f.Structure]; !found {
+			return fmt.Errorf("Unknown structure type %q", f.Structure)
+		}
+		if f.Rig < 0 || f.Rig >= len(structureRigYield) {
+			return fmt.Errorf("Rig must be 0 (none), 1 (T1), or 2 (T2)")
+		}
+	default:
+		return fmt.Errorf("Facility must be a station, outpost, or structure")
+	}
+	system, err := sde.SolarSystemForID(systemID)
+	if err != nil {
+		return fmt.Errorf("Unknown solar system %v", systemID)
+	}
+	f.SystemID, f.SystemName = system.ID, system.Name
+	f.Security, f.SecurityClass = securityClass(system)
+	f.SecurityMultiplier = 1
+	if f.Kind == facilityStructure {
+		f.SecurityMultiplier = 1 + securityBonus[f.SecurityClass]
+		if f.Name == "" {
+			f.Name = fmt.Sprintf("Structure in %v", system.Name)
+		}
+		f.BaseYield = (structureBaseYield + structureRigYield[f.Rig]) *
+			f.SecurityMultiplier * (1 + structureBonus[f.Structure])
+	}
+	return nil
+}
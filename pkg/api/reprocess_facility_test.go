@@ -0,0 +1,201 @@
+/*
+Copyright © 2014–6 Brad Ackerman.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package api
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/backerman/evego"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// Systems of each security class used to test facilities.
+const (
+	highSystemID = 30000142 // Jita, 0.9
+	lowSystemID  = 30002813 // Tama, 0.3
+	zeroSystemID = 30001000 // exactly 0.0
+	nullSystemID = 30004759 // 1DQ1-A, -0.4
+
+	outpostID = 61000001
+)
+
+var facilitySystems = map[int]*evego.SolarSystem{
+	highSystemID: {ID: highSystemID, Name: "Jita", Security: 0.946},
+	lowSystemID:  {ID: lowSystemID, Name: "Tama", Security: 0.3},
+	zeroSystemID: {ID: zeroSystemID, Name: "Zero", Security: 0.0},
+	nullSystemID: {ID: nullSystemID, Name: "1DQ1-A", Security: -0.4},
+}
+
+var errNotFound = errors.New("Not found")
+
+// facilitySDE and facilityXMLAPI answer the lookups made when resolving a
+// facility.
+type facilitySDE struct {
+	evego.Database
+}
+
+func (facilitySDE) StationForID(stationID int) (*evego.Station, error) {
+	if stationID != jitaStationID {
+		return nil, errNotFound
+	}
+	return &evego.Station{
+		ID:                     jitaStationID,
+		Name:                   "Jita IV - Moon 4 - Caldari Navy Assembly Plant",
+		SystemID:               highSystemID,
+		ReprocessingEfficiency: 0.5,
+	}, nil
+}
+
+func (facilitySDE) SolarSystemForID(systemID int) (*evego.SolarSystem, error) {
+	system, found := facilitySystems[systemID]
+	if !found {
+		return nil, errNotFound
+	}
+	return system, nil
+}
+
+type facilityXMLAPI struct {
+	evego.XMLAPI
+}
+
+func (facilityXMLAPI) OutpostForID(stationID int) (*evego.Station, error) {
+	if stationID != outpostID {
+		return nil, errNotFound
+	}
+	return &evego.Station{ID: outpostID, Name: "1DQ1-A - Outpost", SystemID: nullSystemID}, nil
+}
+
+func TestSecurityClass(t *testing.T) {
+	Convey("Verify the security class of systems", t, func() {
+		cases := []struct {
+			desc     string
+			security float64
+			rounded  float64
+			class    string
+		}{
+			{"High security is 0.5 and above", 0.946, 0.9, securityHigh},
+			{"Rounding up to 0.5 is high security", 0.46, 0.5, securityHigh},
+			{"Rounding down from 0.5 is low security", 0.44, 0.4, securityLow},
+			{"Low security goes down to 0.1", 0.1, 0.1, securityLow},
+			{"Just above 0.0 rounds up to low security", 0.01, 0.1, securityLow},
+			{"0.0 is null security", 0.0, 0.0, securityNull},
+			{"Negative security is null security", -0.4, -0.4, securityNull},
+		}
+		for _, tc := range cases {
+			Convey(tc.desc, func() {
+				security, class := securityClass(&evego.SolarSystem{Security: tc.security})
+				So(security, ShouldAlmostEqual, tc.rounded)
+				So(class, ShouldEqual, tc.class)
+			})
+		}
+	})
+}
+
+func TestFacilityResolve(t *testing.T) {
+	Convey("Verify resolving reprocessing facilities", t, func() {
+		sde, xmlAPI := facilitySDE{}, facilityXMLAPI{}
+
+		Convey("A station's yield is its own, whatever its security", func() {
+			f := &facility{Kind: facilityStation, StationID: jitaStationID}
+			So(f.resolve(sde, xmlAPI), ShouldBeNil)
+			So(f.SystemID, ShouldEqual, highSystemID)
+			So(f.SystemName, ShouldEqual, "Jita")
+			So(f.SecurityClass, ShouldEqual, securityHigh)
+			So(f.SecurityMultiplier, ShouldEqual, 1.0)
+			So(f.BaseYield, ShouldEqual, 0.5)
+		})
+
+		Convey("An outpost gains yield with each refinery upgrade level", func() {
+			yields := []float64{0.50, 0.52, 0.54, 0.56}
+			for level, yield := range yields {
+				f := &facility{Kind: facilityOutpost, StationID: outpostID, RefineryUpgrade: level}
+				So(f.resolve(sde, xmlAPI), ShouldBeNil)
+				So(f.SecurityClass, ShouldEqual, securityNull)
+				So(f.SecurityMultiplier, ShouldEqual, 1.0)
+				So(f.BaseYield, ShouldAlmostEqual, yield)
+			}
+		})
+
+		Convey("A structure's yield depends on its hull, rig and security", func() {
+			cases := []struct {
+				structure string
+				rig       int
+				systemID  int
+				class     string
+				yield     float64
+			}{
+				{"Astrahus", 0, highSystemID, securityHigh, 0.5},
+				{"Astrahus", 0, lowSystemID, securityLow, 0.53},
+				{"Astrahus", 0, nullSystemID, securityNull, 0.56},
+				{"Astrahus", 1, highSystemID, securityHigh, 0.51},
+				{"Astrahus", 1, lowSystemID, securityLow, 0.5406},
+				{"Astrahus", 1, nullSystemID, securityNull, 0.5712},
+				{"Astrahus", 2, highSystemID, securityHigh, 0.53},
+				{"Astrahus", 2, lowSystemID, securityLow, 0.5618},
+				{"Astrahus", 2, nullSystemID, securityNull, 0.5936},
+				{"Athanor", 0, highSystemID, securityHigh, 0.51},
+				{"Athanor", 0, lowSystemID, securityLow, 0.5406},
+				{"Athanor", 0, nullSystemID, securityNull, 0.5712},
+				{"Athanor", 1, highSystemID, securityHigh, 0.5202},
+				{"Athanor", 1, lowSystemID, securityLow, 0.551412},
+				{"Athanor", 1, nullSystemID, securityNull, 0.582624},
+				{"Athanor", 2, highSystemID, securityHigh, 0.5406},
+				{"Athanor", 2, lowSystemID, securityLow, 0.573036},
+				{"Athanor", 2, nullSystemID, securityNull, 0.605472},
+				{"Tatara", 0, highSystemID, securityHigh, 0.5275},
+				{"Tatara", 0, lowSystemID, securityLow, 0.55915},
+				{"Tatara", 0, nullSystemID, securityNull, 0.5908},
+				{"Tatara", 1, highSystemID, securityHigh, 0.53805},
+				{"Tatara", 1, lowSystemID, securityLow, 0.570333},
+				{"Tatara", 1, nullSystemID, securityNull, 0.602616},
+				{"Tatara", 2, highSystemID, securityHigh, 0.55915},
+				{"Tatara", 2, lowSystemID, securityLow, 0.592699},
+				{"Tatara", 2, nullSystemID, securityNull, 0.626248},
+				{"Tatara", 0, zeroSystemID, securityNull, 0.5908},
+			}
+			for _, tc := range cases {
+				f := &facility{Kind: facilityStructure, Structure: tc.structure, Rig: tc.rig,
+					SystemID: tc.systemID}
+				So(f.resolve(sde, xmlAPI), ShouldBeNil)
+				So(f.SecurityClass, ShouldEqual, tc.class)
+				So(f.BaseYield, ShouldAlmostEqual, tc.yield, 0.0000001)
+			}
+		})
+
+		Convey("Invalid facilities are rejected", func() {
+			invalid := []*facility{
+				{Kind: "citadel"},
+				{Kind: facilityStation, StationID: 60000001},
+				{Kind: facilityOutpost, StationID: 61000002},
+				{Kind: facilityOutpost, StationID: outpostID, RefineryUpgrade: -1},
+				{Kind: facilityOutpost, StationID: outpostID, RefineryUpgrade: maxOutpostUpgradeLevel + 1},
+				{Kind: facilityStructure, Structure: "tatara", Rig: -1, SystemID: highSystemID},
+				{Kind: facilityStructure, Structure: "tatara", Rig: 3, SystemID: highSystemID},
+				{Kind: facilityStructure, Structure: "tatara", SystemID: 30009999},
+				{Kind: facilityStructure, Structure: "", SystemID: highSystemID},
+				{Kind: facilityStructure, Structure: "tatarra", SystemID: highSystemID},
+				{Kind: facilityStructure, Structure: "sotiyo", SystemID: highSystemID},
+			}
+			for _, f := range invalid {
+				So(f.resolve(sde, xmlAPI), ShouldNotBeNil)
+			}
+		})
+	})
+}
@@ -0,0 +1,115 @@
+/*
+Copyright © 2014–6 Brad Ackerman.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package api
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/backerman/evego"
+	"github.com/backerman/eveindy/pkg/db"
+)
+
+// resourceProcessingGroupID is the skill group containing the skills that
+// affect reprocessing yield.
+const resourceProcessingGroupID = 1218
+
+// asteroidCategoryID is the category of ores and ice, whose yield depends on
+// the refining skills rather than on Scrapmetal Processing.
+const asteroidCategoryID = 25
+
+// The names of the general reprocessing skills; the ore- and ice-specific
+// ones are named after their item group, as in "Veldspar Processing".
+const (
+	reprocessingSkill           = "Reprocessing"
+	reprocessingEfficiencySkill = "Reprocessing Efficiency"
+	scrapmetalProcessingSkill   = "Scrapmetal Processing"
+	processingSkillSuffix       = " Processing"
+)
+
+// reproSkillSet is the set of skills that affect reprocessing yield. It
+// mirrors industry.ReproSkills, but the yield is worked out here rather than
+// by industry.ReprocessItem: the library has no way to take an implant or a
+// structure's bonuses into account, so we pass it the finished yield as the
+// station's and no skills, which would otherwise be applied twice.
+type reproSkillSet struct {
+	Reprocessing           int `json:"reprocessing"`
+	ReprocessingEfficiency int `json:"reprocessingEfficiency"`
+	ScrapmetalProcessing   int `json:"scrapmetalProcessing"`
+	// OreProcessing holds the ore- and ice-specific processing skills, by
+	// skill name.
+	OreProcessing map[string]int `json:"oreProcessing"`
+}
+
+// validate checks that every skill level is between 0 and 5.
+func (s *reproSkillSet) validate() error {
+	levels := map[string]int{
+		reprocessingSkill:           s.Reprocessing,
+		reprocessingEfficiencySkill: s.ReprocessingEfficiency,
+		scrapmetalProcessingSkill:   s.ScrapmetalProcessing,
+	}
+	for name, level := range s.OreProcessing {
+		levels[name] = level
+	}
+	for name, level := range levels {
+		if level < 0 || level > 5 {
+			return fmt.Errorf("Invalid level %d for %v", level, name)
+		}
+	}
+	return nil
+}
+
+// yield returns the fraction of an item's materials recovered by
+// reprocessing it at a station with the passed base yield. Ore and ice
+// benefit from the refining skills and implant (a bonus in percent);
+// everything else only from Scrapmetal Processing.
+func (s *reproSkillSet) yield(item *evego.Item, stationYield, implant float64) float64 {
+	if item.CategoryID != asteroidCategoryID {
+		return math.Min(1, stationYield*(1+0.02*float64(s.ScrapmetalProcessing)))
+	}
+	oreSkill := s.OreProcessing[item.Group+processingSkillSuffix]
+	y := stationYield *
+		(1 + 0.03*float64(s.Reprocessing)) *
+		(1 + 0.02*float64(s.ReprocessingEfficiency)) *
+		(1 + 0.02*float64(oreSkill)) *
+		(1 + 0.01*implant)
+	return math.Min(1, y)
+}
+
+// characterReproSkills returns the reprocessing skills of a stored character.
+func characterReproSkills(localdb db.LocalDB, userID, charID int) (reproSkillSet, error) {
+	skills := reproSkillSet{OreProcessing: make(map[string]int)}
+	stored, err := localdb.CharacterSkillGroup(userID, charID, resourceProcessingGroupID)
+	if err != nil {
+		return skills, err
+	}
+	for _, skill := range stored {
+		switch {
+		case skill.Name == reprocessingSkill:
+			skills.Reprocessing = skill.Level
+		case skill.Name == reprocessingEfficiencySkill:
+			skills.ReprocessingEfficiency = skill.Level
+		case skill.Name == scrapmetalProcessingSkill:
+			skills.ScrapmetalProcessing = skill.Level
+		case strings.HasSuffix(skill.Name, processingSkillSuffix):
+			skills.OreProcessing[skill.Name] = skill.Level
+		}
+	}
+	return skills, nil
+}
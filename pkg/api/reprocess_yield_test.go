@@ -0,0 +1,72 @@
+/*
+Copyright © 2014–6 Brad Ackerman.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package api
+
+import (
+	"testing"
+
+	"github.com/backerman/evego"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestReproYield(t *testing.T) {
+	Convey("Verify reprocessing yields", t, func() {
+		veldspar := &evego.Item{Name: "Veldspar", Group: "Veldspar", CategoryID: asteroidCategoryID}
+		module := &evego.Item{Name: "Small Shield Extender I", Group: "Shield Extender", CategoryID: 7}
+		maxed := reproSkillSet{
+			Reprocessing:           5,
+			ReprocessingEfficiency: 5,
+			ScrapmetalProcessing:   5,
+			OreProcessing:          map[string]int{"Veldspar Processing": 5},
+		}
+		untrained := reproSkillSet{}
+
+		cases := []struct {
+			desc    string
+			skills  reproSkillSet
+			item    *evego.Item
+			station float64
+			implant float64
+			yield   float64
+		}{
+			{"Untrained ore is the station's yield", untrained, veldspar, 0.5, 0, 0.5},
+			{"Ore benefits from the refining skills", maxed, veldspar, 0.5, 0, 0.5 * 1.15 * 1.1 * 1.1},
+			{"A 1% implant applies to ore", untrained, veldspar, 0.5, 1, 0.505},
+			{"A 2% implant applies to ore", untrained, veldspar, 0.5, 2, 0.51},
+			{"A 4% implant applies to ore", maxed, veldspar, 0.5, 4, 0.5 * 1.15 * 1.1 * 1.1 * 1.04},
+			{"Ore yield is capped at 1", maxed, veldspar, 0.9, 4, 1},
+			{"Other items benefit only from Scrapmetal Processing", maxed, module, 0.5, 4, 0.55},
+			{"Untrained scrapmetal is the station's yield", untrained, module, 0.5, 0, 0.5},
+			{"Scrapmetal yield is capped at 1", maxed, module, 0.95, 0, 1},
+			{
+				"Ore doesn't benefit from Scrapmetal Processing",
+				reproSkillSet{ScrapmetalProcessing: 5}, veldspar, 0.5, 0, 0.5,
+			},
+			{
+				"Ore only benefits from its own processing skill",
+				reproSkillSet{OreProcessing: map[string]int{"Scordite Processing": 5}}, veldspar, 0.5, 0, 0.5,
+			},
+		}
+		for _, tc := range cases {
+			Convey(tc.desc, func() {
+				So(tc.skills.yield(tc.item, tc.station, tc.implant), ShouldAlmostEqual, tc.yield)
+			})
+		}
+	})
+}
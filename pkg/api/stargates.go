@@ -0,0 +1,93 @@
+/*
+Copyright © 2014–6 Brad Ackerman.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package api
+
+import (
+	"github.com/backerman/eveindy/pkg/db"
+)
+
+// stargateMap is the map of stargate connections between solar systems, for
+// finding the least secure system on a route.
+type stargateMap struct {
+	neighbours map[int][]int
+	security   map[int]float64
+}
+
+// stargates is the map used by routeBetween; it's nil (and only the security
+// of a route's ends is known) until IndexStargates is called.
+var stargates *stargateMap
+
+// IndexStargates loads the stargate connections in the local database so
+// that hauling routes can report the least secure system they pass through.
+func IndexStargates(localdb db.LocalDB) error {
+	jumps, err := localdb.StargateJumps()
+	if err != nil {
+		return err
+	}
+	stargates = newStargateMap(jumps)
+	return nil
+}
+
+func newStargateMap(jumps []db.StargateJump) *stargateMap {
+	m := &stargateMap{
+		neighbours: make(map[int][]int),
+		security:   make(map[int]float64),
+	}
+	for _, j := range jumps {
+		m.neighbours[j.FromSystem] = append(m.neighbours[j.FromSystem], j.ToSystem)
+		m.security[j.ToSystem] = j.ToSecurity
+	}
+	return m
+}
+
+// leastSecure returns the least secure system on the shortest route between
+// two systems, ends included. Of several equally short routes, the one whose
+// least secure system is most secure is taken. It returns false if there's no
+// route.
+func (m *stargateMap) leastSecure(from, to int) (int, bool) {
+	// worst holds, for each system reached, the least secure system on the
+	// best route to it found so far.
+	worst := map[int]int{from: from}
+	frontier := []int{from}
+	for len(frontier) > 0 {
+		if _, found := worst[to]; found {
+			return worst[to], true
+		}
+		next := make(map[int]int)
+		for _, sys := range frontier {
+			for _, n := range m.neighbours[sys] {
+				if _, seen := worst[n]; seen {
+					continue
+				}
+				candidate := worst[sys]
+				if m.security[n] < m.security[candidate] {
+					candidate = n
+				}
+				if prev, found := next[n]; !found || m.security[candidate] > m.security[prev] {
+					next[n] = candidate
+				}
+			}
+		}
+		frontier = frontier[:0]
+		for sys, w := range next {
+			worst[sys] = w
+			frontier = append(frontier, sys)
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,62 @@
+/*
+Copyright © 2014–6 Brad Ackerman.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package api
+
+import (
+	"testing"
+
+	"github.com/backerman/eveindy/pkg/db"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestLeastSecure(t *testing.T) {
+	Convey("Verify finding the least secure system on a route", t, func() {
+		// 1 reaches 4 through either 2 (0.4) or 3 (0.7) in two jumps, or
+		// through 5 (1.0) and 6 (1.0) in three. 7 is unconnected.
+		security := map[int]float64{1: 0.9, 2: 0.4, 3: 0.7, 4: 0.8, 5: 1.0, 6: 1.0}
+		links := [][2]int{{1, 2}, {1, 3}, {2, 4}, {3, 4}, {1, 5}, {5, 6}, {6, 4}}
+		var jumps []db.StargateJump
+		for _, l := range links {
+			jumps = append(jumps,
+				db.StargateJump{FromSystem: l[0], ToSystem: l[1], ToSecurity: security[l[1]]},
+				db.StargateJump{FromSystem: l[1], ToSystem: l[0], ToSecurity: security[l[0]]})
+		}
+		m := newStargateMap(jumps)
+
+		Convey("The safest of the shortest routes is taken", func() {
+			system, found := m.leastSecure(1, 4)
+			So(found, ShouldBeTrue)
+			So(system, ShouldEqual, 3)
+		})
+
+		Convey("A route's ends are included", func() {
+			system, found := m.leastSecure(5, 1)
+			So(found, ShouldBeTrue)
+			So(system, ShouldEqual, 1)
+			system, found = m.leastSecure(2, 2)
+			So(found, ShouldBeTrue)
+			So(system, ShouldEqual, 2)
+		})
+
+		Convey("There's no route to an unconnected system", func() {
+			_, found := m.leastSecure(1, 7)
+			So(found, ShouldBeFalse)
+		})
+	})
+}
@@ -19,6 +19,7 @@ package api
 
 import (
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"net/http"
 	"strconv"
@@ -56,38 +57,20 @@ func XMLAPIKeysHandlers(localdb db.LocalDB, sess server.Sessionizer) (list, dele
 	// charRefresh refreshes the characters associated with an API key and returns
 	// the current list of characters via the passed responseWriter.
 	charRefresh := func(s *db.Session, key *db.XMLAPIKey, w http.ResponseWriter) {
-		toons, err := localdb.GetAPICharacters(s.User, *key)
+		toons, err := localdb.RefreshAPIKey(*key)
 		if err != nil {
-			http.Error(w, `{"status": "Error", "error": "Database connection error (add characters)"}`,
-				http.StatusInternalServerError)
-			return
-		}
-		for _, toon := range toons {
-			// Update skills for this character.
-			err = localdb.GetAPISkills(*key, toon.ID)
-			if err != nil {
-				http.Error(w, `{"status": "Error", "error": "Database connection error (add skills)"}`,
+			refreshErr, ok := err.(*db.RefreshError)
+			if ok {
+				http.Error(w, fmt.Sprintf(
+					`{"status": "Error", "error": "Unable to refresh API key (%v)", "keyStatus": "%v"}`,
+					refreshErr.Step, db.ClassifyKeyError(refreshErr.Err)),
 					http.StatusInternalServerError)
-				return
-			}
-
-			// Update standings.
-			err = localdb.GetAPIStandings(*key, toon.ID)
-			if err != nil {
-				http.Error(w, `{"status": "Error", "error": "Database connection error (add standings)"}`,
+			} else {
+				http.Error(w, `{"status": "Error", "error": "Unable to refresh API key"}`,
 					http.StatusInternalServerError)
-				return
 			}
-
-			// Update assets and blueprints.
-			err = localdb.GetAssetsBlueprints(*key, toon.ID)
-			if err != nil {
-				http.Error(w, `{"status": "Error", "error": "Database connection error (add assets)"}`,
-					http.StatusInternalServerError)
-				log.Printf("Got error in GetAssets: %v", err)
-				return
-			}
-
+			log.Printf("Got error refreshing API key %v: %v", key.ID, err)
+			return
 		}
 		response := struct {
 			Status     string            `json:"status"`
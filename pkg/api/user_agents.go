@@ -0,0 +1,142 @@
+/*
+Copyright © 2014–6 Brad Ackerman.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/backerman/evego/pkg/character"
+	"github.com/backerman/eveindy/pkg/db"
+	"github.com/backerman/eveindy/pkg/server"
+	"github.com/zenazn/goji/web"
+)
+
+// agentAccess is an agent along with a character's ability to use it.
+type agentAccess struct {
+	db.Agent
+	Standing   float64 `json:"standing"`
+	Required   float64 `json:"requiredStanding"`
+	Accessible bool    `json:"accessible"`
+}
+
+// requiredStanding returns the minimum effective standing needed to use an
+// agent of the given level.
+func requiredStanding(level int) float64 {
+	if level <= 1 {
+		return -10.0
+	}
+	return float64(level-1)*2.0 - 1.0
+}
+
+// effectiveAgentStanding applies the Connections or Diplomacy skill to a
+// character's raw standing with an agent.
+func effectiveAgentStanding(raw float64, connections, diplomacy int) float64 {
+	skill := connections
+	if raw < 0 {
+		skill = diplomacy
+	}
+	return raw + (10.0-raw)*0.04*float64(skill)
+}
+
+// characterSkills is the level of the skills that affect agent standings.
+type characterSkills struct {
+	connections, diplomacy int
+}
+
+// AgentsHandler returns a web handler function that lists the NPC agents
+// available to each of the user's characters. The query parameters corp,
+// level, and division restrict the agents returned.
+func AgentsHandler(localdb db.LocalDB, sess server.Sessionizer) web.HandlerFunc {
+	return func(c web.C, w http.ResponseWriter, r *http.Request) {
+		s := sess.GetSession(&c, w, r)
+		userID := s.User
+		query := r.URL.Query()
+		npcCorpID, _ := strconv.Atoi(query.Get("corp"))
+		level, _ := strconv.Atoi(query.Get("level"))
+		division := query.Get("division")
+
+		agents, err := localdb.Agents(userID, npcCorpID, level, division)
+		if err != nil {
+			http.Error(w, `{"status": "Error", "error": "Unable to get agents."}`,
+				http.StatusInternalServerError)
+			log.Printf("Unable to get agents for user %v: %v", userID, err)
+			return
+		}
+
+		skills := make(map[int]characterSkills)
+		results := make(map[string][]agentAccess)
+		for _, agent := range agents {
+			charSkills, ok := skills[agent.CharacterID]
+			if !ok {
+				connections, err := localdb.CharacterSkill(userID, agent.CharacterID, connectionsSkillID)
+				if err != nil {
+					http.Error(w, `{"status": "Error", "error": "Unable to get character skills."}`,
+						http.StatusInternalServerError)
+					log.Printf("Unable to get skills for character %v: %v", agent.CharacterID, err)
+					return
+				}
+				diplomacy, err := localdb.CharacterSkill(userID, agent.CharacterID, diplomacySkillID)
+				if err != nil {
+					http.Error(w, `{"status": "Error", "error": "Unable to get character skills."}`,
+						http.StatusInternalServerError)
+					log.Printf("Unable to get skills for character %v: %v", agent.CharacterID, err)
+					return
+				}
+				charSkills = characterSkills{connections, diplomacy}
+				skills[agent.CharacterID] = charSkills
+			}
+
+			// An agent will refuse to work for a character whose corporation or
+			// faction standing is -2 or below, regardless of agent standing.
+			blocked := (agent.CorpStanding.Valid && agent.CorpStanding.Float64 <= -2.0) ||
+				(agent.FacStanding.Valid && agent.FacStanding.Float64 <= -2.0)
+
+			// Otherwise, the best of the three effective standings is used.
+			standing := character.EffectiveStanding(agent.CorpStanding, agent.FacStanding,
+				charSkills.connections, charSkills.diplomacy)
+			if agent.AgentStanding.Valid {
+				agentStanding := effectiveAgentStanding(agent.AgentStanding.Float64,
+					charSkills.connections, charSkills.diplomacy)
+				if agentStanding > standing {
+					standing = agentStanding
+				}
+			}
+			required := requiredStanding(agent.Level)
+			results[agent.CharacterName] = append(results[agent.CharacterName], agentAccess{
+				Agent:      agent,
+				Standing:   standing,
+				Required:   required,
+				Accessible: !blocked && standing >= required,
+			})
+		}
+
+		response := struct {
+			Status     string                   `json:"status"`
+			Characters map[string][]agentAccess `json:"characters"`
+		}{
+			Status:     "OK",
+			Characters: results,
+		}
+		responseJSON, _ := json.Marshal(&response)
+		w.Write(responseJSON)
+	}
+}
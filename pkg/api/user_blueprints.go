@@ -21,6 +21,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"strconv"
+	"time"
 
 	log "github.com/Sirupsen/logrus"
 
@@ -30,6 +31,18 @@ import (
 	"github.com/zenazn/goji/web"
 )
 
+// blueprint is a blueprint along with the job (if any) that is using it.
+type blueprint struct {
+	evego.BlueprintItem
+
+	// InUse is true iff the blueprint is installed in a running job.
+	InUse bool `json:"inUse"`
+
+	// JobID and JobEndDate identify the job using the blueprint.
+	JobID      int64      `json:"jobID,omitempty"`
+	JobEndDate *time.Time `json:"jobEndDate,omitempty"`
+}
+
 // BlueprintsHandlers returns web handler functions that provide information on
 // a toon's bluerpints.
 func BlueprintsHandlers(localdb db.LocalDB, sde evego.Database, sess server.Sessionizer) (refresh, get web.HandlerFunc) {
@@ -86,7 +99,22 @@ func BlueprintsHandlers(localdb db.LocalDB, sde evego.Database, sess server.Sess
 			log.Printf("Error accessing database with user %v, character %v: %v", myUserID, charID, err)
 			return
 		}
+		jobs, err := localdb.CharacterIndustryJobs(myUserID, charID)
+		if err != nil {
+			http.Error(w, `{"status": "Error", "error": "Unable to access database."}`,
+				http.StatusInternalServerError)
+			log.Printf("Error accessing database with user %v, character %v: %v", myUserID, charID, err)
+			return
+		}
+		// Map blueprint item IDs to the jobs currently using them.
+		runningJobs := make(map[int64]*db.IndustryJob)
+		for i := range jobs {
+			if jobs[i].IsRunning() {
+				runningJobs[jobs[i].BlueprintID] = &jobs[i]
+			}
+		}
 		stations := make(map[string]*evego.Station)
+		results := make([]blueprint, 0, len(blueprints))
 		for i := range blueprints {
 			bp := &blueprints[i]
 			if _, found := stations[strconv.Itoa(bp.StationID)]; !found {
@@ -95,11 +123,18 @@ func BlueprintsHandlers(localdb db.LocalDB, sde evego.Database, sess server.Sess
 					stations[strconv.Itoa(bp.StationID)] = stn
 				}
 			}
+			result := blueprint{BlueprintItem: *bp}
+			if job, found := runningJobs[int64(bp.ItemID)]; found {
+				result.InUse = true
+				result.JobID = job.JobID
+				result.JobEndDate = &job.EndDate
+			}
+			results = append(results, result)
 		}
 		response := struct {
-			Blueprints []evego.BlueprintItem     `json:"blueprints"`
+			Blueprints []blueprint               `json:"blueprints"`
 			Stations   map[string]*evego.Station `json:"stations"`
-		}{blueprints, stations}
+		}{results, stations}
 		blueprintsJSON, err := json.Marshal(&response)
 		if err != nil {
 			http.Error(w, `{"status": "Error", "error": "Unable to marshal JSON."}`,
@@ -18,10 +18,15 @@ limitations under the License.
 package api
 
 import (
+	"database/sql"
 	"encoding/json"
 	"fmt"
+	"math"
 	"net/http"
 	"strconv"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
 
 	"github.com/backerman/eveindy/pkg/db"
 	"github.com/backerman/eveindy/pkg/server"
@@ -50,3 +55,270 @@ func SkillsHandler(localdb db.LocalDB, sess server.Sessionizer) web.HandlerFunc
 
 	return handler
 }
+
+// Dogma attribute IDs of character attributes, as referenced by skills'
+// primary and secondary attributes.
+const (
+	charismaAttributeID     = 164
+	intelligenceAttributeID = 165
+	memoryAttributeID       = 166
+	perceptionAttributeID   = 167
+	willpowerAttributeID    = 168
+)
+
+// skillPointsForLevel returns the total number of skillpoints required to
+// train a skill of the given rank to the given level.
+func skillPointsForLevel(rank, level int) int {
+	if level <= 0 {
+		return 0
+	}
+	return int(math.Ceil(250.0 * float64(rank) * math.Pow(2, 2.5*float64(level-1))))
+}
+
+// attributeValue returns the value of the character attribute with the given
+// dogma attribute ID.
+func attributeValue(attrs *db.CharacterAttributes, attributeID int) int {
+	switch attributeID {
+	case charismaAttributeID:
+		return attrs.Charisma
+	case intelligenceAttributeID:
+		return attrs.Intelligence
+	case memoryAttributeID:
+		return attrs.Memory
+	case perceptionAttributeID:
+		return attrs.Perception
+	case willpowerAttributeID:
+		return attrs.Willpower
+	}
+	return 0
+}
+
+// trainingTime returns the time it will take a character with the given
+// attributes to train a skill from one level to another.
+func trainingTime(attrs *db.CharacterAttributes, req *db.SkillRequirement, fromLevel, toLevel int) time.Duration {
+	spPerMinute := float64(attributeValue(attrs, req.PrimaryAttribute)) +
+		float64(attributeValue(attrs, req.SecondaryAttribute))/2.0
+	if spPerMinute <= 0 {
+		// Shouldn't happen, but don't divide by zero if the SDE is missing
+		// attributes for this skill.
+		spPerMinute = 1
+	}
+	sp := skillPointsForLevel(req.Rank, toLevel) - skillPointsForLevel(req.Rank, fromLevel)
+	return time.Duration(float64(sp) / spPerMinute * float64(time.Minute))
+}
+
+// Skill plan statuses.
+const (
+	skillTrained = "trained"
+	skillQueued  = "queued"
+	skillPaused  = "paused"
+	skillPlanned = "planned"
+)
+
+type skillPlanEntry struct {
+	db.SkillRequirement
+
+	// QueuedLevel is the highest level of this skill in the character's queue.
+	QueuedLevel int `json:"queuedLevel"`
+
+	// Status is one of "trained", "queued", "paused" (queued, but the queue
+	// is paused), or "planned" (i.e. requires training beyond what is in the
+	// queue).
+	Status string `json:"status"`
+
+	// CompletionTime is the estimated time at which the requirement will be
+	// met, or nil if it has been already or can't be estimated because the
+	// queue is paused.
+	CompletionTime *time.Time `json:"completionTime,omitempty"`
+}
+
+// planSkills estimates when each requirement will be met if the character
+// trains their current queue and then the remaining requirements in order.
+// If the queue is paused, there's no telling when it will finish, so nothing
+// that depends on it gets a completion time; waiting is true if any
+// requirement does.
+func planSkills(attrs *db.CharacterAttributes, queue []db.QueuedSkill,
+	reqs []db.SkillRequirement, now time.Time) (plan []skillPlanEntry, waiting bool) {
+	// When will the queue have trained each level of each skill?
+	type queuedLevel struct {
+		level int
+		done  *time.Time
+	}
+	queued := make(map[int][]queuedLevel)
+	queueEnd := now
+	paused := false
+	for _, q := range queue {
+		queued[q.TypeID] = append(queued[q.TypeID], queuedLevel{q.Level, q.EndTime})
+		if q.EndTime == nil {
+			paused = true
+		} else if q.EndTime.After(queueEnd) {
+			queueEnd = *q.EndTime
+		}
+	}
+	// plannedLevel holds the level that we've planned to train each skill to
+	// after the queue has finished, so that a skill required by several
+	// activities is only trained once.
+	plannedLevel := make(map[int]int)
+	plannedDone := make(map[int]time.Time)
+	cursor := queueEnd
+
+	plan = make([]skillPlanEntry, 0, len(reqs))
+	for i := range reqs {
+		req := &reqs[i]
+		entry := skillPlanEntry{SkillRequirement: *req, Status: skillTrained}
+		for _, q := range queued[req.TypeID] {
+			if q.level > entry.QueuedLevel {
+				entry.QueuedLevel = q.level
+			}
+		}
+		switch {
+		case req.CurrentLevel >= req.RequiredLevel:
+			// Nothing to do.
+		case entry.QueuedLevel >= req.RequiredLevel:
+			entry.Status = skillQueued
+			if paused {
+				entry.Status = skillPaused
+				waiting = true
+				break
+			}
+			for _, q := range queued[req.TypeID] {
+				if q.level == req.RequiredLevel {
+					entry.CompletionTime = q.done
+				}
+			}
+		default:
+			entry.Status = skillPlanned
+			if paused {
+				waiting = true
+			}
+			from := req.CurrentLevel
+			if entry.QueuedLevel > from {
+				from = entry.QueuedLevel
+			}
+			if plannedLevel[req.TypeID] >= req.RequiredLevel {
+				if !paused {
+					done := plannedDone[req.TypeID]
+					entry.CompletionTime = &done
+				}
+				break
+			}
+			if plannedLevel[req.TypeID] > from {
+				from = plannedLevel[req.TypeID]
+			}
+			cursor = cursor.Add(trainingTime(attrs, req, from, req.RequiredLevel))
+			plannedLevel[req.TypeID] = req.RequiredLevel
+			plannedDone[req.TypeID] = cursor
+			if paused {
+				break
+			}
+			done := cursor
+			entry.CompletionTime = &done
+		}
+		plan = append(plan, entry)
+	}
+	return plan, waiting
+}
+
+// SkillQueueHandler returns a web handler function that provides a toon's
+// skill queue and attributes.
+func SkillQueueHandler(localdb db.LocalDB, sess server.Sessionizer) web.HandlerFunc {
+	return func(c web.C, w http.ResponseWriter, r *http.Request) {
+		s := sess.GetSession(&c, w, r)
+		userID := s.User
+		charID, err := strconv.Atoi(c.URLParams["charID"])
+		if err != nil {
+			http.Error(w, `{"status": "Error", "error": "Invalid character ID supplied."}`,
+				http.StatusBadRequest)
+			return
+		}
+		queue, err := localdb.CharacterSkillQueue(userID, charID)
+		if err != nil {
+			http.Error(w, `{"status": "Error", "error": "Unable to get skill queue."}`,
+				http.StatusInternalServerError)
+			log.Printf("Error getting skill queue for user %v, character %v: %v", userID, charID, err)
+			return
+		}
+		attrs, err := localdb.CharacterAttributes(userID, charID)
+		if err != nil && err != sql.ErrNoRows {
+			http.Error(w, `{"status": "Error", "error": "Unable to get character attributes."}`,
+				http.StatusInternalServerError)
+			log.Printf("Error getting attributes for user %v, character %v: %v", userID, charID, err)
+			return
+		}
+		response := struct {
+			Queue      []db.QueuedSkill        `json:"queue"`
+			Attributes *db.CharacterAttributes `json:"attributes"`
+		}{queue, attrs}
+		responseJSON, _ := json.Marshal(&response)
+		w.Write(responseJSON)
+	}
+}
+
+// SkillPlanHandler returns a web handler function that estimates when a toon
+// will have the skills required to build (and, if applicable, invent) an
+// item.
+func SkillPlanHandler(localdb db.LocalDB, sess server.Sessionizer) web.HandlerFunc {
+	return func(c web.C, w http.ResponseWriter, r *http.Request) {
+		s := sess.GetSession(&c, w, r)
+		userID := s.User
+		charID, err := strconv.Atoi(c.URLParams["charID"])
+		if err != nil {
+			http.Error(w, `{"status": "Error", "error": "Invalid character ID supplied."}`,
+				http.StatusBadRequest)
+			return
+		}
+		typeID, err := strconv.Atoi(c.URLParams["typeID"])
+		if err != nil {
+			http.Error(w, `{"status": "Error", "error": "Invalid type ID supplied."}`,
+				http.StatusBadRequest)
+			return
+		}
+		attrs, err := localdb.CharacterAttributes(userID, charID)
+		if err != nil {
+			errorStr := "Unable to get character attributes."
+			status := http.StatusInternalServerError
+			if err == sql.ErrNoRows {
+				errorStr = "No attributes imported for this character; refresh its API key."
+				status = http.StatusNotFound
+			}
+			http.Error(w, fmt.Sprintf(`{"status": "Error", "error": "%v"}`, errorStr), status)
+			return
+		}
+		queue, err := localdb.CharacterSkillQueue(userID, charID)
+		if err != nil {
+			http.Error(w, `{"status": "Error", "error": "Unable to get skill queue."}`,
+				http.StatusInternalServerError)
+			log.Printf("Error getting skill queue for user %v, character %v: %v", userID, charID, err)
+			return
+		}
+		reqs, err := localdb.SkillRequirements(userID, charID, typeID)
+		if err != nil {
+			http.Error(w, `{"status": "Error", "error": "Unable to get skill requirements."}`,
+				http.StatusInternalServerError)
+			log.Printf("Error getting skill requirements for type %v: %v", typeID, err)
+			return
+		}
+		plan, paused := planSkills(attrs, queue, reqs, time.Now())
+		// The item can be built (and invented) once the last requirement is
+		// met; if any requirement is waiting on a paused queue, we can't say
+		// when that will be.
+		var readyAt *time.Time
+		for _, entry := range plan {
+			if entry.CompletionTime != nil && (readyAt == nil || entry.CompletionTime.After(*readyAt)) {
+				readyAt = entry.CompletionTime
+			}
+		}
+		if paused {
+			readyAt = nil
+		}
+		response := struct {
+			Status  string           `json:"status"`
+			TypeID  int              `json:"typeID"`
+			Skills  []skillPlanEntry `json:"skills"`
+			Paused  bool             `json:"paused"`
+			ReadyAt *time.Time       `json:"readyAt,omitempty"`
+		}{"OK", typeID, plan, paused, readyAt}
+		responseJSON, _ := json.Marshal(&response)
+		w.Write(responseJSON)
+	}
+}
@@ -0,0 +1,72 @@
+/*
+Copyright © 2014–6 Brad Ackerman.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/backerman/eveindy/pkg/db"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestPlanSkills(t *testing.T) {
+	Convey("Verify skill plans", t, func() {
+		now := time.Date(2016, 3, 1, 12, 0, 0, 0, time.UTC)
+		queueEnd := now.Add(2 * time.Hour)
+		attrs := &db.CharacterAttributes{Intelligence: 20, Memory: 20}
+		reqs := []db.SkillRequirement{
+			{TypeID: 3380, RequiredLevel: 3, CurrentLevel: 2, Rank: 1,
+				PrimaryAttribute: intelligenceAttributeID, SecondaryAttribute: memoryAttributeID},
+			{TypeID: 3300, RequiredLevel: 1, CurrentLevel: 0, Rank: 1,
+				PrimaryAttribute: intelligenceAttributeID, SecondaryAttribute: memoryAttributeID},
+		}
+
+		Convey("A running queue gives completion times", func() {
+			queue := []db.QueuedSkill{{TypeID: 3380, Level: 3, EndTime: &queueEnd}}
+			plan, waiting := planSkills(attrs, queue, reqs, now)
+			So(waiting, ShouldBeFalse)
+			So(plan, ShouldHaveLength, 2)
+			So(plan[0].Status, ShouldEqual, skillQueued)
+			So(*plan[0].CompletionTime, ShouldResemble, queueEnd)
+			So(plan[1].Status, ShouldEqual, skillPlanned)
+			So(*plan[1].CompletionTime, ShouldResemble,
+				queueEnd.Add(trainingTime(attrs, &reqs[1], 0, 1)))
+		})
+
+		Convey("A paused queue gives none", func() {
+			queue := []db.QueuedSkill{{TypeID: 3380, Level: 3}}
+			plan, waiting := planSkills(attrs, queue, reqs, now)
+			So(waiting, ShouldBeTrue)
+			So(plan, ShouldHaveLength, 2)
+			So(plan[0].Status, ShouldEqual, skillPaused)
+			So(plan[0].CompletionTime, ShouldBeNil)
+			So(plan[1].Status, ShouldEqual, skillPlanned)
+			So(plan[1].CompletionTime, ShouldBeNil)
+		})
+
+		Convey("A paused queue doesn't matter once everything is trained", func() {
+			queue := []db.QueuedSkill{{TypeID: 3380, Level: 4}}
+			trained := []db.SkillRequirement{{TypeID: 3380, RequiredLevel: 3, CurrentLevel: 3, Rank: 1}}
+			plan, waiting := planSkills(attrs, queue, trained, now)
+			So(waiting, ShouldBeFalse)
+			So(plan[0].Status, ShouldEqual, skillTrained)
+		})
+	})
+}
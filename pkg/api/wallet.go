@@ -0,0 +1,203 @@
+/*
+Copyright © 2014–6 Brad Ackerman.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/backerman/eveindy/pkg/db"
+	"github.com/backerman/eveindy/pkg/server"
+	"github.com/zenazn/goji/web"
+)
+
+// lot is a quantity of items bought at the same unit price.
+type lot struct {
+	quantity int
+	price    float64
+}
+
+type itemProfit struct {
+	TypeID   int    `json:"typeID"`
+	TypeName string `json:"typeName"`
+	// QuantitySold is the number of units sold during the period.
+	QuantitySold int `json:"quantitySold"`
+	// Revenue is the proceeds of all sales during the period.
+	Revenue priceFloat `json:"revenue"`
+	// CostBasis is the FIFO purchase cost of the units sold.
+	CostBasis priceFloat `json:"costBasis"`
+	// UnmatchedQuantity is the number of units sold for which there was no
+	// recorded purchase (e.g. because they were manufactured or looted), and
+	// UnmatchedRevenue their proceeds; these are excluded from the profit.
+	UnmatchedQuantity int        `json:"unmatchedQuantity"`
+	UnmatchedRevenue  priceFloat `json:"unmatchedRevenue"`
+	// Fees is this item's share of the period's broker fees and sales tax,
+	// allocated in proportion to revenue.
+	Fees priceFloat `json:"fees"`
+	// Profit is the realised profit on the matched units, net of fees.
+	Profit priceFloat `json:"profit"`
+}
+
+// fifoProfits computes the realised profit on each item type sold on or
+// after from, matching sales to earlier purchases first in, first out. The
+// transactions must be sorted oldest first.
+func fifoProfits(txns []db.WalletTransaction, from time.Time) map[int]*itemProfit {
+	inventory := make(map[int][]lot)
+	profits := make(map[int]*itemProfit)
+	for _, t := range txns {
+		if t.TransactionType == "buy" {
+			inventory[t.TypeID] = append(inventory[t.TypeID], lot{t.Quantity, t.Price})
+			continue
+		}
+		// It's a sale; consume the oldest lots.
+		remaining := t.Quantity
+		var cost float64
+		lots := inventory[t.TypeID]
+		for remaining > 0 && len(lots) > 0 {
+			used := min(remaining, lots[0].quantity)
+			cost += float64(used) * lots[0].price
+			remaining -= used
+			lots[0].quantity -= used
+			if lots[0].quantity == 0 {
+				lots = lots[1:]
+			}
+		}
+		inventory[t.TypeID] = lots
+		if t.Date.Before(from) {
+			// Only needed to establish the cost basis of later sales.
+			continue
+		}
+		p, found := profits[t.TypeID]
+		if !found {
+			p = &itemProfit{TypeID: t.TypeID, TypeName: t.TypeName}
+			profits[t.TypeID] = p
+		}
+		p.QuantitySold += t.Quantity
+		p.Revenue += priceFloat(float64(t.Quantity) * t.Price)
+		p.CostBasis += priceFloat(cost)
+		p.UnmatchedQuantity += remaining
+		p.UnmatchedRevenue += priceFloat(float64(remaining) * t.Price)
+	}
+	return profits
+}
+
+// parseDateParam parses a date (YYYY-MM-DD) passed as a query parameter,
+// returning the default if it wasn't provided.
+func parseDateParam(r *http.Request, name string, def time.Time) (time.Time, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return def, nil
+	}
+	return time.Parse("2006-01-02", value)
+}
+
+// ProfitAndLoss returns a web handler function that computes a character's
+// realised trading profit per item type over a date range (the from and to
+// query parameters, inclusive; default is the last 30 days).
+func ProfitAndLoss(localdb db.LocalDB, sess server.Sessionizer) web.HandlerFunc {
+	return func(c web.C, w http.ResponseWriter, r *http.Request) {
+		s := sess.GetSession(&c, w, r)
+		myUserID := s.User
+		charID, err := strconv.Atoi(c.URLParams["charID"])
+		if err != nil {
+			http.Error(w, `{"status": "Error", "error": "Invalid character ID supplied."}`,
+				http.StatusBadRequest)
+			return
+		}
+		today := time.Now().UTC().Truncate(24 * time.Hour)
+		to, err := parseDateParam(r, "to", today)
+		if err != nil {
+			http.Error(w, `{"status": "Error", "error": "Invalid end date supplied."}`,
+				http.StatusBadRequest)
+			return
+		}
+		from, err := parseDateParam(r, "from", to.AddDate(0, 0, -30))
+		if err != nil || from.After(to) {
+			http.Error(w, `{"status": "Error", "error": "Invalid start date supplied."}`,
+				http.StatusBadRequest)
+			return
+		}
+		until := to.AddDate(0, 0, 1)
+
+		txns, err := localdb.CharacterTransactions(myUserID, charID, until)
+		if err != nil {
+			http.Error(w, `{"status": "Error", "error": "Unable to access database."}`,
+				http.StatusInternalServerError)
+			log.Printf("Error getting transactions with user %v, character %v: %v", myUserID, charID, err)
+			return
+		}
+		fees, err := localdb.CharacterMarketFees(myUserID, charID, from, until)
+		if err != nil {
+			http.Error(w, `{"status": "Error", "error": "Unable to access database."}`,
+				http.StatusInternalServerError)
+			log.Printf("Error getting market fees with user %v, character %v: %v", myUserID, charID, err)
+			return
+		}
+
+		profits := fifoProfits(txns, from)
+		var totalRevenue, totalProfit priceFloat
+		for _, p := range profits {
+			totalRevenue += p.Revenue
+		}
+		totalFees := fees.BrokerFees + fees.SalesTax
+		items := make([]*itemProfit, 0, len(profits))
+		for _, p := range profits {
+			if totalRevenue > 0 {
+				p.Fees = priceFloat(totalFees * float64(p.Revenue/totalRevenue))
+			}
+			p.Profit = p.Revenue - p.UnmatchedRevenue - p.CostBasis - p.Fees
+			totalProfit += p.Profit
+			items = append(items, p)
+		}
+		sort.Sort(byProfit(items))
+
+		response := struct {
+			Status     string        `json:"status"`
+			From       string        `json:"from"`
+			To         string        `json:"to"`
+			Items      []*itemProfit `json:"items"`
+			Revenue    priceFloat    `json:"revenue"`
+			BrokerFees priceFloat    `json:"brokerFees"`
+			SalesTax   priceFloat    `json:"salesTax"`
+			Profit     priceFloat    `json:"profit"`
+		}{
+			Status:     "OK",
+			From:       from.Format("2006-01-02"),
+			To:         to.Format("2006-01-02"),
+			Items:      items,
+			Revenue:    totalRevenue,
+			BrokerFees: priceFloat(fees.BrokerFees),
+			SalesTax:   priceFloat(fees.SalesTax),
+			Profit:     totalProfit,
+		}
+		responseJSON, _ := json.Marshal(&response)
+		w.Write(responseJSON)
+	}
+}
+
+// byProfit sorts items by descending profit.
+type byProfit []*itemProfit
+
+func (b byProfit) Len() int           { return len(b) }
+func (b byProfit) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+func (b byProfit) Less(i, j int) bool { return b[i].Profit > b[j].Profit }
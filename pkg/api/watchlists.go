@@ -0,0 +1,315 @@
+/*
+Copyright © 2014–6 Brad Ackerman.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/backerman/evego"
+	"github.com/backerman/eveindy/pkg/db"
+	"github.com/backerman/eveindy/pkg/notify"
+	"github.com/backerman/eveindy/pkg/server"
+	"github.com/zenazn/goji/web"
+)
+
+// maxWatchedItems is the number of items a watchlist may contain; every
+// watched item is priced each time the watchlists are checked.
+const maxWatchedItems = 50
+
+// watchedItem is an item in a watchlist, as sent to and from clients.
+type watchedItem struct {
+	ID          int    `json:"id"`
+	ItemName    string `json:"itemName"`
+	TypeID      int    `json:"typeID"`
+	StationID   int    `json:"stationID"`
+	StationName string `json:"stationName"`
+	// Side is "buy" or "sell" (the default).
+	Side     string   `json:"side"`
+	Below    *float64 `json:"below"`
+	Above    *float64 `json:"above"`
+	Alerting bool     `json:"alerting"`
+}
+
+// watchlist is a watchlist as sent to and from clients.
+type watchlist struct {
+	ID      int           `json:"id"`
+	Name    string        `json:"name"`
+	Webhook string        `json:"webhook"`
+	Items   []watchedItem `json:"items"`
+}
+
+// alertRow is an alert as sent to clients.
+type alertRow struct {
+	db.Alert
+	StationName string `json:"stationName"`
+}
+
+func nullFloatPtr(f sql.NullFloat64) *float64 {
+	if !f.Valid {
+		return nil
+	}
+	return &f.Float64
+}
+
+func ptrNullFloat(f *float64) sql.NullFloat64 {
+	if f == nil {
+		return sql.NullFloat64{}
+	}
+	return sql.NullFloat64{Float64: *f, Valid: true}
+}
+
+// fromStoredWatchlist converts a watchlist from the database into the form we
+// send to clients.
+func fromStoredWatchlist(sde evego.Database, xmlAPI evego.XMLAPI, names map[int]string,
+	list *db.Watchlist) watchlist {
+	w := watchlist{
+		ID:      list.ID,
+		Name:    list.Name,
+		Webhook: list.Webhook,
+		Items:   make([]watchedItem, 0, len(list.Items)),
+	}
+	for _, i := range list.Items {
+		w.Items = append(w.Items, watchedItem{
+			ID:          i.ID,
+			ItemName:    i.TypeName,
+			TypeID:      i.TypeID,
+			StationID:   i.StationID,
+			StationName: stationName(sde, xmlAPI, names, i.StationID),
+			Side:        i.Side,
+			Below:       nullFloatPtr(i.Below),
+			Above:       nullFloatPtr(i.Above),
+			Alerting:    i.Alerting,
+		})
+	}
+	return w
+}
+
+// toStoredWatchlist validates a watchlist sent by a client and converts it
+// into the form stored in the database. The returned string describes the
+// problem if the watchlist is invalid.
+func toStoredWatchlist(sde evego.Database, xmlAPI evego.XMLAPI, userID int,
+	w *watchlist) (*db.Watchlist, string) {
+	if w.Name == "" {
+		return nil, "Watchlist must have a name."
+	}
+	if len(w.Items) > maxWatchedItems {
+		return nil, "Watchlists may contain at most " + strconv.Itoa(maxWatchedItems) + " items."
+	}
+	if w.Webhook != "" {
+		err := notify.ValidateWebhook(w.Webhook)
+		if err == notify.ErrPrivateTarget {
+			return nil, "Webhooks may only be delivered to public addresses."
+		}
+		if err != nil {
+			return nil, "Invalid webhook URL supplied."
+		}
+	}
+	list := &db.Watchlist{
+		ID:      w.ID,
+		UserID:  userID,
+		Name:    w.Name,
+		Webhook: w.Webhook,
+		Items:   make([]db.WatchedItem, 0, len(w.Items)),
+	}
+	for _, i := range w.Items {
+		item, err := sde.ItemForName(i.ItemName)
+		if err != nil {
+			return nil, "Unknown item " + i.ItemName
+		}
+		_, err = findStation(sde, xmlAPI, i.StationID)
+		if err != nil {
+			return nil, "Unknown station " + strconv.Itoa(i.StationID)
+		}
+		side := i.Side
+		switch side {
+		case "":
+			side = db.WatchSell
+		case db.WatchBuy, db.WatchSell:
+		default:
+			return nil, "Side must be buy or sell."
+		}
+		if i.Below == nil && i.Above == nil {
+			return nil, "Each item needs a price threshold."
+		}
+		if i.Below != nil && i.Above != nil && *i.Below >= *i.Above {
+			return nil, "Lower threshold must be below upper threshold."
+		}
+		list.Items = append(list.Items, db.WatchedItem{
+			ID:        i.ID,
+			TypeID:    item.ID,
+			StationID: i.StationID,
+			Side:      side,
+			Below:     ptrNullFloat(i.Below),
+			Above:     ptrNullFloat(i.Above),
+		})
+	}
+	return list, ""
+}
+
+// WatchlistHandlers returns web handler functions that list the current user's
+// watchlists, create or replace one (from a watchlist in the request body),
+// delete one, and list the user's recently triggered alerts.
+func WatchlistHandlers(sde evego.Database, localdb db.LocalDB, xmlAPI evego.XMLAPI,
+	sess server.Sessionizer) (list, save, remove, alerts web.HandlerFunc) {
+	list = func(c web.C, w http.ResponseWriter, r *http.Request) {
+		s := sess.GetSession(&c, w, r)
+		if s.User == 0 {
+			http.Error(w, `{"status": "Error", "error": "You must be logged in to use watchlists."}`,
+				http.StatusUnauthorized)
+			return
+		}
+		stored, err := localdb.Watchlists(s.User)
+		if err != nil {
+			http.Error(w, `{"status": "Error", "error": "Unable to access database."}`,
+				http.StatusInternalServerError)
+			log.Printf("Unable to get watchlists for user %v: %v", s.User, err)
+			return
+		}
+		names := make(map[int]string)
+		lists := make([]watchlist, 0, len(stored))
+		for i := range stored {
+			lists = append(lists, fromStoredWatchlist(sde, xmlAPI, names, &stored[i]))
+		}
+		response := struct {
+			Status     string      `json:"status"`
+			Watchlists []watchlist `json:"watchlists"`
+		}{"OK", lists}
+		responseJSON, _ := json.Marshal(&response)
+		w.Write(responseJSON)
+	}
+
+	save = func(c web.C, w http.ResponseWriter, r *http.Request) {
+		s := sess.GetSession(&c, w, r)
+		if s.User == 0 {
+			http.Error(w, `{"status": "Error", "error": "You must be logged in to use watchlists."}`,
+				http.StatusUnauthorized)
+			return
+		}
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, `{"status": "Error", "error": "Unable to read request."}`,
+				http.StatusBadRequest)
+			return
+		}
+		var req watchlist
+		err = json.Unmarshal(body, &req)
+		if err != nil {
+			http.Error(w, `{"status": "Error", "error": "Unable to parse request."}`,
+				http.StatusBadRequest)
+			return
+		}
+		stored, problem := toStoredWatchlist(sde, xmlAPI, s.User, &req)
+		if stored == nil {
+			errJSON, _ := json.Marshal(struct {
+				Status string `json:"status"`
+				Error  string `json:"error"`
+			}{"Error", problem})
+			http.Error(w, string(errJSON), http.StatusBadRequest)
+			return
+		}
+		err = localdb.SaveWatchlist(stored)
+		if err == sql.ErrNoRows {
+			http.Error(w, `{"status": "Error", "error": "No such watchlist."}`,
+				http.StatusNotFound)
+			return
+		}
+		if err == db.ErrUnknownWatchedItem {
+			http.Error(w, `{"status": "Error", "error": "No such item in this watchlist."}`,
+				http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			http.Error(w, `{"status": "Error", "error": "Unable to save watchlist."}`,
+				http.StatusInternalServerError)
+			log.Printf("Unable to save watchlist for user %v: %v", s.User, err)
+			return
+		}
+		// Fill in the IDs assigned to the list and its items.
+		req.ID = stored.ID
+		for i := range req.Items {
+			req.Items[i].ID = stored.Items[i].ID
+			req.Items[i].TypeID = stored.Items[i].TypeID
+			req.Items[i].Side = stored.Items[i].Side
+			req.Items[i].Alerting = false
+		}
+		response := struct {
+			Status    string    `json:"status"`
+			Watchlist watchlist `json:"watchlist"`
+		}{"OK", req}
+		responseJSON, _ := json.Marshal(&response)
+		w.Write(responseJSON)
+	}
+
+	remove = func(c web.C, w http.ResponseWriter, r *http.Request) {
+		s := sess.GetSession(&c, w, r)
+		if s.User == 0 {
+			http.Error(w, `{"status": "Error", "error": "You must be logged in to use watchlists."}`,
+				http.StatusUnauthorized)
+			return
+		}
+		listID, err := strconv.Atoi(c.URLParams["id"])
+		if err != nil {
+			http.Error(w, `{"status": "Error", "error": "Invalid watchlist ID supplied."}`,
+				http.StatusBadRequest)
+			return
+		}
+		err = localdb.DeleteWatchlist(s.User, listID)
+		if err != nil {
+			http.Error(w, `{"status": "Error", "error": "Unable to delete watchlist."}`,
+				http.StatusInternalServerError)
+			log.Printf("Unable to delete watchlist %v: %v", listID, err)
+			return
+		}
+		w.Write([]byte(`{"status": "OK"}`))
+	}
+
+	alerts = func(c web.C, w http.ResponseWriter, r *http.Request) {
+		s := sess.GetSession(&c, w, r)
+		if s.User == 0 {
+			http.Error(w, `{"status": "Error", "error": "You must be logged in to list alerts."}`,
+				http.StatusUnauthorized)
+			return
+		}
+		stored, err := localdb.UserAlerts(s.User)
+		if err != nil {
+			http.Error(w, `{"status": "Error", "error": "Unable to access database."}`,
+				http.StatusInternalServerError)
+			log.Printf("Unable to get alerts for user %v: %v", s.User, err)
+			return
+		}
+		names := make(map[int]string)
+		rows := make([]alertRow, 0, len(stored))
+		for _, a := range stored {
+			rows = append(rows, alertRow{a, stationName(sde, xmlAPI, names, a.StationID)})
+		}
+		response := struct {
+			Status string     `json:"status"`
+			Alerts []alertRow `json:"alerts"`
+		}{"OK", rows}
+		responseJSON, _ := json.Marshal(&response)
+		w.Write(responseJSON)
+	}
+
+	return
+}
@@ -0,0 +1,86 @@
+/*
+Copyright © 2014–6 Brad Ackerman.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package db
+
+import (
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+// maxUserAppraisals is the number of a user's appraisals listed.
+const maxUserAppraisals = 100
+
+// uniqueViolation is PostgreSQL's error code for a duplicate key.
+const uniqueViolation = "23505"
+
+// ErrDuplicateID is returned when a record can't be stored because its ID is
+// already taken.
+var ErrDuplicateID = errors.New("ID is already in use")
+
+// isUniqueViolation returns true iff err is a duplicate key error.
+func isUniqueViolation(err error) bool {
+	pqErr, ok := err.(*pq.Error)
+	return ok && pqErr.Code == uniqueViolation
+}
+
+// nullID converts a zero ID to NULL.
+func nullID(id int) interface{} {
+	if id == 0 {
+		return nil
+	}
+	return id
+}
+
+func (d *dbInterface) SaveAppraisal(a *Appraisal) error {
+	err := d.insertAppraisalStmt.QueryRowx(a.ID, nullID(a.UserID),
+		nullID(a.StationID), a.Region, a.Location, a.Method, a.TopPercent,
+		string(a.Items), string(a.Prices), string(a.Unmatched), a.BuyTotal,
+		a.SellTotal).Scan(&a.Created)
+	if isUniqueViolation(err) {
+		return ErrDuplicateID
+	}
+	return err
+}
+
+func (d *dbInterface) Appraisal(id string) (*Appraisal, error) {
+	a := &Appraisal{}
+	err := d.getAppraisalStmt.QueryRowx(id).StructScan(a)
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func (d *dbInterface) UserAppraisals(userID int) ([]Appraisal, error) {
+	rows, err := d.getUserAppraisalsStmt.Queryx(userID, maxUserAppraisals)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	appraisals := make([]Appraisal, 0, 20)
+	for rows.Next() {
+		a := Appraisal{}
+		err = rows.StructScan(&a)
+		if err != nil {
+			return nil, err
+		}
+		appraisals = append(appraisals, a)
+	}
+	return appraisals, nil
+}
@@ -0,0 +1,74 @@
+/*
+Copyright © 2014–6 Brad Ackerman.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package db
+
+import "database/sql"
+
+func (d *dbInterface) BuybackPrograms(userID int) ([]BuybackProgram, error) {
+	programs := []BuybackProgram{}
+	err := d.getUserBuybackProgramsStmt.Select(&programs, userID)
+	return programs, err
+}
+
+func (d *dbInterface) BuybackProgram(programID int) (*BuybackProgram, error) {
+	p := &BuybackProgram{}
+	err := d.getBuybackProgramStmt.QueryRowx(programID).StructScan(p)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (d *dbInterface) SaveBuybackProgram(p *BuybackProgram) error {
+	if p.ID == 0 {
+		return d.insertBuybackProgramStmt.QueryRowx(p.UserID, p.Name, p.StationID,
+			p.RefineYield, string(p.Rules)).Scan(&p.ID)
+	}
+	res, err := d.updateBuybackProgramStmt.Exec(p.ID, p.UserID, p.Name, p.StationID,
+		p.RefineYield, string(p.Rules))
+	if err != nil {
+		return err
+	}
+	updated, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if updated == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (d *dbInterface) DeleteBuybackProgram(userID, programID int) error {
+	_, err := d.deleteBuybackProgramStmt.Exec(programID, userID)
+	return err
+}
+
+func (d *dbInterface) SaveBuybackQuote(q *BuybackQuote) error {
+	return d.insertBuybackQuoteStmt.QueryRowx(q.ID, q.ProgramID, nullID(q.UserID),
+		q.Expires, q.Location, string(q.Lines), string(q.Unmatched), q.Total).Scan(&q.Created)
+}
+
+func (d *dbInterface) BuybackQuote(quoteID string) (*BuybackQuote, error) {
+	q := &BuybackQuote{}
+	err := d.getBuybackQuoteStmt.QueryRowx(quoteID).StructScan(q)
+	if err != nil {
+		return nil, err
+	}
+	return q, nil
+}
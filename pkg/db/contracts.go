@@ -0,0 +1,129 @@
+/*
+Copyright © 2014–6 Brad Ackerman.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package db
+
+import (
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/backerman/evego"
+	"github.com/backerman/eveindy/pkg/xmlapi"
+)
+
+// isActiveContract returns true iff a contract is still outstanding or in
+// progress.
+func isActiveContract(c *xmlapi.Contract) bool {
+	return c.Status == xmlapi.ContractOutstanding || c.Status == xmlapi.ContractInProgress
+}
+
+func (d *dbInterface) GetAPIContracts(key XMLAPIKey, charID int) error {
+	k := &evego.XMLKey{
+		KeyID:            key.ID,
+		VerificationCode: key.VerificationCode,
+	}
+	contracts, err := d.charAPI.Contracts(k, charID)
+	d.recordKeyResult(key.ID, charID, stepContracts, err)
+	if err != nil {
+		return err
+	}
+	// Only fetch the contents of contracts that are still active; there's
+	// one API call per contract, and we never look at the others' items.
+	items := make(map[int64][]xmlapi.ContractItem)
+	for i := range contracts {
+		c := &contracts[i]
+		if !isActiveContract(c) {
+			continue
+		}
+		items[c.ContractID], err = d.charAPI.ContractItems(k, charID, c.ContractID)
+		d.recordKeyResult(key.ID, charID, stepContracts, err)
+		if err != nil {
+			return err
+		}
+	}
+
+	tx, err := d.db.Beginx()
+	if err != nil {
+		return err
+	}
+	// Clear contracts before inserting the API's information.
+	_, err = tx.Stmtx(d.clearContractsStmt).Exec(key.ID, charID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	insertStmt := tx.Stmtx(d.insertContractStmt)
+	insertItemStmt := tx.Stmtx(d.insertContractItemStmt)
+	for _, c := range contracts {
+		_, err = insertStmt.Exec(c.ContractID, charID, key.ID, c.IssuerID,
+			c.IssuerCorpID, c.AssigneeID, c.AcceptorID, c.StartStationID,
+			c.EndStationID, c.Type, c.Status, c.Title, c.ForCorp != 0,
+			c.Availability, c.DateIssued, c.DateExpired, nullTime(c.DateAccepted),
+			nullTime(c.DateCompleted), c.NumDays, c.Price, c.Reward, c.Collateral,
+			c.Buyout, c.Volume)
+		if err != nil {
+			log.Printf("Failed to insert contract %+v", c)
+			tx.Rollback()
+			return err
+		}
+		for _, i := range items[c.ContractID] {
+			_, err = insertItemStmt.Exec(c.ContractID, charID, i.RecordID, i.TypeID,
+				i.Quantity, i.Singleton != 0, i.Included != 0)
+			if err != nil {
+				log.Printf("Failed to insert contract item %+v", i)
+				tx.Rollback()
+				return err
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+func (d *dbInterface) CharacterContracts(userID, charID int) ([]Contract, error) {
+	rows, err := d.getActiveContractsStmt.Queryx(userID, charID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	contracts := make([]Contract, 0, 10)
+	index := make(map[int64]int)
+	for rows.Next() {
+		c := Contract{Items: []ContractItem{}}
+		err = rows.StructScan(&c)
+		if err != nil {
+			return nil, err
+		}
+		index[c.ContractID] = len(contracts)
+		contracts = append(contracts, c)
+	}
+
+	itemRows, err := d.getActiveContractItemsStmt.Queryx(userID, charID)
+	if err != nil {
+		return nil, err
+	}
+	defer itemRows.Close()
+	for itemRows.Next() {
+		i := ContractItem{}
+		err = itemRows.StructScan(&i)
+		if err != nil {
+			return nil, err
+		}
+		if idx, found := index[i.ContractID]; found {
+			contracts[idx].Items = append(contracts[idx].Items, i)
+		}
+	}
+	return contracts, nil
+}
@@ -25,44 +25,129 @@ import (
 
 	"github.com/backerman/evego"
 	"github.com/backerman/evego/pkg/evesso"
+	"github.com/backerman/eveindy/pkg/xmlapi"
 	"github.com/jmoiron/sqlx"
 	"golang.org/x/oauth2"
 )
 
 type dbInterface struct {
-	db                            *sqlx.DB
-	getSessionStmt                *sqlx.Stmt
-	getAPIKeysStmt                *sqlx.Stmt
-	addAPIKeyStmt                 *sqlx.Stmt
-	deleteAPIKeyStmt              *sqlx.Stmt
-	setTokenStmt                  *sqlx.Stmt
-	logoutSessionStmt             *sqlx.Stmt
-	apiKeyInsertToonStmt          *sqlx.Stmt
-	apiKeyListToonsStmt           *sqlx.Stmt
-	apiKeyInsertSkillStmt         *sqlx.Stmt
-	apiKeyClearSkillsStmt         *sqlx.Stmt
-	getSkillStmt                  *sqlx.Stmt
-	getSkillGroupStmt             *sqlx.Stmt
-	apiKeyClearCorpStandingsStmt  *sqlx.Stmt
-	apiKeyClearFacStandingsStmt   *sqlx.Stmt
-	apiKeyInsertCorpStandingsStmt *sqlx.Stmt
-	apiKeyInsertFacStandingsStmt  *sqlx.Stmt
-	getStandingsStmt              *sqlx.Stmt
-	deleteToonsStmt               *sqlx.Stmt
-	clearOutpostsStmt             *sqlx.Stmt
-	insertOutpostsStmt            *sqlx.Stmt
-	searchStationsStmt            *sqlx.Stmt
-	getStationStmt                *sqlx.Stmt
-	clearBlueprintsStmt           *sqlx.Stmt
-	insertBlueprintStmt           *sqlx.Stmt
-	getBlueprintsStmt             *sqlx.Stmt
-	clearAssetsStmt               *sqlx.Stmt
-	insertAssetStmt               *sqlx.Stmt
-	getAssetsStmt                 *sqlx.Stmt
-	unusedSalvageStmt             *sqlx.Stmt
+	db                             *sqlx.DB
+	getSessionStmt                 *sqlx.Stmt
+	getAPIKeysStmt                 *sqlx.Stmt
+	getAllAPIKeysStmt              *sqlx.Stmt
+	setAPIKeyStatusStmt            *sqlx.Stmt
+	clearAPIKeyStepStmt            *sqlx.Stmt
+	insertAPIKeyStepStmt           *sqlx.Stmt
+	getAPIKeyStepsStmt             *sqlx.Stmt
+	addAPIKeyStmt                  *sqlx.Stmt
+	deleteAPIKeyStmt               *sqlx.Stmt
+	setTokenStmt                   *sqlx.Stmt
+	logoutSessionStmt              *sqlx.Stmt
+	apiKeyInsertToonStmt           *sqlx.Stmt
+	getUserCharacterIDsStmt        *sqlx.Stmt
+	apiKeyListToonsStmt            *sqlx.Stmt
+	apiKeyInsertSkillStmt          *sqlx.Stmt
+	apiKeyClearSkillsStmt          *sqlx.Stmt
+	getSkillStmt                   *sqlx.Stmt
+	getSkillGroupStmt              *sqlx.Stmt
+	apiKeyClearCorpStandingsStmt   *sqlx.Stmt
+	apiKeyClearFacStandingsStmt    *sqlx.Stmt
+	apiKeyInsertCorpStandingsStmt  *sqlx.Stmt
+	apiKeyInsertFacStandingsStmt   *sqlx.Stmt
+	apiKeyClearAgentStandingsStmt  *sqlx.Stmt
+	apiKeyInsertAgentStandingsStmt *sqlx.Stmt
+	getAgentsStmt                  *sqlx.Stmt
+	getStandingsStmt               *sqlx.Stmt
+	deleteToonsStmt                *sqlx.Stmt
+	clearOutpostsStmt              *sqlx.Stmt
+	insertOutpostsStmt             *sqlx.Stmt
+	searchStationsStmt             *sqlx.Stmt
+	getStationStmt                 *sqlx.Stmt
+	clearBlueprintsStmt            *sqlx.Stmt
+	insertBlueprintStmt            *sqlx.Stmt
+	getBlueprintsStmt              *sqlx.Stmt
+	clearAssetsStmt                *sqlx.Stmt
+	insertAssetStmt                *sqlx.Stmt
+	getAssetsStmt                  *sqlx.Stmt
+	unusedSalvageStmt              *sqlx.Stmt
+	clearSkillQueueStmt            *sqlx.Stmt
+	insertSkillQueueStmt           *sqlx.Stmt
+	clearAttributesStmt            *sqlx.Stmt
+	insertAttributesStmt           *sqlx.Stmt
+	clearImplantsStmt              *sqlx.Stmt
+	insertImplantStmt              *sqlx.Stmt
+	getSkillQueueStmt              *sqlx.Stmt
+	getAttributesStmt              *sqlx.Stmt
+	getSkillRequirementsStmt       *sqlx.Stmt
+	deleteIndustryJobsStmt         *sqlx.Stmt
+	insertIndustryJobStmt          *sqlx.Stmt
+	markJobsGoneStmt               *sqlx.Stmt
+	getIndustryJobsStmt            *sqlx.Stmt
+	insertTransactionStmt          *sqlx.Stmt
+	insertJournalEntryStmt         *sqlx.Stmt
+	getTransactionsStmt            *sqlx.Stmt
+	getMarketFeesStmt              *sqlx.Stmt
+	clearMarketOrdersStmt          *sqlx.Stmt
+	insertMarketOrderStmt          *sqlx.Stmt
+	getOpenMarketOrdersStmt        *sqlx.Stmt
+	clearContractsStmt             *sqlx.Stmt
+	insertContractStmt             *sqlx.Stmt
+	insertContractItemStmt         *sqlx.Stmt
+	getActiveContractsStmt         *sqlx.Stmt
+	getActiveContractItemsStmt     *sqlx.Stmt
+	clearColoniesStmt              *sqlx.Stmt
+	insertColonyStmt               *sqlx.Stmt
+	insertPinStmt                  *sqlx.Stmt
+	insertRouteStmt                *sqlx.Stmt
+	getColoniesStmt                *sqlx.Stmt
+	getPinsStmt                    *sqlx.Stmt
+	getSchematicsStmt              *sqlx.Stmt
+	clearSnapshotOrdersStmt        *sqlx.Stmt
+	lockMarketGenerationStmt       *sqlx.Stmt
+	getMarketGenerationStmt        *sqlx.Stmt
+	clearMarketGenerationStmt      *sqlx.Stmt
+	insertMarketGenerationStmt     *sqlx.Stmt
+	insertSnapshotOrderStmt        *sqlx.Stmt
+	getSnapshotOrdersStmt          *sqlx.Stmt
+	clearPriceHistoryStmt          *sqlx.Stmt
+	insertPriceHistoryStmt         *sqlx.Stmt
+	getPriceHistoryStmt            *sqlx.Stmt
+	getDailyDepletionStmt          *sqlx.Stmt
+	getMarketGroupItemsStmt        *sqlx.Stmt
+	getMarketGroupPathStmt         *sqlx.Stmt
+	getItemNamesStmt               *sqlx.Stmt
+	getStargateJumpsStmt           *sqlx.Stmt
+	insertAppraisalStmt            *sqlx.Stmt
+	getAppraisalStmt               *sqlx.Stmt
+	getUserAppraisalsStmt          *sqlx.Stmt
+	getWatchlistsStmt              *sqlx.Stmt
+	getUserWatchedItemsStmt        *sqlx.Stmt
+	getAllWatchedItemsStmt         *sqlx.Stmt
+	insertWatchlistStmt            *sqlx.Stmt
+	updateWatchlistStmt            *sqlx.Stmt
+	deleteWatchlistStmt            *sqlx.Stmt
+	clearWatchedItemsStmt          *sqlx.Stmt
+	insertWatchedItemStmt          *sqlx.Stmt
+	updateWatchedItemStmt          *sqlx.Stmt
+	setWatchAlertingStmt           *sqlx.Stmt
+	insertAlertStmt                *sqlx.Stmt
+	markAlertsDeliveredStmt        *sqlx.Stmt
+	getUndeliveredAlertsStmt       *sqlx.Stmt
+	getUserAlertsStmt              *sqlx.Stmt
+	getUserTickersStmt             *sqlx.Stmt
+	deleteTickerStmt               *sqlx.Stmt
+	insertTickerStmt               *sqlx.Stmt
+	getUserBuybackProgramsStmt     *sqlx.Stmt
+	getBuybackProgramStmt          *sqlx.Stmt
+	insertBuybackProgramStmt       *sqlx.Stmt
+	updateBuybackProgramStmt       *sqlx.Stmt
+	deleteBuybackProgramStmt       *sqlx.Stmt
+	insertBuybackQuoteStmt         *sqlx.Stmt
+	getBuybackQuoteStmt            *sqlx.Stmt
 
 	// Need access to EVE APIs.
-	xmlAPI evego.XMLAPI
+	xmlAPI  evego.XMLAPI
+	charAPI xmlapi.API
 }
 
 // Interface returns an interface to the local data store. Currently, it assumes
@@ -70,7 +155,9 @@ type dbInterface struct {
 // path, so you'll need to ensure that it's set in the provided resource.
 //
 // Example resource: "user=enoch dbname=evetool search_path=eveindy"
-func Interface(driver, resource string, xmlAPI evego.XMLAPI) (LocalDB, error) {
+//
+// The XML API calls that evego doesn't implement are made using charAPI.
+func Interface(driver, resource string, xmlAPI evego.XMLAPI, charAPI xmlapi.API) (LocalDB, error) {
 	dbConn, err := sqlx.Connect(driver, resource)
 	if err != nil {
 		return nil, err
@@ -78,8 +165,9 @@ func Interface(driver, resource string, xmlAPI evego.XMLAPI) (LocalDB, error) {
 	// Is resource a URL or the other thing?
 	// Find out, then add/modify search_path parameter.
 	d := &dbInterface{
-		db:     dbConn,
-		xmlAPI: xmlAPI,
+		db:      dbConn,
+		xmlAPI:  xmlAPI,
+		charAPI: charAPI,
 	}
 	// Prepare statements
 	stmts := []struct {
@@ -90,11 +178,17 @@ func Interface(driver, resource string, xmlAPI evego.XMLAPI) (LocalDB, error) {
 		{&d.getSessionStmt, getSessionStmt},
 		{&d.setTokenStmt, setTokenStmt},
 		{&d.getAPIKeysStmt, getAPIKeysStmt},
+		{&d.getAllAPIKeysStmt, getAllAPIKeysStmt},
+		{&d.setAPIKeyStatusStmt, setAPIKeyStatusStmt},
+		{&d.clearAPIKeyStepStmt, clearAPIKeyStepStmt},
+		{&d.insertAPIKeyStepStmt, insertAPIKeyStepStmt},
+		{&d.getAPIKeyStepsStmt, getAPIKeyStepsStmt},
 		{&d.addAPIKeyStmt, addAPIKeyStmt},
 		{&d.deleteAPIKeyStmt, deleteAPIKeyStmt},
 		{&d.logoutSessionStmt, logoutSessionStmt},
 		{&d.apiKeyInsertToonStmt, apiKeyInsertToonStmt},
 		{&d.apiKeyListToonsStmt, apiKeyListToonsStmt},
+		{&d.getUserCharacterIDsStmt, getUserCharacterIDsStmt},
 		{&d.apiKeyInsertSkillStmt, apiKeyInsertSkillStmt},
 		{&d.apiKeyClearSkillsStmt, apiKeyClearSkillsStmt},
 		{&d.getSkillStmt, getSkillStmt},
@@ -103,6 +197,9 @@ func Interface(driver, resource string, xmlAPI evego.XMLAPI) (LocalDB, error) {
 		{&d.apiKeyClearFacStandingsStmt, apiKeyClearFacStandingsStmt},
 		{&d.apiKeyInsertCorpStandingsStmt, apiKeyInsertCorpStandingsStmt},
 		{&d.apiKeyInsertFacStandingsStmt, apiKeyInsertFacStandingsStmt},
+		{&d.apiKeyClearAgentStandingsStmt, apiKeyClearAgentStandingsStmt},
+		{&d.apiKeyInsertAgentStandingsStmt, apiKeyInsertAgentStandingsStmt},
+		{&d.getAgentsStmt, getAgentsStmt},
 		{&d.getStandingsStmt, getStandingsStmt},
 		{&d.deleteToonsStmt, deleteToonsStmt},
 		{&d.clearOutpostsStmt, clearOutpostsStmt},
@@ -116,6 +213,80 @@ func Interface(driver, resource string, xmlAPI evego.XMLAPI) (LocalDB, error) {
 		{&d.insertAssetStmt, insertAssetStmt},
 		{&d.getAssetsStmt, getAssetsStmt},
 		{&d.unusedSalvageStmt, unusedSalvageStmt},
+		{&d.clearSkillQueueStmt, clearSkillQueueStmt},
+		{&d.insertSkillQueueStmt, insertSkillQueueStmt},
+		{&d.clearAttributesStmt, clearAttributesStmt},
+		{&d.insertAttributesStmt, insertAttributesStmt},
+		{&d.clearImplantsStmt, clearImplantsStmt},
+		{&d.insertImplantStmt, insertImplantStmt},
+		{&d.getSkillQueueStmt, getSkillQueueStmt},
+		{&d.getAttributesStmt, getAttributesStmt},
+		{&d.getSkillRequirementsStmt, getSkillRequirementsStmt},
+		{&d.deleteIndustryJobsStmt, deleteIndustryJobsStmt},
+		{&d.insertIndustryJobStmt, insertIndustryJobStmt},
+		{&d.markJobsGoneStmt, markJobsGoneStmt},
+		{&d.getIndustryJobsStmt, getIndustryJobsStmt},
+		{&d.insertTransactionStmt, insertTransactionStmt},
+		{&d.insertJournalEntryStmt, insertJournalEntryStmt},
+		{&d.getTransactionsStmt, getTransactionsStmt},
+		{&d.getMarketFeesStmt, getMarketFeesStmt},
+		{&d.clearMarketOrdersStmt, clearMarketOrdersStmt},
+		{&d.insertMarketOrderStmt, insertMarketOrderStmt},
+		{&d.getOpenMarketOrdersStmt, getOpenMarketOrdersStmt},
+		{&d.clearContractsStmt, clearContractsStmt},
+		{&d.insertContractStmt, insertContractStmt},
+		{&d.insertContractItemStmt, insertContractItemStmt},
+		{&d.getActiveContractsStmt, getActiveContractsStmt},
+		{&d.getActiveContractItemsStmt, getActiveContractItemsStmt},
+		{&d.clearColoniesStmt, clearColoniesStmt},
+		{&d.insertColonyStmt, insertColonyStmt},
+		{&d.insertPinStmt, insertPinStmt},
+		{&d.insertRouteStmt, insertRouteStmt},
+		{&d.getColoniesStmt, getColoniesStmt},
+		{&d.getPinsStmt, getPinsStmt},
+		{&d.getSchematicsStmt, getSchematicsStmt},
+		{&d.clearSnapshotOrdersStmt, clearSnapshotOrdersStmt},
+		{&d.insertSnapshotOrderStmt, insertSnapshotOrderStmt},
+		{&d.lockMarketGenerationStmt, lockMarketGenerationStmt},
+		{&d.getMarketGenerationStmt, getMarketGenerationStmt},
+		{&d.clearMarketGenerationStmt, clearMarketGenerationStmt},
+		{&d.insertMarketGenerationStmt, insertMarketGenerationStmt},
+		{&d.getSnapshotOrdersStmt, getSnapshotOrdersStmt},
+		{&d.clearPriceHistoryStmt, clearPriceHistoryStmt},
+		{&d.insertPriceHistoryStmt, insertPriceHistoryStmt},
+		{&d.getPriceHistoryStmt, getPriceHistoryStmt},
+		{&d.getDailyDepletionStmt, getDailyDepletionStmt},
+		{&d.getMarketGroupItemsStmt, getMarketGroupItemsStmt},
+		{&d.getMarketGroupPathStmt, getMarketGroupPathStmt},
+		{&d.getItemNamesStmt, getItemNamesStmt},
+		{&d.getStargateJumpsStmt, getStargateJumpsStmt},
+		{&d.insertAppraisalStmt, insertAppraisalStmt},
+		{&d.getAppraisalStmt, getAppraisalStmt},
+		{&d.getUserAppraisalsStmt, getUserAppraisalsStmt},
+		{&d.getWatchlistsStmt, getWatchlistsStmt},
+		{&d.getUserWatchedItemsStmt, getUserWatchedItemsStmt},
+		{&d.getAllWatchedItemsStmt, getAllWatchedItemsStmt},
+		{&d.insertWatchlistStmt, insertWatchlistStmt},
+		{&d.updateWatchlistStmt, updateWatchlistStmt},
+		{&d.deleteWatchlistStmt, deleteWatchlistStmt},
+		{&d.clearWatchedItemsStmt, clearWatchedItemsStmt},
+		{&d.insertWatchedItemStmt, insertWatchedItemStmt},
+		{&d.updateWatchedItemStmt, updateWatchedItemStmt},
+		{&d.setWatchAlertingStmt, setWatchAlertingStmt},
+		{&d.insertAlertStmt, insertAlertStmt},
+		{&d.markAlertsDeliveredStmt, markAlertsDeliveredStmt},
+		{&d.getUndeliveredAlertsStmt, getUndeliveredAlertsStmt},
+		{&d.getUserAlertsStmt, getUserAlertsStmt},
+		{&d.getUserTickersStmt, getUserTickersStmt},
+		{&d.deleteTickerStmt, deleteTickerStmt},
+		{&d.insertTickerStmt, insertTickerStmt},
+		{&d.getUserBuybackProgramsStmt, getUserBuybackProgramsStmt},
+		{&d.getBuybackProgramStmt, getBuybackProgramStmt},
+		{&d.insertBuybackProgramStmt, insertBuybackProgramStmt},
+		{&d.updateBuybackProgramStmt, updateBuybackProgramStmt},
+		{&d.deleteBuybackProgramStmt, deleteBuybackProgramStmt},
+		{&d.insertBuybackQuoteStmt, insertBuybackQuoteStmt},
+		{&d.getBuybackQuoteStmt, getBuybackQuoteStmt},
 	}
 
 	for _, s := range stmts {
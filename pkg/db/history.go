@@ -0,0 +1,92 @@
+/*
+Copyright © 2014–6 Brad Ackerman.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package db
+
+func (d *dbInterface) RecordPriceHistory(entry PriceHistoryEntry) error {
+	tx, err := d.db.Beginx()
+	if err != nil {
+		return err
+	}
+	// Days are UTC, as on the EVE clock, whatever the server's time zone.
+	day := entry.Day.UTC().Format("2006-01-02")
+	_, err = tx.Stmtx(d.clearPriceHistoryStmt).Exec(entry.TypeID, entry.StationID, day)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	_, err = tx.Stmtx(d.insertPriceHistoryStmt).Exec(entry.TypeID, entry.StationID,
+		day, entry.BestBuy, entry.BestSell, entry.BuyVolume, entry.SellVolume,
+		entry.BuyOrders, entry.SellOrders, entry.SellP5, entry.SellMedian,
+		entry.BuyP95, entry.BuyMedian)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (d *dbInterface) PriceHistory(typeID, stationID, days int) ([]PriceHistoryEntry, error) {
+	rows, err := d.getPriceHistoryStmt.Queryx(typeID, stationID, days)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	history := make([]PriceHistoryEntry, 0, days)
+	for rows.Next() {
+		e := PriceHistoryEntry{}
+		err = rows.StructScan(&e)
+		if err != nil {
+			return nil, err
+		}
+		history = append(history, e)
+	}
+	return history, nil
+}
+
+func (d *dbInterface) DailyDepletion(stationID, days int) (map[int]float64, error) {
+	rows, err := d.getDailyDepletionStmt.Query(stationID, days)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	depletion := make(map[int]float64)
+	for rows.Next() {
+		var (
+			typeID int
+			units  float64
+		)
+		err = rows.Scan(&typeID, &units)
+		if err != nil {
+			return nil, err
+		}
+		depletion[typeID] = units
+	}
+	return depletion, nil
+}
+
+func (d *dbInterface) MarketGroupItems(marketGroupID int) ([]string, error) {
+	items := []string{}
+	err := d.getMarketGroupItemsStmt.Select(&items, marketGroupID)
+	return items, err
+}
+
+func (d *dbInterface) MarketGroupPath(typeID int) ([]int, error) {
+	path := []int{}
+	err := d.getMarketGroupPathStmt.Select(&path, typeID)
+	return path, err
+}
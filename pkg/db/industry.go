@@ -0,0 +1,138 @@
+/*
+Copyright © 2014–6 Brad Ackerman.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package db
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/backerman/evego"
+	"github.com/backerman/eveindy/pkg/xmlapi"
+	"github.com/jmoiron/sqlx"
+)
+
+// int64ArrayString formats a list of IDs as a SQL array string
+// representation, which will be cast to an array by the prepared statement.
+func int64ArrayString(ids []int64) string {
+	idStrs := make([]string, 0, len(ids))
+	for _, id := range ids {
+		idStrs = append(idStrs, strconv.FormatInt(id, 10))
+	}
+	return fmt.Sprintf("{%s}", strings.Join(idStrs, ", "))
+}
+
+func (d *dbInterface) GetAPIIndustryJobs(key XMLAPIKey, charID int) error {
+	k := &evego.XMLKey{
+		KeyID:            key.ID,
+		VerificationCode: key.VerificationCode,
+	}
+	jobs, err := d.charAPI.IndustryJobs(k, charID)
+	d.recordKeyResult(key.ID, charID, stepIndustryJobs, err)
+	if err != nil {
+		return err
+	}
+	// Corporation jobs are only available on corporation keys, so being
+	// refused just means that this isn't one; anything else is a real failure.
+	corpJobs, err := d.charAPI.CorporationIndustryJobs(k)
+	if xmlapi.IsNotCorporationKey(err) {
+		corpJobs, err = nil, nil
+	}
+	if err != nil {
+		return err
+	}
+
+	tx, err := d.db.Beginx()
+	if err != nil {
+		return err
+	}
+	err = d.storeIndustryJobs(tx, key.ID, charID, false, jobs)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if corpJobs != nil {
+		err = d.storeIndustryJobs(tx, key.ID, charID, true, corpJobs)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// JobGone is the status given to a job that dropped off the XML API's list
+// without us having seen its final status. It's not one of the API's own
+// statuses, but like them it's above 100 so that the job isn't running.
+const JobGone = 199
+
+// storeIndustryJobs replaces this character's stored copies of the passed
+// jobs and marks any of its other running jobs as gone. Each character keeps
+// its own copy of a corporation job, so that members' keys seeing the same
+// job don't overwrite each other.
+func (d *dbInterface) storeIndustryJobs(tx *sqlx.Tx, keyID, charID int, isCorp bool, jobs []xmlapi.IndustryJob) error {
+	jobIDs := make([]int64, 0, len(jobs))
+	for _, job := range jobs {
+		jobIDs = append(jobIDs, job.JobID)
+	}
+	jobIDsString := int64ArrayString(jobIDs)
+	_, err := tx.Stmtx(d.deleteIndustryJobsStmt).Exec(charID, isCorp, jobIDsString)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Stmtx(d.markJobsGoneStmt).Exec(charID, isCorp, jobIDsString, JobGone)
+	if err != nil {
+		return err
+	}
+	insertStmt := tx.Stmtx(d.insertIndustryJobStmt)
+	for _, j := range jobs {
+		var productTypeID interface{}
+		if j.ProductTypeID != 0 {
+			productTypeID = j.ProductTypeID
+		}
+		_, err = insertStmt.Exec(j.JobID, charID, keyID, isCorp, j.InstallerID,
+			j.InstallerName, j.FacilityID, j.SolarSystemID, j.StationID, j.ActivityID,
+			j.BlueprintID, j.BlueprintTypeID, j.Runs, j.LicensedRuns, productTypeID,
+			j.Status, j.StartDate, j.EndDate, nullTime(j.CompletedDate))
+		if err != nil {
+			log.Printf("Failed to insert industry job %+v", j)
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *dbInterface) CharacterIndustryJobs(userID, charID int) ([]IndustryJob, error) {
+	rows, err := d.getIndustryJobsStmt.Queryx(userID, charID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	jobs := make([]IndustryJob, 0, 10)
+	for rows.Next() {
+		job := IndustryJob{}
+		err = rows.StructScan(&job)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
@@ -19,6 +19,7 @@ package db
 
 import (
 	"database/sql"
+	"time"
 
 	"github.com/backerman/evego"
 	"github.com/backerman/evego/pkg/evesso"
@@ -41,6 +42,10 @@ type LocalDB interface {
 	// APIKeys returns the user's API keys that have been registered in this application.
 	APIKeys(userID int) ([]XMLAPIKey, error)
 
+	// AllAPIKeys returns every API key registered in this application, for use
+	// by background jobs.
+	AllAPIKeys() ([]XMLAPIKey, error)
+
 	// LogoutSession deletes all of a user's sessions.
 	LogoutSession(cookie string) error
 
@@ -53,6 +58,17 @@ type LocalDB interface {
 	// GetAPICharacters adds the characters on an API key to the database.
 	GetAPICharacters(userid int, key XMLAPIKey) ([]evego.Character, error)
 
+	// UserCharacterIDs returns the IDs of the characters on all of a user's API
+	// keys.
+	UserCharacterIDs(userID int) ([]int, error)
+
+	// RefreshAPIKey imports all of the information that we use from the XML
+	// API for each character on the provided key, recording the outcome of
+	// each step with the key. If listing the characters or importing their
+	// skills or assets fails, the error returned will be a *RefreshError;
+	// other steps that fail are skipped.
+	RefreshAPIKey(key XMLAPIKey) ([]evego.Character, error)
+
 	// GetAPISkills adds the skills on a character to the database.
 	GetAPISkills(key XMLAPIKey, charID int) error
 
@@ -64,13 +80,37 @@ type LocalDB interface {
 	// group.
 	CharacterSkillGroup(userID, charID, skillGroupID int) ([]evego.Skill, error)
 
-	// GetAPIStandings adds a character's standings with NPC entities to the database.
+	// GetAPISkillQueue adds a character's skill queue, attributes, and implants
+	// to the database.
+	GetAPISkillQueue(key XMLAPIKey, charID int) error
+
+	// CharacterSkillQueue returns a character's skill queue.
+	CharacterSkillQueue(userID, charID int) ([]QueuedSkill, error)
+
+	// CharacterAttributes returns a character's attributes (including implant
+	// bonuses) and skillpoints.
+	CharacterAttributes(userID, charID int) (*CharacterAttributes, error)
+
+	// SkillRequirements returns the skills required to manufacture the
+	// specified item or blueprint (and to invent it, if applicable), along with
+	// the character's current level in each.
+	SkillRequirements(userID, charID, typeID int) ([]SkillRequirement, error)
+
+	// GetAPIStandings adds a character's standings with NPC entities (agents,
+	// corporations, and factions) to the database.
 	GetAPIStandings(key XMLAPIKey, charID int) error
 
 	// CharacterStandings queries a character's standings (corporation and faction)
 	// with an NPC corporation.
 	CharacterStandings(userID, charID, corpID int) (corpStanding, factionStanding sql.NullFloat64, err error)
 
+	// Agents returns the NPC agents of the specified NPC corporation (or, if
+	// npcCorpID is zero, of every corporation with which the user's characters
+	// have standings), along with each of the user's characters' standings
+	// with them. Agents can be filtered by level and division name; pass zero
+	// or the empty string to disable either filter.
+	Agents(userID, npcCorpID, level int, division string) ([]Agent, error)
+
 	// RepopulateOutposts updates outpost information in the local database.
 	RepopulateOutposts() error
 
@@ -90,6 +130,181 @@ type LocalDB interface {
 	// database.
 	CharacterBlueprints(userID, charID int) ([]evego.BlueprintItem, error)
 
+	// GetAPIIndustryJobs adds a character's industry jobs (and, for
+	// corporation keys, their corporation's) to the database.
+	GetAPIIndustryJobs(key XMLAPIKey, charID int) error
+
+	// CharacterIndustryJobs returns the industry jobs installed by, or
+	// visible to, a character.
+	CharacterIndustryJobs(userID, charID int) ([]IndustryJob, error)
+
+	// GetAPIWallet adds any new wallet transactions and journal entries for a
+	// character to the database.
+	GetAPIWallet(key XMLAPIKey, charID int) error
+
+	// CharacterTransactions returns a character's personal market transactions
+	// made before the specified time, oldest first.
+	CharacterTransactions(userID, charID int, until time.Time) ([]WalletTransaction, error)
+
+	// CharacterMarketFees returns the broker fees and sales tax that a
+	// character paid during the specified period.
+	CharacterMarketFees(userID, charID int, from, until time.Time) (*MarketFees, error)
+
+	// GetAPIMarketOrders replaces a character's market orders in the database
+	// with those returned by the XML API.
+	GetAPIMarketOrders(key XMLAPIKey, charID int) error
+
+	// CharacterMarketOrders returns a character's open market orders.
+	CharacterMarketOrders(userID, charID int) ([]MarketOrder, error)
+
+	// GetAPIContracts replaces a character's contracts in the database with
+	// those returned by the XML API, along with the contents of any that are
+	// still active.
+	GetAPIContracts(key XMLAPIKey, charID int) error
+
+	// CharacterContracts returns a character's outstanding and in-progress
+	// contracts and their items.
+	CharacterContracts(userID, charID int) ([]Contract, error)
+
+	// GetAPIPlanets replaces a character's planetary colonies in the database
+	// with those returned by the XML API, including their pins and routes.
+	GetAPIPlanets(key XMLAPIKey, charID int) error
+
+	// CharacterColonies returns the planetary colonies and pins of one of the
+	// user's characters, or of all of them if charID is zero.
+	CharacterColonies(userID, charID int) ([]Colony, error)
+
+	// PlanetSchematics returns the inputs and outputs of every planetary
+	// schematic, indexed by schematic ID.
+	PlanetSchematics() (map[int][]SchematicFlow, error)
+
+	// ImportMarketSnapshot stores the orders from an order-book snapshot,
+	// replacing any previously stored orders for the same items in the same
+	// regions, and records them as generated at the passed time by uploader.
+	ImportMarketSnapshot(orders []SnapshotOrder, generatedAt time.Time, uploader string) error
+
+	// SnapshotOrders returns the unexpired snapshot orders for an item in a
+	// region, limited to one solar system if systemID is nonzero.
+	SnapshotOrders(typeID, regionID, systemID int) ([]SnapshotOrder, error)
+
+	// ReplaceMarketOrders replaces the local market's orders for each item and
+	// region in sets with those uploaded, unless the orders we have were
+	// generated at the same time or later; it returns whether each set's
+	// orders were replaced. Either every fresh set is stored or, on error,
+	// none is.
+	ReplaceMarketOrders(sets []MarketOrderSet, uploader string) ([]bool, error)
+
+	// RecordPriceHistory stores a day's market snapshot for an item at a
+	// station, replacing any earlier snapshot for the same day.
+	RecordPriceHistory(entry PriceHistoryEntry) error
+
+	// PriceHistory returns an item's market snapshots at a station over the
+	// specified number of days, oldest first.
+	PriceHistory(typeID, stationID, days int) ([]PriceHistoryEntry, error)
+
+	// DailyDepletion returns, for each item with recorded history at a
+	// station, the average daily fall in its order-book volume over the past
+	// number of days. This is an estimate of traded volume, not a measurement
+	// of it. Items without enough history are left out.
+	DailyDepletion(stationID, days int) (map[int]float64, error)
+
+	// MarketGroupItems returns the names of the items in a market group,
+	// including those in its subgroups.
+	MarketGroupItems(marketGroupID int) ([]string, error)
+
+	// MarketGroupPath returns the IDs of the market group containing an item
+	// and of each group above it, nearest first. It's empty if the item isn't
+	// on the market.
+	MarketGroupPath(typeID int) ([]int, error)
+
+	// ItemNames returns the names of every item that can be traded on the
+	// market.
+	ItemNames() ([]string, error)
+
+	// StargateJumps returns every stargate connection between two solar
+	// systems, in each direction.
+	StargateJumps() ([]StargateJump, error)
+
+	// SaveAppraisal stores an appraisal under its ID and sets its creation
+	// time. It returns ErrDuplicateID if the ID is already taken.
+	SaveAppraisal(a *Appraisal) error
+
+	// Appraisal returns the appraisal with the passed ID, or sql.ErrNoRows if
+	// there isn't one.
+	Appraisal(id string) (*Appraisal, error)
+
+	// UserAppraisals returns a user's most recent appraisals, newest first,
+	// without their items and prices.
+	UserAppraisals(userID int) ([]Appraisal, error)
+
+	// Watchlists returns a user's watchlists and their items.
+	Watchlists(userID int) ([]Watchlist, error)
+
+	// SaveWatchlist creates a watchlist (if its ID is zero) or replaces an
+	// existing one belonging to its user, including its items. Items with a
+	// nonzero ID are updated in place; the IDs of the list and any new items
+	// are filled in. Returns sql.ErrNoRows if the list to be replaced doesn't
+	// exist or belongs to another user, and ErrUnknownWatchedItem if an item
+	// to be updated isn't in it; nothing is saved in either case.
+	SaveWatchlist(list *Watchlist) error
+
+	// DeleteWatchlist deletes one of a user's watchlists.
+	DeleteWatchlist(userID, watchlistID int) error
+
+	// AllWatchedItems returns every item in every user's watchlists.
+	AllWatchedItems() ([]WatchedItem, error)
+
+	// SetWatchAlerting records whether a watched item's price is past one of
+	// its thresholds.
+	SetWatchAlerting(watchedItemID int, alerting bool) error
+
+	// RecordAlert stores an alert and marks its item as alerting. The alert's
+	// ID and trigger time are filled in.
+	RecordAlert(alert *Alert) error
+
+	// MarkAlertsDelivered records that alerts were delivered to their owner.
+	MarkAlertsDelivered(alertIDs []int64) error
+
+	// UndeliveredAlerts returns the alerts triggered since the passed time
+	// that are still to be delivered to their watchlist's webhook, oldest
+	// first.
+	UndeliveredAlerts(since time.Time) ([]UndeliveredAlert, error)
+
+	// UserAlerts returns the most recent alerts triggered by a user's
+	// watchlists, newest first.
+	UserAlerts(userID int) ([]Alert, error)
+
+	// UserTickers returns the market tickers defined by a user.
+	UserTickers(userID int) ([]UserTicker, error)
+
+	// SaveTicker creates or replaces one of a user's tickers.
+	SaveTicker(t *UserTicker) error
+
+	// DeleteTicker deletes one of a user's tickers.
+	DeleteTicker(userID int, name string) error
+
+	// BuybackPrograms returns the buyback programs administered by a user.
+	BuybackPrograms(userID int) ([]BuybackProgram, error)
+
+	// BuybackProgram returns a buyback program, or sql.ErrNoRows if it
+	// doesn't exist or has been deleted.
+	BuybackProgram(programID int) (*BuybackProgram, error)
+
+	// SaveBuybackProgram creates a buyback program (if its ID is zero) or
+	// replaces one; its ID is filled in on creation. It returns
+	// sql.ErrNoRows if the program to be replaced doesn't belong to its user.
+	SaveBuybackProgram(p *BuybackProgram) error
+
+	// DeleteBuybackProgram deletes one of a user's buyback programs. Quotes
+	// already made under it are kept.
+	DeleteBuybackProgram(userID, programID int) error
+
+	// SaveBuybackQuote stores a buyback quote and fills in its creation time.
+	SaveBuybackQuote(q *BuybackQuote) error
+
+	// BuybackQuote returns a buyback quote.
+	BuybackQuote(quoteID string) (*BuybackQuote, error)
+
 	// UnusedSalvage returns a character's salvage inventory that is not used
 	// by any blueprint he owns.
 	UnusedSalvage(userid, characterID int) ([]evego.InventoryItem, error)
@@ -0,0 +1,128 @@
+/*
+Copyright © 2014–6 Brad Ackerman.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package db
+
+import (
+	"database/sql"
+	"regexp"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// keyErrorCodes maps the error codes returned by the XML API to the key status
+// they indicate.
+var keyErrorCodes = map[string]KeyStatus{
+	"200": KeyInsufficientAccess, // Security level not high enough
+	"202": KeyInvalid,            // API key authentication failure
+	"203": KeyInvalid,            // Authentication failure
+	"204": KeyInvalid,            // Authentication failure
+	"205": KeyInvalid,            // Authentication failure (final pass)
+	"206": KeyInsufficientAccess, // Character must have Accountant or Junior Accountant roles
+	"210": KeyInvalid,            // Authentication failure
+	"211": KeyInvalid,            // Login denied by account status
+	"212": KeyInvalid,            // Authentication failure (final pass)
+	"220": KeyInsufficientAccess, // Invalid corporation key
+	"221": KeyInsufficientAccess, // Illegal page request (access mask)
+	"222": KeyExpired,            // Key has expired
+	"223": KeyExpired,            // Authentication failure (legacy key)
+}
+
+// keyErrorFragments maps fragments of the error messages returned by the XML
+// API to the key status they indicate, for errors where the code has been
+// lost along the way.
+var keyErrorFragments = []struct {
+	fragment string
+	status   KeyStatus
+}{
+	{"expired", KeyExpired},
+	{"authentication failure", KeyInvalid},
+	{"403 forbidden", KeyInvalid},
+	{"security level", KeyInsufficientAccess},
+	{"access mask", KeyInsufficientAccess},
+	{"illegal page request", KeyInsufficientAccess},
+	{"role requirements", KeyInsufficientAccess},
+}
+
+// apiErrorCodeRE extracts the XML API's error code from an error message.
+var apiErrorCodeRE = regexp.MustCompile(`(?i)(?:error|code)\D{0,3}(\d{3})\b`)
+
+// ClassifyKeyError determines what an error returned by the XML API says about
+// the key used to make the call. Errors that don't indicate a problem with the
+// key are considered transient.
+func ClassifyKeyError(err error) KeyStatus {
+	if err == nil {
+		return KeyOK
+	}
+	msg := err.Error()
+	if match := apiErrorCodeRE.FindStringSubmatch(msg); match != nil {
+		if status, found := keyErrorCodes[match[1]]; found {
+			return status
+		}
+	}
+	msg = strings.ToLower(msg)
+	for _, f := range keyErrorFragments {
+		if strings.Contains(msg, f.fragment) {
+			return f.status
+		}
+	}
+	return KeyTransientError
+}
+
+// recordKeyResult stores the outcome of an XML API call made using the
+// specified key for a step of syncing a character. The key's own status is
+// only changed by the core steps, or by errors that mean the key can't be used
+// at all. Failure to record it is logged but otherwise ignored, as it
+// shouldn't cause the sync itself to fail.
+func (d *dbInterface) recordKeyResult(keyID, charID int, step string, apiErr error) {
+	status := ClassifyKeyError(apiErr)
+	var lastError sql.NullString
+	if apiErr != nil {
+		lastError = sql.NullString{String: apiErr.Error(), Valid: true}
+	}
+	err := d.recordKeyStep(keyID, charID, step, status, lastError)
+	if err != nil {
+		log.Printf("Unable to record status %v of %v for API key %v: %v", status, step, keyID, err)
+	}
+	if !coreSteps[step] && status != KeyInvalid && status != KeyExpired {
+		return
+	}
+	_, err = d.setAPIKeyStatusStmt.Exec(keyID, string(status), lastError)
+	if err != nil {
+		log.Printf("Unable to record status %v for API key %v: %v", status, keyID, err)
+	}
+}
+
+func (d *dbInterface) recordKeyStep(keyID, charID int, step string, status KeyStatus,
+	lastError sql.NullString) error {
+	tx, err := d.db.Beginx()
+	if err != nil {
+		return err
+	}
+	_, err = tx.Stmtx(d.clearAPIKeyStepStmt).Exec(keyID, charID, step)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	_, err = tx.Stmtx(d.insertAPIKeyStepStmt).Exec(keyID, charID, step, string(status), lastError)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,59 @@
+/*
+Copyright © 2014–6 Brad Ackerman.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package db_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/backerman/eveindy/pkg/db"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestClassifyKeyError(t *testing.T) {
+	Convey("Verify that XML API errors are mapped to key statuses", t, func() {
+		Convey("A successful call leaves the key OK", func() {
+			So(db.ClassifyKeyError(nil), ShouldEqual, db.KeyOK)
+		})
+		Convey("Error codes are recognized", func() {
+			So(db.ClassifyKeyError(errors.New("API error 222: Key has expired. Contact key owner for access renewal.")),
+				ShouldEqual, db.KeyExpired)
+			So(db.ClassifyKeyError(errors.New("error code 203")), ShouldEqual, db.KeyInvalid)
+			So(db.ClassifyKeyError(errors.New("Error 221: Illegal page request!")),
+				ShouldEqual, db.KeyInsufficientAccess)
+		})
+		Convey("Messages without a code are recognized", func() {
+			So(db.ClassifyKeyError(errors.New("Authentication failure.")), ShouldEqual, db.KeyInvalid)
+			So(db.ClassifyKeyError(errors.New("Current security level not high enough.")),
+				ShouldEqual, db.KeyInsufficientAccess)
+		})
+		Convey("Other errors are transient", func() {
+			So(db.ClassifyKeyError(errors.New("dial tcp 10.0.0.200:443: connection refused")),
+				ShouldEqual, db.KeyTransientError)
+			So(db.ClassifyKeyError(errors.New("Error 520: Unexpected failure accessing database.")),
+				ShouldEqual, db.KeyTransientError)
+		})
+		Convey("Only working or transiently failing keys are usable", func() {
+			So(db.KeyOK.IsUsable(), ShouldBeTrue)
+			So(db.KeyTransientError.IsUsable(), ShouldBeTrue)
+			So(db.KeyExpired.IsUsable(), ShouldBeFalse)
+			So(db.KeyInvalid.IsUsable(), ShouldBeFalse)
+		})
+	})
+}
@@ -0,0 +1,76 @@
+/*
+Copyright © 2014–6 Brad Ackerman.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package db
+
+import (
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/backerman/evego"
+)
+
+func (d *dbInterface) GetAPIMarketOrders(key XMLAPIKey, charID int) error {
+	k := &evego.XMLKey{
+		KeyID:            key.ID,
+		VerificationCode: key.VerificationCode,
+	}
+	orders, err := d.charAPI.MarketOrders(k, charID)
+	d.recordKeyResult(key.ID, charID, stepMarketOrders, err)
+	if err != nil {
+		return err
+	}
+	tx, err := d.db.Beginx()
+	if err != nil {
+		return err
+	}
+	// Clear orders before inserting the API's information.
+	_, err = tx.Stmtx(d.clearMarketOrdersStmt).Exec(key.ID, charID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	insertStmt := tx.Stmtx(d.insertMarketOrderStmt)
+	for _, o := range orders {
+		_, err = insertStmt.Exec(o.OrderID, charID, key.ID, o.StationID, o.VolEntered,
+			o.VolRemaining, o.MinVolume, o.OrderState, o.TypeID, o.Range, o.Duration,
+			o.Escrow, o.Price, o.Bid != 0, o.Issued)
+		if err != nil {
+			log.Printf("Failed to insert market order %+v", o)
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (d *dbInterface) CharacterMarketOrders(userID, charID int) ([]MarketOrder, error) {
+	rows, err := d.getOpenMarketOrdersStmt.Queryx(userID, charID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	orders := make([]MarketOrder, 0, 20)
+	for rows.Next() {
+		o := MarketOrder{}
+		err = rows.StructScan(&o)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, o)
+	}
+	return orders, nil
+}
@@ -0,0 +1,163 @@
+/*
+Copyright © 2014–6 Brad Ackerman.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package db
+
+import (
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/backerman/evego"
+	"github.com/backerman/eveindy/pkg/xmlapi"
+)
+
+// nullTypeID converts a zero type ID (as returned by the XML API for empty
+// pins) to NULL.
+func nullTypeID(typeID int) interface{} {
+	if typeID == 0 {
+		return nil
+	}
+	return typeID
+}
+
+func (d *dbInterface) GetAPIPlanets(key XMLAPIKey, charID int) error {
+	k := &evego.XMLKey{
+		KeyID:            key.ID,
+		VerificationCode: key.VerificationCode,
+	}
+	colonies, err := d.charAPI.PlanetaryColonies(k, charID)
+	d.recordKeyResult(key.ID, charID, stepPlanets, err)
+	if err != nil {
+		return err
+	}
+	pins := make(map[int][]xmlapi.Pin)
+	routes := make(map[int][]xmlapi.Route)
+	for _, c := range colonies {
+		pins[c.PlanetID], err = d.charAPI.PlanetaryPins(k, charID, c.PlanetID)
+		d.recordKeyResult(key.ID, charID, stepPlanets, err)
+		if err != nil {
+			return err
+		}
+		routes[c.PlanetID], err = d.charAPI.PlanetaryRoutes(k, charID, c.PlanetID)
+		d.recordKeyResult(key.ID, charID, stepPlanets, err)
+		if err != nil {
+			return err
+		}
+	}
+
+	tx, err := d.db.Beginx()
+	if err != nil {
+		return err
+	}
+	// Clear colonies before inserting the API's information.
+	_, err = tx.Stmtx(d.clearColoniesStmt).Exec(key.ID, charID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	insertColonyStmt := tx.Stmtx(d.insertColonyStmt)
+	insertPinStmt := tx.Stmtx(d.insertPinStmt)
+	insertRouteStmt := tx.Stmtx(d.insertRouteStmt)
+	for _, c := range colonies {
+		_, err = insertColonyStmt.Exec(charID, key.ID, c.PlanetID, c.SolarSystemID,
+			c.PlanetName, c.PlanetTypeID, c.UpgradeLevel, c.NumberOfPins, c.LastUpdate)
+		if err != nil {
+			log.Printf("Failed to insert colony %+v", c)
+			tx.Rollback()
+			return err
+		}
+		for _, p := range pins[c.PlanetID] {
+			_, err = insertPinStmt.Exec(charID, c.PlanetID, p.PinID, p.TypeID,
+				p.SchematicID, p.CycleTime, p.QuantityPerCycle,
+				nullTypeID(p.ContentTypeID), p.ContentQuantity,
+				nullTime(p.LastLaunchTime), nullTime(p.InstallTime),
+				nullTime(p.ExpiryTime))
+			if err != nil {
+				log.Printf("Failed to insert pin %+v", p)
+				tx.Rollback()
+				return err
+			}
+		}
+		for _, r := range routes[c.PlanetID] {
+			_, err = insertRouteStmt.Exec(charID, c.PlanetID, r.RouteID,
+				r.SourcePinID, r.DestinationPinID, r.ContentTypeID, r.Quantity,
+				int64ArrayString(r.Waypoints()))
+			if err != nil {
+				log.Printf("Failed to insert route %+v", r)
+				tx.Rollback()
+				return err
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+func (d *dbInterface) CharacterColonies(userID, charID int) ([]Colony, error) {
+	rows, err := d.getColoniesStmt.Queryx(userID, charID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	type colonyKey struct {
+		charID, planetID int
+	}
+	colonies := make([]Colony, 0, 6)
+	index := make(map[colonyKey]int)
+	for rows.Next() {
+		c := Colony{Pins: []Pin{}}
+		err = rows.StructScan(&c)
+		if err != nil {
+			return nil, err
+		}
+		index[colonyKey{c.CharacterID, c.PlanetID}] = len(colonies)
+		colonies = append(colonies, c)
+	}
+
+	pinRows, err := d.getPinsStmt.Queryx(userID, charID)
+	if err != nil {
+		return nil, err
+	}
+	defer pinRows.Close()
+	for pinRows.Next() {
+		p := Pin{}
+		err = pinRows.StructScan(&p)
+		if err != nil {
+			return nil, err
+		}
+		if idx, found := index[colonyKey{p.CharacterID, p.PlanetID}]; found {
+			colonies[idx].Pins = append(colonies[idx].Pins, p)
+		}
+	}
+	return colonies, nil
+}
+
+func (d *dbInterface) PlanetSchematics() (map[int][]SchematicFlow, error) {
+	rows, err := d.getSchematicsStmt.Queryx()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	schematics := make(map[int][]SchematicFlow)
+	for rows.Next() {
+		f := SchematicFlow{}
+		err = rows.StructScan(&f)
+		if err != nil {
+			return nil, err
+		}
+		schematics[f.SchematicID] = append(schematics[f.SchematicID], f)
+	}
+	return schematics, nil
+}
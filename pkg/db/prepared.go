@@ -35,11 +35,51 @@ const (
 
 	// Get all API keys that have been registered for a user.
 	getAPIKeysStmt = `
-	SELECT userid, id, vcode, label
+	SELECT userid, id, vcode, label, status, COALESCE(lastError, '') lasterror,
+	       lastSuccess
 	FROM   apikeys
 	WHERE  userid = $1
 	`
 
+	// Get all API keys, regardless of user, for the background refresher.
+	getAllAPIKeysStmt = `
+	SELECT userid, id, vcode, label, status, COALESCE(lastError, '') lasterror,
+	       lastSuccess
+	FROM   apikeys
+	`
+
+	// Record the outcome of a call using an API key. The first argument is the
+	// key's ID, the second its new status, and the third the error message (or
+	// NULL if the call succeeded).
+	setAPIKeyStatusStmt = `
+	UPDATE apikeys
+	SET    status = $2, lastError = $3,
+	       lastSuccess = CASE WHEN $2 = 'ok' THEN CURRENT_TIMESTAMP
+	                          ELSE lastSuccess END
+	WHERE  id = $1
+	`
+
+	// Remove the recorded outcome of a step of a key's sync, to be replaced.
+	clearAPIKeyStepStmt = `
+	DELETE FROM apikeySteps
+	WHERE  keyid = $1 AND charid = $2 AND step = $3
+	`
+
+	// Record the outcome of a step of a key's sync.
+	insertAPIKeyStepStmt = `
+	INSERT INTO apikeySteps (keyid, charid, step, status, lastError)
+	VALUES ($1, $2, $3, $4, $5)
+	`
+
+	// Get the outcome of each step of a key's most recent sync.
+	// Lowercase everything for sqlx.
+	getAPIKeyStepsStmt = `
+	SELECT   charid, step, status, COALESCE(lastError, '') lasterror, updated
+	FROM     apikeySteps
+	WHERE    keyid = $1
+	ORDER BY charid, step
+	`
+
 	// Add an API key to the database.
 	addAPIKeyStmt = `
 	INSERT
@@ -72,6 +112,13 @@ const (
 	WHERE userid = $1 and apikey = $2
 	`
 
+	// Get the IDs of all of a user's characters.
+	getUserCharacterIDsStmt = `
+	SELECT id
+	FROM   characters
+	WHERE  userid = $1
+	`
+
 	apiKeyClearSkillsStmt = `
 	DELETE FROM skills
 	WHERE charid = $1
@@ -114,6 +161,11 @@ const (
 	WHERE charid = $1
 	`
 
+	apiKeyClearAgentStandingsStmt = `
+	DELETE FROM agentStandings
+	WHERE charid = $1
+	`
+
 	apiKeyInsertCorpStandingsStmt = `
 	INSERT INTO corpStandings(charid, corp, standing)
 	VALUES ($1, $2, $3)
@@ -124,6 +176,11 @@ const (
 	VALUES ($1, $2, $3)
 	`
 
+	apiKeyInsertAgentStandingsStmt = `
+	INSERT INTO agentStandings(charid, agent, standing)
+	VALUES ($1, $2, $3)
+	`
+
 	// Get NPC corporation and faction standings for a character.
 	getStandingsStmt = `
 	WITH availableCharacters AS (
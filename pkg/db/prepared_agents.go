@@ -0,0 +1,64 @@
+/*
+Copyright © 2014–6 Brad Ackerman.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package db
+
+const (
+	// Agents
+
+	// Get the agents available to each of a user's characters, along with the
+	// character's standings with the agent, its corporation, and its faction.
+	// The second argument limits the results to one NPC corporation; if it's
+	// zero, agents of every corporation with which one of the user's
+	// characters has standings are returned. The third and fourth arguments
+	// filter by agent level and division name, and are ignored if zero or
+	// empty.
+	// Lowercase everything for sqlx.
+	getAgentsStmt = `
+  WITH availableCharacters AS (
+    SELECT id, name
+    FROM   characters
+    WHERE  userid = $1
+  ), relevantCorps AS (
+    SELECT DISTINCT corp
+    FROM   corpStandings cs
+    JOIN   availableCharacters a ON a.id = cs.charid
+  )
+  SELECT    c.id charid, c.name charname, ag."agentID" agentid,
+            an."itemName" agentname, ag."level", d."divisionName" division,
+            ag."corporationID" corporationid, cn."itemName" corporationname,
+            ag."locationID" locationid, COALESCE(s."stationName", '') stationname,
+            COALESCE(s."solarSystemID", 0) solarsystemid,
+            agst.standing agentstanding, cs.standing corpstanding,
+            fs.standing facstanding
+  FROM      availableCharacters c
+  CROSS JOIN "agtAgents" ag
+  JOIN      "invNames" an ON an."itemID" = ag."agentID"
+  JOIN      "crpNPCDivisions" d ON d."divisionID" = ag."divisionID"
+  JOIN      "crpNPCCorporations" npc ON npc."corporationID" = ag."corporationID"
+  JOIN      "invNames" cn ON cn."itemID" = ag."corporationID"
+  LEFT JOIN allStations s ON s."stationID" = ag."locationID"
+  LEFT JOIN agentStandings agst ON agst.charid = c.id AND agst.agent = ag."agentID"
+  LEFT JOIN corpStandings cs ON cs.charid = c.id AND cs.corp = ag."corporationID"
+  LEFT JOIN facStandings fs ON fs.charid = c.id AND fs.faction = npc."factionID"
+  WHERE     (($2 = 0 AND ag."corporationID" IN (SELECT corp FROM relevantCorps))
+             OR ag."corporationID" = $2)
+  AND       ($3 = 0 OR ag."level" = $3)
+  AND       ($4 = '' OR LOWER(d."divisionName") = LOWER($4))
+  ORDER BY  c.name, ag."level" DESC, an."itemName"
+  `
+)
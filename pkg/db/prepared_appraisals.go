@@ -0,0 +1,52 @@
+/*
+Copyright © 2014–6 Brad Ackerman.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package db
+
+const (
+	// Appraisals
+
+	// Save an appraisal.
+	insertAppraisalStmt = `
+  INSERT INTO appraisals
+    (id, userid, stationID, region, location, method, topPercent, items,
+     prices, unmatched, buyTotal, sellTotal)
+  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+  RETURNING created
+  `
+
+	// Get an appraisal by ID.
+	// Lowercase everything for sqlx.
+	getAppraisalStmt = `
+  SELECT id, COALESCE(userid, 0) userid, created,
+         COALESCE(stationid, 0) stationid, region, location, method,
+         toppercent, items, prices, unmatched, buytotal, selltotal
+  FROM   appraisals
+  WHERE  id = $1
+  `
+
+	// Get a user's most recent appraisals, without their contents.
+	// Lowercase everything for sqlx.
+	getUserAppraisalsStmt = `
+  SELECT   id, userid, created, COALESCE(stationid, 0) stationid, region,
+           location, method, toppercent, buytotal, selltotal
+  FROM     appraisals
+  WHERE    userid = $1
+  ORDER BY created DESC
+  LIMIT    $2
+  `
+)
@@ -0,0 +1,79 @@
+/*
+Copyright © 2014–6 Brad Ackerman.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package db
+
+const (
+	// Buyback programs and quotes
+
+	// Get a user's buyback programs.
+	// Lowercase everything for sqlx.
+	getUserBuybackProgramsStmt = `
+  SELECT   id, userid, name, stationid, refineyield, rules
+  FROM     buybackPrograms
+  WHERE    userid = $1 AND NOT deleted
+  ORDER BY name
+  `
+
+	// Get a buyback program by ID, unless it's been deleted.
+	// Lowercase everything for sqlx.
+	getBuybackProgramStmt = `
+  SELECT id, userid, name, stationid, refineyield, rules
+  FROM   buybackPrograms
+  WHERE  id = $1 AND NOT deleted
+  `
+
+	// Create a buyback program.
+	insertBuybackProgramStmt = `
+  INSERT INTO buybackPrograms (userid, name, stationID, refineYield, rules)
+  VALUES ($1, $2, $3, $4, $5)
+  RETURNING id
+  `
+
+	// Update a buyback program, if it belongs to the specified user.
+	updateBuybackProgramStmt = `
+  UPDATE buybackPrograms
+  SET    name = $3, stationID = $4, refineYield = $5, rules = $6
+  WHERE  id = $1 AND userid = $2 AND NOT deleted
+  `
+
+	// Delete a buyback program, if it belongs to the specified user. It's only
+	// marked as deleted, as its quotes refer to it.
+	deleteBuybackProgramStmt = `
+  UPDATE buybackPrograms
+  SET    deleted = true
+  WHERE  id = $1 AND userid = $2
+  `
+
+	// Save a buyback quote.
+	insertBuybackQuoteStmt = `
+  INSERT INTO buybackQuotes
+    (id, programID, userid, expires, location, lines, unmatched, total)
+  VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+  RETURNING created
+  `
+
+	// Get a buyback quote by ID, with the name of its program.
+	// Lowercase everything for sqlx.
+	getBuybackQuoteStmt = `
+  SELECT q.id, q.programid, p.name program, COALESCE(q.userid, 0) userid,
+         q.created, q.expires, q.location, q.lines, q.unmatched, q.total
+  FROM   buybackQuotes q
+  JOIN   buybackPrograms p ON p.id = q.programID
+  WHERE  q.id = $1
+  `
+)
@@ -0,0 +1,82 @@
+/*
+Copyright © 2014–6 Brad Ackerman.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package db
+
+const (
+	// Contracts
+
+	// Clear toon's contracts; their items are removed by cascade.
+	clearContractsStmt = `
+  DELETE FROM contracts
+  WHERE apiKey = $1 AND charID = $2
+  `
+
+	// Insert a contract.
+	insertContractStmt = `
+  INSERT INTO contracts
+    (contractID, charID, apikey, issuerID, issuerCorpID, assigneeID, acceptorID,
+     startStationID, endStationID, contractType, status, title, forCorp,
+     availability, dateIssued, dateExpired, dateAccepted, dateCompleted,
+     numDays, price, reward, collateral, buyout, volume)
+  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15,
+          $16, $17, $18, $19, $20, $21, $22, $23, $24)
+  `
+
+	// Insert an item in a contract.
+	insertContractItemStmt = `
+  INSERT INTO contractItems
+    (contractID, charID, recordID, typeID, quantity, singleton, included)
+  VALUES ($1, $2, $3, $4, $5, $6, $7)
+  `
+
+	// Get a character's outstanding and in-progress contracts.
+	// Lowercase everything for sqlx.
+	getActiveContractsStmt = `
+  WITH availableCharacters AS (
+    SELECT id
+    FROM   characters
+    WHERE  userid = $1
+  )
+  SELECT   contractid, issuerid, issuercorpid, assigneeid, acceptorid,
+           startstationid, endstationid, contracttype, status, title, forcorp,
+           availability, dateissued, dateexpired, dateaccepted, numdays, price,
+           reward, collateral, buyout, volume
+  FROM     contracts c
+  JOIN     availableCharacters a ON a.id = c.charID
+  WHERE    charID = $2 AND status IN ('Outstanding', 'InProgress')
+  ORDER BY dateissued DESC
+  `
+
+	// Get the items in a character's outstanding and in-progress contracts.
+	// Lowercase everything for sqlx.
+	getActiveContractItemsStmt = `
+  WITH availableCharacters AS (
+    SELECT id
+    FROM   characters
+    WHERE  userid = $1
+  )
+  SELECT   i.contractid, i.typeid, "typeName" typename, i.quantity,
+           i.singleton, i.included
+  FROM     contractItems i
+  JOIN     contracts c ON c.contractID = i.contractID AND c.charID = i.charID
+  JOIN     availableCharacters a ON a.id = i.charID
+  JOIN     "invTypes" t ON t."typeID" = i.typeID
+  WHERE    i.charID = $2 AND c.status IN ('Outstanding', 'InProgress')
+  ORDER BY "typeName"
+  `
+)
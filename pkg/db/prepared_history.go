@@ -0,0 +1,103 @@
+/*
+Copyright © 2014–6 Brad Ackerman.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package db
+
+const (
+	// Price history
+
+	// Remove an item's history entry for a day, to be replaced by a newer
+	// snapshot.
+	clearPriceHistoryStmt = `
+  DELETE FROM priceHistory
+  WHERE typeID = $1 AND stationID = $2 AND day = $3
+  `
+
+	// Insert an item's history entry for a day.
+	insertPriceHistoryStmt = `
+  INSERT INTO priceHistory
+    (typeID, stationID, day, bestBuy, bestSell, buyVolume, sellVolume,
+     buyOrders, sellOrders, sellP5, sellMedian, buyP95, buyMedian)
+  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+  `
+
+	// Get an item's history at a station for the last $3 days.
+	// Lowercase everything for sqlx.
+	getPriceHistoryStmt = `
+  SELECT   typeid, stationid, day, bestbuy, bestsell, buyvolume, sellvolume,
+           buyorders, sellorders, sellp5, sellmedian, buyp95, buymedian
+  FROM     priceHistory
+  WHERE    typeID = $1 AND stationID = $2
+  AND      day > CURRENT_DATE - $3::integer
+  ORDER BY day
+  `
+
+	// Average the daily fall in order-book volume of each item at a station
+	// over the last $2 days, from consecutive snapshots. This approximates
+	// traded volume: trades offset by new orders are missed, and cancelled or
+	// expired orders are counted.
+	// Lowercase everything for sqlx.
+	getDailyDepletionStmt = `
+  SELECT   typeid,
+           AVG(GREATEST(prevsell - sellvolume, 0) +
+               GREATEST(prevbuy - buyvolume, 0)) dailydepletion
+  FROM     (SELECT typeID, sellVolume, buyVolume,
+                   lag(sellVolume) OVER w prevsell,
+                   lag(buyVolume) OVER w prevbuy
+            FROM   priceHistory
+            WHERE  stationID = $1
+            AND    day > CURRENT_DATE - $2::integer
+            WINDOW w AS (PARTITION BY typeID ORDER BY day)) h
+  WHERE    prevsell IS NOT NULL
+  GROUP BY typeid
+  `
+
+	// Get the names of the published items in a market group and its
+	// subgroups.
+	getMarketGroupItemsStmt = `
+  WITH RECURSIVE groups("marketGroupID") AS (
+    SELECT $1::integer
+    UNION
+    SELECT mg."marketGroupID"
+    FROM   "invMarketGroups" mg
+    JOIN   groups g ON mg."parentGroupID" = g."marketGroupID"
+  )
+  SELECT   t."typeName"
+  FROM     "invTypes" t
+  JOIN     groups g USING ("marketGroupID")
+  WHERE    t.published
+  ORDER BY t."typeName"
+  `
+
+	// Get the market group of an item and each of its ancestors, nearest
+	// first.
+	getMarketGroupPathStmt = `
+  WITH RECURSIVE path("marketGroupID", "parentGroupID", depth) AS (
+    SELECT mg."marketGroupID", mg."parentGroupID", 0
+    FROM   "invTypes" t
+    JOIN   "invMarketGroups" mg USING ("marketGroupID")
+    WHERE  t."typeID" = $1
+    UNION ALL
+    SELECT mg."marketGroupID", mg."parentGroupID", p.depth + 1
+    FROM   "invMarketGroups" mg
+    JOIN   path p ON mg."marketGroupID" = p."parentGroupID"
+  )
+  SELECT   "marketGroupID"
+  FROM     path
+  ORDER BY depth
+  `
+)
@@ -0,0 +1,85 @@
+/*
+Copyright © 2014–6 Brad Ackerman.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package db
+
+const (
+	// Industry jobs
+
+	// Delete jobs that are about to be reinserted (PostgreSQL 9.4 has no
+	// upsert). The first argument is the character whose key provided them,
+	// the second whether these are corporation jobs, and the third the IDs.
+	deleteIndustryJobsStmt = `
+  DELETE FROM industryJobs
+  WHERE charID = $1 AND isCorporation = $2
+  AND   jobID = ANY ($3::bigint[])
+  `
+
+	// Insert an industry job.
+	insertIndustryJobStmt = `
+  INSERT INTO industryJobs
+    (jobID, charID, apikey, isCorporation, installerID, installerName,
+     facilityID, solarSystemID, stationID, activityID, blueprintID,
+     blueprintTypeID, runs, licensedRuns, productTypeID, status, startDate,
+     endDate, completedDate)
+  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15,
+          $16, $17, $18, $19)
+  `
+
+	// Jobs that have dropped off the API's list without a final status may
+	// have been delivered, cancelled or reverted; mark them as gone (the
+	// fourth argument). The first argument is the character, the second
+	// whether these are corporation jobs, and the third the IDs of jobs still
+	// being returned by the API.
+	markJobsGoneStmt = `
+  UPDATE industryJobs
+  SET    status = $4, completedDate = COALESCE(completedDate, endDate)
+  WHERE  charID = $1 AND isCorporation = $2 AND status < 100
+  AND    NOT (jobID = ANY ($3::bigint[]))
+  `
+
+	// Get jobs installed by, or visible to, a user's character. Jobs that
+	// finished more than 30 days ago are omitted. A corporation job can be
+	// stored once for each character whose key sees it; a copy still reported
+	// by the API is preferred to one marked gone (status 199).
+	// Lowercase everything for sqlx.
+	getIndustryJobsStmt = `
+  WITH availableCharacters AS (
+    SELECT id
+    FROM   characters
+    WHERE  userid = $1
+  ), visibleJobs AS (
+    SELECT DISTINCT ON (jobID) j.*
+    FROM     industryJobs j
+    JOIN     availableCharacters a ON a.id = j.charID
+    WHERE    (j.charID = $2 OR j.installerID = $2)
+    ORDER BY jobID, status = 199, status DESC
+  )
+  SELECT   jobid, iscorporation, installerid, installername, facilityid,
+           solarsystemid, stationid, j.activityid, "activityName" activity,
+           blueprintid, blueprinttypeid, bt."typeName" blueprinttypename, runs,
+           licensedruns, producttypeid, pt."typeName" producttypename, status,
+           startdate, enddate, completeddate
+  FROM     visibleJobs j
+  JOIN     "ramActivities" ra ON ra."activityID" = j.activityID
+  JOIN     "invTypes" bt ON bt."typeID" = j.blueprintTypeID
+  LEFT JOIN "invTypes" pt ON pt."typeID" = j.productTypeID
+  WHERE    (status < 100 OR
+            COALESCE(completedDate, endDate) > CURRENT_TIMESTAMP - interval '30 days')
+  ORDER BY enddate
+  `
+)
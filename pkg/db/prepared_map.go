@@ -0,0 +1,32 @@
+/*
+Copyright © 2014–6 Brad Ackerman.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package db
+
+const (
+	// Map lookups
+
+	// Get every stargate connection, with the security of the system it
+	// leads to.
+	// Lowercase everything for sqlx.
+	getStargateJumpsStmt = `
+  SELECT j."fromSolarSystemID" fromsystem, j."toSolarSystemID" tosystem,
+         s.security tosecurity
+  FROM   "mapSolarSystemJumps" j
+  JOIN   "mapSolarSystems" s ON s."solarSystemID" = j."toSolarSystemID"
+  `
+)
@@ -0,0 +1,54 @@
+/*
+Copyright © 2014–6 Brad Ackerman.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package db
+
+const (
+	// Market orders
+
+	// Clear toon's market orders.
+	clearMarketOrdersStmt = `
+  DELETE FROM marketOrders
+  WHERE apiKey = $1 AND charID = $2
+  `
+
+	// Insert a market order.
+	insertMarketOrderStmt = `
+  INSERT INTO marketOrders
+    (orderID, charID, apikey, stationID, volEntered, volRemaining, minVolume,
+     orderState, typeID, orderRange, duration, escrow, price, isBuy, issued)
+  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+  `
+
+	// Get a character's open market orders.
+	// Lowercase everything for sqlx.
+	getOpenMarketOrdersStmt = `
+  WITH availableCharacters AS (
+    SELECT id
+    FROM   characters
+    WHERE  userid = $1
+  )
+  SELECT   orderid, stationid, volentered, volremaining, minvolume, typeid,
+           "typeName" typename, orderrange, duration, escrow, price, isbuy,
+           issued
+  FROM     marketOrders o
+  JOIN     availableCharacters a ON a.id = o.charID
+  JOIN     "invTypes" t ON t."typeID" = o.typeID
+  WHERE    charID = $2 AND orderState = 0
+  ORDER BY "typeName", isbuy
+  `
+)
@@ -0,0 +1,104 @@
+/*
+Copyright © 2014–6 Brad Ackerman.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package db
+
+const (
+	// Planetary interaction
+
+	// Clear toon's colonies; their pins and routes are removed by cascade.
+	clearColoniesStmt = `
+  DELETE FROM planetaryColonies
+  WHERE apiKey = $1 AND charID = $2
+  `
+
+	// Insert a colony.
+	insertColonyStmt = `
+  INSERT INTO planetaryColonies
+    (charID, apikey, planetID, solarSystemID, planetName, planetTypeID,
+     upgradeLevel, numberOfPins, lastUpdate)
+  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+  `
+
+	// Insert a pin.
+	insertPinStmt = `
+  INSERT INTO planetaryPins
+    (charID, planetID, pinID, typeID, schematicID, cycleTime, quantityPerCycle,
+     contentTypeID, contentQuantity, lastLaunchTime, installTime, expiryTime)
+  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+  `
+
+	// Insert a route.
+	insertRouteStmt = `
+  INSERT INTO planetaryRoutes
+    (charID, planetID, routeID, sourcePinID, destinationPinID, contentTypeID,
+     quantity, waypoints)
+  VALUES ($1, $2, $3, $4, $5, $6, $7, $8::bigint[])
+  `
+
+	// Get colonies belonging to one of the user's characters, or to all of them
+	// if the character ID passed is zero.
+	// Lowercase everything for sqlx.
+	getColoniesStmt = `
+  WITH availableCharacters AS (
+    SELECT id, name
+    FROM   characters
+    WHERE  userid = $1
+  )
+  SELECT   a.id charid, a.name charname, planetid, planetname,
+           c.solarsystemid, "solarSystemName" solarsystemname,
+           planettypeid, "typeName" planettypename, upgradelevel,
+           numberofpins, lastupdate
+  FROM     planetaryColonies c
+  JOIN     availableCharacters a ON a.id = c.charID
+  JOIN     "mapSolarSystems" s ON s."solarSystemID" = c.solarSystemID
+  JOIN     "invTypes" t ON t."typeID" = c.planetTypeID
+  WHERE    $2 = 0 OR c.charID = $2
+  ORDER BY a.name, planetname
+  `
+
+	// Get the pins in the colonies returned by getColoniesStmt.
+	// Lowercase everything for sqlx.
+	getPinsStmt = `
+  WITH availableCharacters AS (
+    SELECT id
+    FROM   characters
+    WHERE  userid = $1
+  )
+  SELECT    p.charid, planetid, pinid, p.typeid, pt."typeName" typename,
+            schematicid, cycletime, quantitypercycle,
+            COALESCE(contenttypeid, 0) contenttypeid,
+            COALESCE(ct."typeName", '') contenttypename, contentquantity,
+            expirytime
+  FROM      planetaryPins p
+  JOIN      availableCharacters a ON a.id = p.charID
+  JOIN      "invTypes" pt ON pt."typeID" = p.typeID
+  LEFT JOIN "invTypes" ct ON ct."typeID" = p.contentTypeID
+  WHERE     $2 = 0 OR p.charID = $2
+  `
+
+	// Get the inputs and outputs of every planetary schematic.
+	// Lowercase everything for sqlx.
+	getSchematicsStmt = `
+  SELECT s."schematicID" schematicid, s."cycleTime" cycletime,
+         m."typeID" typeid, t."typeName" typename, m."quantity",
+         m."isInput" isinput
+  FROM   "planetSchematics" s
+  JOIN   "planetSchematicsTypeMap" m ON m."schematicID" = s."schematicID"
+  JOIN   "invTypes" t ON t."typeID" = m."typeID"
+  `
+)
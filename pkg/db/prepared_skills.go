@@ -0,0 +1,163 @@
+/*
+Copyright © 2014–6 Brad Ackerman.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package db
+
+const (
+	// Skill queues and attributes
+
+	// Clear toon's skill queue.
+	clearSkillQueueStmt = `
+  DELETE FROM skillQueue
+  WHERE charID = $1
+  `
+
+	// Insert a skill queue entry.
+	insertSkillQueueStmt = `
+  INSERT INTO skillQueue
+    (charID, position, typeID, level, startSP, endSP, startTime, endTime)
+  VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+  `
+
+	// Clear toon's attributes.
+	clearAttributesStmt = `
+  DELETE FROM characterAttributes
+  WHERE charID = $1
+  `
+
+	// Insert toon's attributes.
+	insertAttributesStmt = `
+  INSERT INTO characterAttributes
+    (charID, intelligence, memory, charisma, perception, willpower,
+     skillPoints, freeSkillPoints)
+  VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+  `
+
+	// Clear toon's implants.
+	clearImplantsStmt = `
+  DELETE FROM implants
+  WHERE charID = $1
+  `
+
+	// Insert an implant.
+	insertImplantStmt = `
+  INSERT INTO implants(charID, typeID)
+  VALUES ($1, $2)
+  `
+
+	// Get user's skill queue.
+	// Lowercase everything for sqlx.
+	getSkillQueueStmt = `
+  WITH availableCharacters AS (
+    SELECT id
+    FROM   characters
+    WHERE  userid = $1
+  )
+  SELECT   position, typeid, "typeName" typename, level, startsp, endsp,
+           starttime, endtime
+  FROM     skillQueue q
+  JOIN     availableCharacters a ON a.id = q.charID
+  JOIN     "invTypes" t ON q.typeid = t."typeID"
+  WHERE    charID = $2
+  ORDER BY position
+  `
+
+	// Get a character's attributes, including implant bonuses. The implant
+	// bonus attributes are 175 (charisma) through 179 (willpower).
+	getAttributesStmt = `
+  WITH availableCharacters AS (
+    SELECT id
+    FROM   characters
+    WHERE  userid = $1
+  ), bonuses AS (
+    SELECT   SUM(CASE WHEN "attributeID" = 175 THEN bonus ELSE 0 END) charisma,
+             SUM(CASE WHEN "attributeID" = 176 THEN bonus ELSE 0 END) intelligence,
+             SUM(CASE WHEN "attributeID" = 177 THEN bonus ELSE 0 END) memory,
+             SUM(CASE WHEN "attributeID" = 178 THEN bonus ELSE 0 END) perception,
+             SUM(CASE WHEN "attributeID" = 179 THEN bonus ELSE 0 END) willpower
+    FROM     (
+      SELECT "attributeID",
+             COALESCE("valueInt", "valueFloat" :: integer) bonus
+      FROM   implants i
+      JOIN   "dgmTypeAttributes" ta ON ta."typeID" = i.typeID
+      WHERE  i.charID = $2
+      AND    ta."attributeID" BETWEEN 175 AND 179
+    ) implantBonuses
+  )
+  SELECT ca.intelligence + COALESCE(b.intelligence, 0) intelligence,
+         ca.memory + COALESCE(b.memory, 0) memory,
+         ca.charisma + COALESCE(b.charisma, 0) charisma,
+         ca.perception + COALESCE(b.perception, 0) perception,
+         ca.willpower + COALESCE(b.willpower, 0) willpower,
+         ca.skillpoints, ca.freeskillpoints
+  FROM   characterAttributes ca
+  JOIN   availableCharacters a ON a.id = ca.charID, bonuses b
+  WHERE  ca.charID = $2
+  `
+
+	// Get the skills required to build (and, for T2 items, invent) the
+	// specified item or blueprint, along with the character's current level
+	// of each and the attributes (275: rank; 180, 181: primary and secondary
+	// attributes) required to estimate training times.
+	getSkillRequirementsStmt = `
+  WITH availableCharacters AS (
+    SELECT id
+    FROM   characters
+    WHERE  userid = $1
+  ), blueprints AS (
+    -- The blueprint that manufactures the requested item, or the item itself
+    -- if it's a blueprint.
+    SELECT "typeID"
+    FROM   "industryActivityProducts"
+    WHERE  "productTypeID" = $3 AND "activityID" = 1
+    UNION
+    SELECT "typeID"
+    FROM   "industryBlueprints"
+    WHERE  "typeID" = $3
+  ), requirements AS (
+    SELECT ias."activityID", ias."skillID", ias."level"
+    FROM   "industryActivitySkills" ias
+    JOIN   blueprints b USING ("typeID")
+    WHERE  ias."activityID" = 1
+    UNION
+    -- Invention skills belong to the blueprint being invented from.
+    SELECT ias."activityID", ias."skillID", ias."level"
+    FROM   "industryActivityProducts" iap
+    JOIN   blueprints b ON iap."productTypeID" = b."typeID"
+    JOIN   "industryActivitySkills" ias
+    ON     ias."typeID" = iap."typeID" AND ias."activityID" = iap."activityID"
+    WHERE  iap."activityID" = 8
+  )
+  SELECT   ra."activityName" activity, r."skillID" typeid, t."typeName" typename,
+           r."level" requiredlevel, COALESCE(s.level, 0) currentlevel,
+           COALESCE(rank."valueInt", rank."valueFloat" :: integer, 1) rank,
+           COALESCE(pa."valueInt", pa."valueFloat" :: integer, 0) primaryattribute,
+           COALESCE(sa."valueInt", sa."valueFloat" :: integer, 0) secondaryattribute
+  FROM     requirements r
+  JOIN     availableCharacters a ON a.id = $2
+  JOIN     "ramActivities" ra ON ra."activityID" = r."activityID"
+  JOIN     "invTypes" t ON t."typeID" = r."skillID"
+  LEFT JOIN skills s ON s.id = r."skillID" AND s.charID = $2
+  LEFT JOIN "dgmTypeAttributes" rank
+  ON       rank."typeID" = r."skillID" AND rank."attributeID" = 275
+  LEFT JOIN "dgmTypeAttributes" pa
+  ON       pa."typeID" = r."skillID" AND pa."attributeID" = 180
+  LEFT JOIN "dgmTypeAttributes" sa
+  ON       sa."typeID" = r."skillID" AND sa."attributeID" = 181
+  ORDER BY r."activityID", t."typeName"
+  `
+)
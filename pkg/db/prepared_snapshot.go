@@ -0,0 +1,74 @@
+/*
+Copyright © 2014–6 Brad Ackerman.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package db
+
+const (
+	// Market snapshots
+
+	// Remove the orders for an item in a region, to be replaced by those in a
+	// newer snapshot.
+	clearSnapshotOrdersStmt = `
+  DELETE FROM marketSnapshot
+  WHERE typeID = $1 AND regionID = $2
+  `
+
+	// Insert an order from a snapshot.
+	insertSnapshotOrderStmt = `
+  INSERT INTO marketSnapshot
+    (orderID, typeID, regionID, systemID, stationID, isBuy, price,
+     volRemaining, minVolume, orderRange, issued, duration)
+  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+  `
+
+	// Get the unexpired orders for an item in a region, optionally limited to
+	// one solar system (if the third argument is nonzero).
+	// Lowercase everything for sqlx.
+	getSnapshotOrdersStmt = `
+  SELECT orderid, typeid, regionid, systemid, stationid, isbuy, price,
+         volremaining, minvolume, orderrange, issued, duration, importedat
+  FROM   marketSnapshot
+  WHERE  typeID = $1 AND regionID = $2 AND ($3 = 0 OR systemID = $3)
+  AND    issued + duration * INTERVAL '1 day' > CURRENT_TIMESTAMP
+  `
+
+	// Lock the orders for an item in a region until the end of the
+	// transaction. An advisory lock is used because the item may not have a
+	// row in marketGenerations yet.
+	lockMarketGenerationStmt = `
+  SELECT pg_advisory_xact_lock($1::integer, $2::integer)
+  `
+
+	// Get the generation time of the uploaded orders for an item in a region.
+	getMarketGenerationStmt = `
+  SELECT generatedAt
+  FROM   marketGenerations
+  WHERE  typeID = $1 AND regionID = $2
+  `
+
+	// Remove the generation time of the orders for an item in a region.
+	clearMarketGenerationStmt = `
+  DELETE FROM marketGenerations
+  WHERE typeID = $1 AND regionID = $2
+  `
+
+	// Record the generation time of the orders for an item in a region.
+	insertMarketGenerationStmt = `
+  INSERT INTO marketGenerations (typeID, regionID, generatedAt, uploader)
+  VALUES ($1, $2, $3, $4)
+  `
+)
@@ -0,0 +1,43 @@
+/*
+Copyright © 2014–6 Brad Ackerman.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package db
+
+const (
+	// Market tickers
+
+	// Get a user's tickers.
+	// Lowercase everything for sqlx.
+	getUserTickersStmt = `
+  SELECT   userid, name, stationid, items
+  FROM     tickers
+  WHERE    userid = $1
+  ORDER BY name
+  `
+
+	// Delete one of a user's tickers.
+	deleteTickerStmt = `
+  DELETE FROM tickers
+  WHERE  userid = $1 AND name = $2
+  `
+
+	// Add a ticker.
+	insertTickerStmt = `
+  INSERT INTO tickers (userid, name, stationID, items)
+  VALUES ($1, $2, $3, $4)
+  `
+)
@@ -0,0 +1,87 @@
+/*
+Copyright © 2014–6 Brad Ackerman.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package db
+
+const (
+	// Wallet
+
+	// Insert a wallet transaction unless we already have it for this
+	// character.
+	insertTransactionStmt = `
+  INSERT INTO walletTransactions
+    (transactionID, charID, apikey, transactionDate, typeID, quantity, price,
+     clientID, clientName, stationID, transactionType, transactionFor,
+     journalRefID)
+  SELECT $1::bigint, $2::integer, $3::integer, $4::timestamptz, $5::integer,
+         $6::integer, $7::double precision, $8::integer, $9::text, $10::bigint,
+         $11::text, $12::text, $13::bigint
+  WHERE NOT EXISTS (
+    SELECT 1 FROM walletTransactions
+    WHERE  charID = $2::integer AND transactionID = $1::bigint
+  )
+  `
+
+	// Insert a wallet journal entry unless we already have it.
+	insertJournalEntryStmt = `
+  INSERT INTO walletJournal
+    (charID, refID, apikey, entryDate, refTypeID, ownerID1, ownerName1,
+     ownerID2, ownerName2, argID1, argName1, amount, balance, reason)
+  SELECT $1::integer, $2::bigint, $3::integer, $4::timestamptz, $5::integer,
+         $6::integer, $7::text, $8::integer, $9::text, $10::bigint, $11::text,
+         $12::double precision, $13::double precision, $14::text
+  WHERE NOT EXISTS (
+    SELECT 1 FROM walletJournal WHERE charID = $1::integer AND refID = $2::bigint
+  )
+  `
+
+	// Get a character's personal market transactions up to the specified
+	// time, oldest first.
+	// Lowercase everything for sqlx.
+	getTransactionsStmt = `
+  WITH availableCharacters AS (
+    SELECT id
+    FROM   characters
+    WHERE  userid = $1
+  )
+  SELECT   transactionid, transactiondate, typeid, "typeName" typename,
+           quantity, price, clientid, clientname, stationid, transactiontype
+  FROM     walletTransactions w
+  JOIN     availableCharacters a ON a.id = w.charID
+  JOIN     "invTypes" t ON t."typeID" = w.typeID
+  WHERE    charID = $2 AND transactionFor = 'personal'
+  AND      transactionDate < $3
+  ORDER BY transactionDate, transactionID
+  `
+
+	// Get a character's total broker fees (46) and transaction taxes (54)
+	// between two times. Amounts are negative in the journal; we return them
+	// as positive numbers.
+	getMarketFeesStmt = `
+  WITH availableCharacters AS (
+    SELECT id
+    FROM   characters
+    WHERE  userid = $1
+  )
+  SELECT COALESCE(-SUM(CASE WHEN refTypeID = 46 THEN amount ELSE 0 END), 0) brokerfees,
+         COALESCE(-SUM(CASE WHEN refTypeID = 54 THEN amount ELSE 0 END), 0) salestax
+  FROM   walletJournal j
+  JOIN   availableCharacters a ON a.id = j.charID
+  WHERE  charID = $2
+  AND    entryDate >= $3 AND entryDate < $4
+  `
+)
@@ -0,0 +1,151 @@
+/*
+Copyright © 2014–6 Brad Ackerman.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package db
+
+const (
+	// Watchlists and alerts
+
+	// Get a user's watchlists.
+	// Lowercase everything for sqlx.
+	getWatchlistsStmt = `
+  SELECT   id, userid, name, webhook
+  FROM     watchlists
+  WHERE    userid = $1
+  ORDER BY name
+  `
+
+	// Get the items in a user's watchlists.
+	// Lowercase everything for sqlx.
+	getUserWatchedItemsStmt = `
+  SELECT   i.id, i.watchlistid, i.typeid, t."typeName" typename, i.stationid,
+           i.side, i.below, i.above, i.alerting, w.userid, w.name watchlist,
+           w.webhook
+  FROM     watchedItems i
+  JOIN     watchlists w ON w.id = i.watchlistID
+  JOIN     "invTypes" t ON t."typeID" = i.typeID
+  WHERE    w.userid = $1
+  ORDER BY t."typeName", i.stationid
+  `
+
+	// Get every watched item, for evaluation.
+	// Lowercase everything for sqlx.
+	getAllWatchedItemsStmt = `
+  SELECT   i.id, i.watchlistid, i.typeid, t."typeName" typename, i.stationid,
+           i.side, i.below, i.above, i.alerting, w.userid, w.name watchlist,
+           w.webhook
+  FROM     watchedItems i
+  JOIN     watchlists w ON w.id = i.watchlistID
+  JOIN     "invTypes" t ON t."typeID" = i.typeID
+  ORDER BY i.typeid, i.stationid
+  `
+
+	// Create a watchlist.
+	insertWatchlistStmt = `
+  INSERT INTO watchlists (userid, name, webhook)
+  VALUES ($1, $2, $3)
+  RETURNING id
+  `
+
+	// Update a watchlist, if it belongs to the specified user.
+	updateWatchlistStmt = `
+  UPDATE watchlists
+  SET    name = $3, webhook = $4
+  WHERE  id = $1 AND userid = $2
+  `
+
+	// Delete a watchlist, if it belongs to the specified user.
+	deleteWatchlistStmt = `
+  DELETE FROM watchlists
+  WHERE  id = $1 AND userid = $2
+  `
+
+	// Remove the items from a watchlist other than those listed in $2.
+	clearWatchedItemsStmt = `
+  DELETE FROM watchedItems
+  WHERE  watchlistID = $1
+  AND    NOT (id = ANY($2::integer[]))
+  `
+
+	// Add an item to a watchlist.
+	insertWatchedItemStmt = `
+  INSERT INTO watchedItems
+    (watchlistID, typeID, stationID, side, below, above)
+  VALUES ($1, $2, $3, $4, $5, $6)
+  RETURNING id
+  `
+
+	// Update an item in a watchlist. Changing it resets its alert state.
+	updateWatchedItemStmt = `
+  UPDATE watchedItems
+  SET    typeID = $3, stationID = $4, side = $5, below = $6, above = $7,
+         alerting = false
+  WHERE  id = $1 AND watchlistID = $2
+  `
+
+	// Set whether a watched item's price is past one of its thresholds.
+	setWatchAlertingStmt = `
+  UPDATE watchedItems
+  SET    alerting = $2
+  WHERE  id = $1
+  `
+
+	// Record an alert.
+	insertAlertStmt = `
+  INSERT INTO alerts (watchedItemID, price, threshold, direction)
+  VALUES ($1, $2, $3, $4)
+  RETURNING id, triggered
+  `
+
+	// Mark alerts as having been delivered.
+	markAlertsDeliveredStmt = `
+  UPDATE alerts
+  SET    delivered = true
+  WHERE  id = ANY($1::bigint[])
+  `
+
+	// Get the alerts that haven't been delivered to lists with a webhook,
+	// triggered since $1, oldest first.
+	// Lowercase everything for sqlx.
+	getUndeliveredAlertsStmt = `
+  SELECT   a.id, a.watcheditemid, w.name watchlist, i.typeid,
+           t."typeName" typename, i.stationid, i.side, a.triggered, a.price,
+           a.threshold, a.direction, a.delivered, w.id watchlistid, w.userid,
+           w.webhook
+  FROM     alerts a
+  JOIN     watchedItems i ON i.id = a.watchedItemID
+  JOIN     watchlists w ON w.id = i.watchlistID
+  JOIN     "invTypes" t ON t."typeID" = i.typeID
+  WHERE    NOT a.delivered AND w.webhook <> '' AND a.triggered >= $1
+  ORDER BY a.triggered
+  `
+
+	// Get a user's most recent alerts.
+	// Lowercase everything for sqlx.
+	getUserAlertsStmt = `
+  SELECT   a.id, a.watcheditemid, w.name watchlist, i.typeid,
+           t."typeName" typename, i.stationid, i.side, a.triggered, a.price,
+           a.threshold, a.direction, a.delivered
+  FROM     alerts a
+  JOIN     watchedItems i ON i.id = a.watchedItemID
+  JOIN     watchlists w ON w.id = i.watchlistID
+  JOIN     "invTypes" t ON t."typeID" = i.typeID
+  WHERE    w.userid = $1
+  ORDER BY a.triggered DESC
+  LIMIT    $2
+  `
+)
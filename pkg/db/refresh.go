@@ -0,0 +1,101 @@
+/*
+Copyright © 2014–6 Brad Ackerman.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package db
+
+import (
+	"fmt"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/backerman/evego"
+)
+
+// RefreshError is returned by RefreshAPIKey when one of the steps of a sync
+// fails.
+type RefreshError struct {
+	// Step is a short description of the data being imported when the error
+	// occurred (e.g. "skills").
+	Step string
+
+	// Err is the underlying error.
+	Err error
+}
+
+func (e *RefreshError) Error() string {
+	return fmt.Sprintf("unable to refresh %v: %v", e.Step, e.Err)
+}
+
+// The steps of a sync, as recorded with each key.
+const (
+	stepCharacters   = "characters"
+	stepSkills       = "skills"
+	stepSkillQueue   = "skill queue"
+	stepStandings    = "standings"
+	stepAssets       = "assets"
+	stepIndustryJobs = "industry jobs"
+	stepWallet       = "wallet"
+	stepMarketOrders = "market orders"
+	stepContracts    = "contracts"
+	stepPlanets      = "planets"
+)
+
+// coreSteps are the steps without which a key is of no use to us. The others
+// need access bits that a key may reasonably lack, so their failure only
+// affects the data they import.
+var coreSteps = map[string]bool{
+	stepCharacters: true,
+	stepSkills:     true,
+	stepAssets:     true,
+}
+
+func (d *dbInterface) RefreshAPIKey(key XMLAPIKey) ([]evego.Character, error) {
+	toons, err := d.GetAPICharacters(key.User, key)
+	if err != nil {
+		return nil, &RefreshError{stepCharacters, err}
+	}
+	steps := []struct {
+		name string
+		sync func(XMLAPIKey, int) error
+	}{
+		{stepSkills, d.GetAPISkills},
+		{stepSkillQueue, d.GetAPISkillQueue},
+		{stepStandings, d.GetAPIStandings},
+		{stepAssets, d.GetAssetsBlueprints},
+		{stepIndustryJobs, d.GetAPIIndustryJobs},
+		{stepWallet, d.GetAPIWallet},
+		{stepMarketOrders, d.GetAPIMarketOrders},
+		{stepContracts, d.GetAPIContracts},
+		{stepPlanets, d.GetAPIPlanets},
+	}
+	for _, toon := range toons {
+		for _, step := range steps {
+			err = step.sync(key, toon.ID)
+			if err == nil {
+				continue
+			}
+			if coreSteps[step.name] {
+				return nil, &RefreshError{step.name, err}
+			}
+			// The step's status has been recorded with the key; carry on with
+			// the rest.
+			log.Printf("Unable to refresh %v for character %v on API key %v: %v",
+				step.name, toon.ID, key.ID, err)
+		}
+	}
+	return toons, nil
+}
@@ -0,0 +1,137 @@
+/*
+Copyright © 2014–6 Brad Ackerman.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package db
+
+import (
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/backerman/evego"
+	"github.com/jmoiron/sqlx"
+)
+
+// nullTime converts a zero time (as returned by the XML API for missing
+// timestamps) to NULL.
+func nullTime(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t
+}
+
+func (d *dbInterface) GetAPISkillQueue(key XMLAPIKey, charID int) error {
+	k := &evego.XMLKey{
+		KeyID:            key.ID,
+		VerificationCode: key.VerificationCode,
+	}
+	attrs, err := d.charAPI.CharacterAttributes(k, charID)
+	d.recordKeyResult(key.ID, charID, stepSkillQueue, err)
+	if err != nil {
+		return err
+	}
+	queue, err := d.charAPI.SkillQueue(k, charID)
+	d.recordKeyResult(key.ID, charID, stepSkillQueue, err)
+	if err != nil {
+		return err
+	}
+	tx, err := d.db.Beginx()
+	if err != nil {
+		return err
+	}
+	// Clear existing information before inserting the API's.
+	clearStmts := []*sqlx.Stmt{d.clearSkillQueueStmt, d.clearAttributesStmt, d.clearImplantsStmt}
+	for _, stmt := range clearStmts {
+		_, err = tx.Stmtx(stmt).Exec(charID)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	_, err = tx.Stmtx(d.insertAttributesStmt).Exec(charID, attrs.Intelligence, attrs.Memory,
+		attrs.Charisma, attrs.Perception, attrs.Willpower, attrs.SkillPoints,
+		attrs.FreeSkillPoints)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	insertStmt := tx.Stmtx(d.insertImplantStmt)
+	for _, implant := range attrs.Implants {
+		_, err = insertStmt.Exec(charID, implant)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	insertStmt = tx.Stmtx(d.insertSkillQueueStmt)
+	for _, q := range queue {
+		_, err = insertStmt.Exec(charID, q.Position, q.TypeID, q.Level, q.StartSP, q.EndSP,
+			nullTime(q.StartTime), nullTime(q.EndTime))
+		if err != nil {
+			log.Printf("Failed to insert skill queue entry %+v", q)
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (d *dbInterface) CharacterSkillQueue(userID, charID int) ([]QueuedSkill, error) {
+	rows, err := d.getSkillQueueStmt.Queryx(userID, charID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	queue := make([]QueuedSkill, 0, 10)
+	for rows.Next() {
+		q := QueuedSkill{}
+		err = rows.StructScan(&q)
+		if err != nil {
+			return nil, err
+		}
+		queue = append(queue, q)
+	}
+	return queue, nil
+}
+
+func (d *dbInterface) CharacterAttributes(userID, charID int) (*CharacterAttributes, error) {
+	attrs := &CharacterAttributes{}
+	err := d.getAttributesStmt.QueryRowx(userID, charID).StructScan(attrs)
+	if err != nil {
+		return nil, err
+	}
+	return attrs, nil
+}
+
+func (d *dbInterface) SkillRequirements(userID, charID, typeID int) ([]SkillRequirement, error) {
+	rows, err := d.getSkillRequirementsStmt.Queryx(userID, charID, typeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	reqs := make([]SkillRequirement, 0, 10)
+	for rows.Next() {
+		req := SkillRequirement{}
+		err = rows.StructScan(&req)
+		if err != nil {
+			return nil, err
+		}
+		reqs = append(reqs, req)
+	}
+	return reqs, nil
+}
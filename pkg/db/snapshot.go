@@ -0,0 +1,199 @@
+/*
+Copyright © 2014–6 Brad Ackerman.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/jmoiron/sqlx"
+)
+
+// itemRegion identifies the orders for an item in a region, which are locked
+// as a unit while they're replaced.
+type itemRegion struct {
+	typeID, regionID int
+}
+
+// byItemRegion sorts item/region pairs into the order in which their locks are
+// taken, so that concurrent imports covering the same pairs can't deadlock.
+type byItemRegion []itemRegion
+
+func (b byItemRegion) Len() int      { return len(b) }
+func (b byItemRegion) Swap(i, j int) { b[i], b[j] = b[j], b[i] }
+func (b byItemRegion) Less(i, j int) bool {
+	if b[i].typeID != b[j].typeID {
+		return b[i].typeID < b[j].typeID
+	}
+	return b[i].regionID < b[j].regionID
+}
+
+// setMarketGeneration locks the orders for an item in a region and records
+// the time at which the orders replacing them were generated.
+func (d *dbInterface) setMarketGeneration(tx *sqlx.Tx, typeID, regionID int,
+	generatedAt time.Time, uploader string) error {
+	_, err := tx.Stmtx(d.lockMarketGenerationStmt).Exec(typeID, regionID)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Stmtx(d.clearMarketGenerationStmt).Exec(typeID, regionID)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Stmtx(d.insertMarketGenerationStmt).Exec(typeID, regionID, generatedAt, uploader)
+	return err
+}
+
+func (d *dbInterface) ImportMarketSnapshot(orders []SnapshotOrder, generatedAt time.Time,
+	uploader string) error {
+	// A snapshot replaces everything we know about each item in each region
+	// it covers.
+	seen := make(map[itemRegion]bool)
+	var covered []itemRegion
+	for _, o := range orders {
+		ir := itemRegion{o.TypeID, o.RegionID}
+		if !seen[ir] {
+			seen[ir] = true
+			covered = append(covered, ir)
+		}
+	}
+	sort.Sort(byItemRegion(covered))
+
+	tx, err := d.db.Beginx()
+	if err != nil {
+		return err
+	}
+	clearStmt := tx.Stmtx(d.clearSnapshotOrdersStmt)
+	for _, ir := range covered {
+		err = d.setMarketGeneration(tx, ir.typeID, ir.regionID, generatedAt, uploader)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		_, err = clearStmt.Exec(ir.typeID, ir.regionID)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	insertStmt := tx.Stmtx(d.insertSnapshotOrderStmt)
+	for _, o := range orders {
+		_, err = insertStmt.Exec(o.OrderID, o.TypeID, o.RegionID, o.SystemID,
+			o.StationID, o.IsBuy, o.Price, o.VolRemaining, o.MinVolume, o.Range,
+			o.Issued, o.Duration)
+		if err != nil {
+			log.Printf("Failed to insert snapshot order %+v", o)
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// replaceOrderSet replaces the orders for an item in a region within tx,
+// unless the orders we have were generated at the same time or later, and
+// returns whether they were replaced.
+func (d *dbInterface) replaceOrderSet(tx *sqlx.Tx, set *MarketOrderSet, uploader string) (bool, error) {
+	// Uploads for the same item and region are serialized by the lock, so
+	// the generation we read can't change before we replace it.
+	_, err := tx.Stmtx(d.lockMarketGenerationStmt).Exec(set.TypeID, set.RegionID)
+	if err != nil {
+		return false, err
+	}
+	var current time.Time
+	err = tx.Stmtx(d.getMarketGenerationStmt).QueryRowx(set.TypeID, set.RegionID).Scan(&current)
+	switch {
+	case err == sql.ErrNoRows:
+	case err != nil:
+		return false, err
+	case !set.GeneratedAt.After(current):
+		// We already have these orders or newer ones.
+		return false, nil
+	}
+	err = d.setMarketGeneration(tx, set.TypeID, set.RegionID, set.GeneratedAt, uploader)
+	if err != nil {
+		return false, err
+	}
+	_, err = tx.Stmtx(d.clearSnapshotOrdersStmt).Exec(set.TypeID, set.RegionID)
+	if err != nil {
+		return false, err
+	}
+	insertStmt := tx.Stmtx(d.insertSnapshotOrderStmt)
+	for _, o := range set.Orders {
+		_, err = insertStmt.Exec(o.OrderID, o.TypeID, o.RegionID, o.SystemID,
+			o.StationID, o.IsBuy, o.Price, o.VolRemaining, o.MinVolume, o.Range,
+			o.Issued, o.Duration)
+		if err != nil {
+			log.Printf("Failed to insert uploaded order %+v", o)
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func (d *dbInterface) ReplaceMarketOrders(sets []MarketOrderSet, uploader string) ([]bool, error) {
+	// Visit the sets in lock order, remembering where each one came from.
+	index := make(map[itemRegion]int, len(sets))
+	keys := make([]itemRegion, 0, len(sets))
+	for i, set := range sets {
+		ir := itemRegion{set.TypeID, set.RegionID}
+		if _, found := index[ir]; found {
+			return nil, fmt.Errorf("Orders for type %v in region %v supplied twice",
+				set.TypeID, set.RegionID)
+		}
+		index[ir] = i
+		keys = append(keys, ir)
+	}
+	sort.Sort(byItemRegion(keys))
+
+	tx, err := d.db.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	replaced := make([]bool, len(sets))
+	for _, ir := range keys {
+		i := index[ir]
+		replaced[i], err = d.replaceOrderSet(tx, &sets[i], uploader)
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+	return replaced, tx.Commit()
+}
+
+func (d *dbInterface) SnapshotOrders(typeID, regionID, systemID int) ([]SnapshotOrder, error) {
+	rows, err := d.getSnapshotOrdersStmt.Queryx(typeID, regionID, systemID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	orders := make([]SnapshotOrder, 0, 50)
+	for rows.Next() {
+		o := SnapshotOrder{}
+		err = rows.StructScan(&o)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, o)
+	}
+	return orders, nil
+}
@@ -0,0 +1,47 @@
+/*
+Copyright © 2014–6 Brad Ackerman.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package db
+
+func (d *dbInterface) UserTickers(userID int) ([]UserTicker, error) {
+	tickers := []UserTicker{}
+	err := d.getUserTickersStmt.Select(&tickers, userID)
+	return tickers, err
+}
+
+func (d *dbInterface) SaveTicker(t *UserTicker) error {
+	tx, err := d.db.Beginx()
+	if err != nil {
+		return err
+	}
+	_, err = tx.Stmtx(d.deleteTickerStmt).Exec(t.UserID, t.Name)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	_, err = tx.Stmtx(d.insertTickerStmt).Exec(t.UserID, t.Name, t.StationID, string(t.Items))
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (d *dbInterface) DeleteTicker(userID int, name string) error {
+	_, err := d.deleteTickerStmt.Exec(userID, name)
+	return err
+}
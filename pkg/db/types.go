@@ -18,9 +18,11 @@ limitations under the License.
 package db
 
 import (
+	"database/sql"
 	"time"
 
 	"github.com/backerman/evego"
+	"github.com/backerman/eveindy/pkg/xmlapi"
 
 	"golang.org/x/oauth2"
 )
@@ -65,4 +67,486 @@ type XMLAPIKey struct {
 
 	// Characters is a list of characters that are accessible using this API key.
 	Characters []evego.Character `json:"characters"`
+
+	// Status is the key's health as of the last time it was used.
+	Status KeyStatus `db:"status" json:"status"`
+
+	// LastError is the error returned by the EVE API the last time a sync
+	// using this key failed.
+	LastError string `db:"lasterror" json:"lastError,omitempty"`
+
+	// LastSuccess is the time at which this key was last used successfully.
+	LastSuccess *time.Time `db:"lastsuccess" json:"lastSuccess,omitempty"`
+
+	// Steps are the outcomes of the steps of the key's most recent sync.
+	Steps []KeyStep `json:"steps"`
+}
+
+// KeyStep is the outcome of one step of syncing a character using an API
+// key.
+type KeyStep struct {
+	// CharID is zero for the step that lists the key's characters.
+	CharID    int       `db:"charid" json:"charID"`
+	Step      string    `db:"step" json:"step"`
+	Status    KeyStatus `db:"status" json:"status"`
+	LastError string    `db:"lasterror" json:"lastError,omitempty"`
+	Updated   time.Time `db:"updated" json:"updated"`
+}
+
+// KeyStatus is the health of an XML API key.
+type KeyStatus string
+
+const (
+	// KeyOK indicates that the key was working the last time it was used.
+	KeyOK KeyStatus = "ok"
+
+	// KeyExpired indicates that the key has passed its expiry date.
+	KeyExpired KeyStatus = "expired"
+
+	// KeyInvalid indicates that the key has been deleted or that its
+	// verification code has been changed.
+	KeyInvalid KeyStatus = "invalid"
+
+	// KeyInsufficientAccess indicates that the key does not have the access
+	// bits required for one of the calls we make.
+	KeyInsufficientAccess KeyStatus = "insufficient"
+
+	// KeyTransientError indicates that the last call failed for a reason that
+	// may go away on its own (e.g. the API server being down).
+	KeyTransientError KeyStatus = "transient"
+)
+
+// IsUsable returns true iff it is worth attempting to use a key with this
+// status without the user's intervention.
+func (k KeyStatus) IsUsable() bool {
+	return k == KeyOK || k == KeyTransientError || k == ""
+}
+
+// QueuedSkill is an entry in a character's skill queue.
+type QueuedSkill struct {
+	// Position is this entry's position in the queue, starting at 0.
+	Position int `db:"position" json:"position"`
+
+	// TypeID and Name identify the skill being trained.
+	TypeID int    `db:"typeid" json:"typeID"`
+	Name   string `db:"typename" json:"name"`
+
+	// Level is the level to which this entry trains the skill.
+	Level int `db:"level" json:"level"`
+
+	// StartSP and EndSP are the skill's skillpoints at the start and end of
+	// this entry's training.
+	StartSP int `db:"startsp" json:"startSP"`
+	EndSP   int `db:"endsp" json:"endSP"`
+
+	// StartTime and EndTime are nil if the queue is paused.
+	StartTime *time.Time `db:"starttime" json:"startTime"`
+	EndTime   *time.Time `db:"endtime" json:"endTime"`
+}
+
+// CharacterAttributes is a character's attributes, including the bonuses
+// from their implants, and their skillpoints.
+type CharacterAttributes struct {
+	Intelligence int `db:"intelligence" json:"intelligence"`
+	Memory       int `db:"memory" json:"memory"`
+	Charisma     int `db:"charisma" json:"charisma"`
+	Perception   int `db:"perception" json:"perception"`
+	Willpower    int `db:"willpower" json:"willpower"`
+
+	// SkillPoints is the total number of skillpoints in trained skills.
+	SkillPoints int64 `db:"skillpoints" json:"skillPoints"`
+
+	// FreeSkillPoints is the number of unallocated skillpoints.
+	FreeSkillPoints int `db:"freeskillpoints" json:"freeSkillPoints"`
+}
+
+// SkillRequirement is a skill that is required to perform an industry
+// activity, along with the information needed to work out how long it will
+// take a character to train it.
+type SkillRequirement struct {
+	// Activity is the name of the industry activity (e.g. "Manufacturing").
+	Activity string `db:"activity" json:"activity"`
+
+	// TypeID and Name identify the skill.
+	TypeID int    `db:"typeid" json:"typeID"`
+	Name   string `db:"typename" json:"name"`
+
+	// RequiredLevel is the level required by the activity.
+	RequiredLevel int `db:"requiredlevel" json:"requiredLevel"`
+
+	// CurrentLevel is the level that the character has trained.
+	CurrentLevel int `db:"currentlevel" json:"currentLevel"`
+
+	// Rank is the skill's training time multiplier.
+	Rank int `db:"rank" json:"rank"`
+
+	// PrimaryAttribute and SecondaryAttribute are the dogma attribute IDs
+	// of the attributes that determine this skill's training speed.
+	PrimaryAttribute   int `db:"primaryattribute" json:"-"`
+	SecondaryAttribute int `db:"secondaryattribute" json:"-"`
+}
+
+// IndustryJob is an industry job installed by one of a user's characters or
+// their corporation.
+type IndustryJob struct {
+	JobID             int64      `db:"jobid" json:"jobID"`
+	IsCorporation     bool       `db:"iscorporation" json:"isCorporation"`
+	InstallerID       int        `db:"installerid" json:"installerID"`
+	InstallerName     string     `db:"installername" json:"installerName"`
+	FacilityID        int64      `db:"facilityid" json:"facilityID"`
+	SolarSystemID     int        `db:"solarsystemid" json:"solarSystemID"`
+	StationID         int64      `db:"stationid" json:"stationID"`
+	ActivityID        int        `db:"activityid" json:"activityID"`
+	Activity          string     `db:"activity" json:"activity"`
+	BlueprintID       int64      `db:"blueprintid" json:"blueprintID"`
+	BlueprintTypeID   int        `db:"blueprinttypeid" json:"blueprintTypeID"`
+	BlueprintTypeName string     `db:"blueprinttypename" json:"blueprintTypeName"`
+	Runs              int        `db:"runs" json:"runs"`
+	LicensedRuns      int        `db:"licensedruns" json:"licensedRuns"`
+	ProductTypeID     *int       `db:"producttypeid" json:"productTypeID,omitempty"`
+	ProductTypeName   *string    `db:"producttypename" json:"productTypeName,omitempty"`
+	Status            int        `db:"status" json:"status"`
+	StartDate         time.Time  `db:"startdate" json:"startDate"`
+	EndDate           time.Time  `db:"enddate" json:"endDate"`
+	CompletedDate     *time.Time `db:"completeddate" json:"completedDate,omitempty"`
+}
+
+// IsRunning returns true iff this job is still occupying its blueprint.
+func (j *IndustryJob) IsRunning() bool {
+	return j.Status < xmlapi.JobDelivered
+}
+
+// WalletTransaction is a market transaction made by a character.
+type WalletTransaction struct {
+	TransactionID int64     `db:"transactionid" json:"transactionID"`
+	Date          time.Time `db:"transactiondate" json:"date"`
+	TypeID        int       `db:"typeid" json:"typeID"`
+	TypeName      string    `db:"typename" json:"typeName"`
+	Quantity      int       `db:"quantity" json:"quantity"`
+	Price         float64   `db:"price" json:"price"`
+	ClientID      int       `db:"clientid" json:"clientID"`
+	ClientName    string    `db:"clientname" json:"clientName"`
+	StationID     int64     `db:"stationid" json:"stationID"`
+	// TransactionType is "buy" or "sell".
+	TransactionType string `db:"transactiontype" json:"transactionType"`
+}
+
+// MarketFees is the total of a character's market fees over a period.
+type MarketFees struct {
+	BrokerFees float64 `db:"brokerfees" json:"brokerFees"`
+	SalesTax   float64 `db:"salestax" json:"salesTax"`
+}
+
+// MarketOrder is an open market order placed by a character.
+type MarketOrder struct {
+	OrderID      int64     `db:"orderid" json:"orderID"`
+	StationID    int       `db:"stationid" json:"stationID"`
+	VolEntered   int       `db:"volentered" json:"volEntered"`
+	VolRemaining int       `db:"volremaining" json:"volRemaining"`
+	MinVolume    int       `db:"minvolume" json:"minVolume"`
+	TypeID       int       `db:"typeid" json:"typeID"`
+	TypeName     string    `db:"typename" json:"typeName"`
+	Range        int       `db:"orderrange" json:"range"`
+	Duration     int       `db:"duration" json:"duration"`
+	Escrow       float64   `db:"escrow" json:"escrow"`
+	Price        float64   `db:"price" json:"price"`
+	IsBuy        bool      `db:"isbuy" json:"isBuy"`
+	Issued       time.Time `db:"issued" json:"issued"`
+}
+
+// Agent is an NPC agent, along with a character's raw standings with it and
+// its corporation and faction.
+type Agent struct {
+	CharacterID     int    `db:"charid" json:"characterID"`
+	CharacterName   string `db:"charname" json:"characterName"`
+	AgentID         int    `db:"agentid" json:"agentID"`
+	AgentName       string `db:"agentname" json:"agentName"`
+	Level           int    `db:"level" json:"level"`
+	Division        string `db:"division" json:"division"`
+	CorporationID   int    `db:"corporationid" json:"corporationID"`
+	CorporationName string `db:"corporationname" json:"corporationName"`
+	// LocationID is the station that the agent is in.
+	LocationID    int    `db:"locationid" json:"locationID"`
+	StationName   string `db:"stationname" json:"stationName"`
+	SolarSystemID int    `db:"solarsystemid" json:"solarSystemID"`
+
+	AgentStanding sql.NullFloat64 `db:"agentstanding" json:"-"`
+	CorpStanding  sql.NullFloat64 `db:"corpstanding" json:"-"`
+	FacStanding   sql.NullFloat64 `db:"facstanding" json:"-"`
+}
+
+// Contract is an outstanding or in-progress contract issued by or to a
+// character.
+type Contract struct {
+	ContractID     int64          `db:"contractid" json:"contractID"`
+	IssuerID       int            `db:"issuerid" json:"issuerID"`
+	IssuerCorpID   int            `db:"issuercorpid" json:"issuerCorpID"`
+	AssigneeID     int            `db:"assigneeid" json:"assigneeID"`
+	AcceptorID     int            `db:"acceptorid" json:"acceptorID"`
+	StartStationID int            `db:"startstationid" json:"startStationID"`
+	EndStationID   int            `db:"endstationid" json:"endStationID"`
+	Type           string         `db:"contracttype" json:"type"`
+	Status         string         `db:"status" json:"status"`
+	Title          string         `db:"title" json:"title"`
+	ForCorp        bool           `db:"forcorp" json:"forCorp"`
+	Availability   string         `db:"availability" json:"availability"`
+	DateIssued     time.Time      `db:"dateissued" json:"dateIssued"`
+	DateExpired    time.Time      `db:"dateexpired" json:"dateExpired"`
+	DateAccepted   *time.Time     `db:"dateaccepted" json:"dateAccepted,omitempty"`
+	NumDays        int            `db:"numdays" json:"numDays"`
+	Price          float64        `db:"price" json:"price"`
+	Reward         float64        `db:"reward" json:"reward"`
+	Collateral     float64        `db:"collateral" json:"collateral"`
+	Buyout         float64        `db:"buyout" json:"buyout"`
+	Volume         float64        `db:"volume" json:"volume"`
+	Items          []ContractItem `db:"-" json:"items"`
+}
+
+// ContractItem is an item included in or requested by a contract.
+type ContractItem struct {
+	ContractID int64  `db:"contractid" json:"-"`
+	TypeID     int    `db:"typeid" json:"typeID"`
+	TypeName   string `db:"typename" json:"typeName"`
+	Quantity   int64  `db:"quantity" json:"quantity"`
+	Singleton  bool   `db:"singleton" json:"singleton"`
+	// Included is true if the issuer provides the item, and false if the
+	// issuer is asking for it.
+	Included bool `db:"included" json:"included"`
+}
+
+// Colony is a character's planetary colony.
+type Colony struct {
+	CharacterID     int       `db:"charid" json:"characterID"`
+	CharacterName   string    `db:"charname" json:"characterName"`
+	PlanetID        int       `db:"planetid" json:"planetID"`
+	PlanetName      string    `db:"planetname" json:"planetName"`
+	SolarSystemID   int       `db:"solarsystemid" json:"solarSystemID"`
+	SolarSystemName string    `db:"solarsystemname" json:"solarSystemName"`
+	PlanetTypeID    int       `db:"planettypeid" json:"planetTypeID"`
+	PlanetTypeName  string    `db:"planettypename" json:"planetTypeName"`
+	UpgradeLevel    int       `db:"upgradelevel" json:"upgradeLevel"`
+	NumberOfPins    int       `db:"numberofpins" json:"numberOfPins"`
+	LastUpdate      time.Time `db:"lastupdate" json:"lastUpdate"`
+	Pins            []Pin     `db:"-" json:"pins"`
+}
+
+// Pin is a structure in a planetary colony.
+type Pin struct {
+	CharacterID int    `db:"charid" json:"-"`
+	PlanetID    int    `db:"planetid" json:"-"`
+	PinID       int64  `db:"pinid" json:"pinID"`
+	TypeID      int    `db:"typeid" json:"typeID"`
+	TypeName    string `db:"typename" json:"typeName"`
+	// SchematicID is the schematic a factory is running; zero for anything
+	// else.
+	SchematicID int `db:"schematicid" json:"schematicID"`
+	// CycleTime is an extractor's cycle time in minutes.
+	CycleTime        int `db:"cycletime" json:"cycleTime"`
+	QuantityPerCycle int `db:"quantitypercycle" json:"quantityPerCycle"`
+	// ContentTypeID is the type the pin contains (for an extractor, the
+	// resource being extracted), or zero if it's empty.
+	ContentTypeID   int        `db:"contenttypeid" json:"contentTypeID"`
+	ContentTypeName string     `db:"contenttypename" json:"contentTypeName"`
+	ContentQuantity int        `db:"contentquantity" json:"contentQuantity"`
+	ExpiryTime      *time.Time `db:"expirytime" json:"expiryTime,omitempty"`
+}
+
+// IsExtractor returns true iff this pin is an extractor with a program
+// installed.
+func (p *Pin) IsExtractor() bool {
+	return p.SchematicID == 0 && p.CycleTime > 0 && p.QuantityPerCycle > 0
+}
+
+// SchematicFlow is one input or output of a planetary schematic.
+type SchematicFlow struct {
+	SchematicID int `db:"schematicid"`
+	// CycleTime is the schematic's cycle time in seconds.
+	CycleTime int    `db:"cycletime"`
+	TypeID    int    `db:"typeid"`
+	TypeName  string `db:"typename"`
+	Quantity  int    `db:"quantity"`
+	IsInput   bool   `db:"isinput"`
+}
+
+// SnapshotOrder is a market order imported from an order-book snapshot.
+type SnapshotOrder struct {
+	OrderID      int64   `db:"orderid" json:"orderID"`
+	TypeID       int     `db:"typeid" json:"typeID"`
+	RegionID     int     `db:"regionid" json:"regionID"`
+	SystemID     int     `db:"systemid" json:"systemID"`
+	StationID    int64   `db:"stationid" json:"stationID"`
+	IsBuy        bool    `db:"isbuy" json:"isBuy"`
+	Price        float64 `db:"price" json:"price"`
+	VolRemaining int     `db:"volremaining" json:"volRemaining"`
+	MinVolume    int     `db:"minvolume" json:"minVolume"`
+	// Range is -1 for station, 0 for system, 32767 for region, and otherwise
+	// the number of jumps, as in the XML API.
+	Range      int       `db:"orderrange" json:"range"`
+	Issued     time.Time `db:"issued" json:"issued"`
+	Duration   int       `db:"duration" json:"duration"`
+	ImportedAt time.Time `db:"importedat" json:"importedAt"`
+}
+
+// StargateJump is a stargate connection from one solar system to another.
+type StargateJump struct {
+	FromSystem int `db:"fromsystem"`
+	ToSystem   int `db:"tosystem"`
+	// ToSecurity is the security status of the destination system.
+	ToSecurity float64 `db:"tosecurity"`
+}
+
+// MarketOrderSet is the complete set of orders for an item in a region, as
+// of the time it was generated.
+type MarketOrderSet struct {
+	TypeID      int
+	RegionID    int
+	GeneratedAt time.Time
+	Orders      []SnapshotOrder
+}
+
+// PriceHistoryEntry is a day's snapshot of the market for an item at a
+// station.
+type PriceHistoryEntry struct {
+	TypeID     int       `db:"typeid" json:"typeID"`
+	StationID  int       `db:"stationid" json:"stationID"`
+	Day        time.Time `db:"day" json:"day"`
+	BestBuy    float64   `db:"bestbuy" json:"bestBuy"`
+	BestSell   float64   `db:"bestsell" json:"bestSell"`
+	BuyVolume  int64     `db:"buyvolume" json:"buyVolume"`
+	SellVolume int64     `db:"sellvolume" json:"sellVolume"`
+	BuyOrders  int       `db:"buyorders" json:"buyOrders"`
+	SellOrders int       `db:"sellorders" json:"sellOrders"`
+	SellP5     float64   `db:"sellp5" json:"sellP5"`
+	SellMedian float64   `db:"sellmedian" json:"sellMedian"`
+	BuyP95     float64   `db:"buyp95" json:"buyP95"`
+	BuyMedian  float64   `db:"buymedian" json:"buyMedian"`
+}
+
+// Appraisal is a saved valuation of a list of items.
+type Appraisal struct {
+	ID string `db:"id"`
+	// UserID is zero if the appraisal was made anonymously.
+	UserID  int       `db:"userid"`
+	Created time.Time `db:"created"`
+	// StationID is zero if the items were priced in a region.
+	StationID  int     `db:"stationid"`
+	Region     string  `db:"region"`
+	Location   string  `db:"location"`
+	Method     string  `db:"method"`
+	TopPercent float64 `db:"toppercent"`
+	// Items, Prices and Unmatched are JSON, as sent to the client.
+	Items     []byte  `db:"items"`
+	Prices    []byte  `db:"prices"`
+	Unmatched []byte  `db:"unmatched"`
+	BuyTotal  float64 `db:"buytotal"`
+	SellTotal float64 `db:"selltotal"`
+}
+
+// The sides of the market a watched item's price can be taken from.
+const (
+	WatchBuy  = "buy"
+	WatchSell = "sell"
+)
+
+// The directions in which a price can cross an alert threshold.
+const (
+	AlertBelow = "below"
+	AlertAbove = "above"
+)
+
+// Watchlist is a named list of items whose prices a user wants to be alerted
+// about.
+type Watchlist struct {
+	ID     int    `db:"id"`
+	UserID int    `db:"userid"`
+	Name   string `db:"name"`
+	// Webhook is the URL to which the list's alerts are posted, if any.
+	Webhook string        `db:"webhook"`
+	Items   []WatchedItem `db:"-"`
+}
+
+// WatchedItem is an item whose price is being watched at a station, along
+// with the thresholds beyond which an alert is triggered.
+type WatchedItem struct {
+	ID          int    `db:"id"`
+	WatchlistID int    `db:"watchlistid"`
+	TypeID      int    `db:"typeid"`
+	TypeName    string `db:"typename"`
+	StationID   int    `db:"stationid"`
+	// Side is WatchBuy or WatchSell.
+	Side  string          `db:"side"`
+	Below sql.NullFloat64 `db:"below"`
+	Above sql.NullFloat64 `db:"above"`
+	// Alerting is true while the price is past one of the thresholds.
+	Alerting bool `db:"alerting"`
+	// The watchlist the item belongs to.
+	UserID    int    `db:"userid"`
+	Watchlist string `db:"watchlist"`
+	Webhook   string `db:"webhook"`
+}
+
+// Alert is a record of a watched item's price crossing one of its
+// thresholds.
+type Alert struct {
+	ID            int64     `db:"id" json:"id"`
+	WatchedItemID int       `db:"watcheditemid" json:"watchedItemID"`
+	Watchlist     string    `db:"watchlist" json:"watchlist"`
+	TypeID        int       `db:"typeid" json:"typeID"`
+	TypeName      string    `db:"typename" json:"typeName"`
+	StationID     int       `db:"stationid" json:"stationID"`
+	Side          string    `db:"side" json:"side"`
+	Triggered     time.Time `db:"triggered" json:"triggered"`
+	Price         float64   `db:"price" json:"price"`
+	Threshold     float64   `db:"threshold" json:"threshold"`
+	// Direction is AlertBelow or AlertAbove.
+	Direction string `db:"direction" json:"direction"`
+	Delivered bool   `db:"delivered" json:"delivered"`
+}
+
+// UndeliveredAlert is an alert that is still to be delivered, with the
+// watchlist it's to be delivered for.
+type UndeliveredAlert struct {
+	Alert
+	WatchlistID int    `db:"watchlistid"`
+	UserID      int    `db:"userid"`
+	Webhook     string `db:"webhook"`
+}
+
+// UserTicker is a market ticker defined by a user: a list of items whose
+// prices are shown at a station.
+type UserTicker struct {
+	UserID    int    `db:"userid"`
+	Name      string `db:"name"`
+	StationID int    `db:"stationid"`
+	// Items is a JSON array of item names.
+	Items []byte `db:"items"`
+}
+
+// BuybackProgram is a set of rules for pricing items bought back by a
+// corporation, administered by the user who created it.
+type BuybackProgram struct {
+	ID          int     `db:"id"`
+	UserID      int     `db:"userid"`
+	Name        string  `db:"name"`
+	StationID   int     `db:"stationid"`
+	RefineYield float64 `db:"refineyield"`
+	// Rules is the JSON array of the program's rules.
+	Rules []byte `db:"rules"`
+}
+
+// BuybackQuote is a paste priced under a buyback program.
+type BuybackQuote struct {
+	ID        string `db:"id"`
+	ProgramID int    `db:"programid"`
+	Program   string `db:"program"`
+	// UserID is zero if the quote was made anonymously.
+	UserID  int       `db:"userid"`
+	Created time.Time `db:"created"`
+	Expires time.Time `db:"expires"`
+	// Location is where the items were priced.
+	Location string `db:"location"`
+	// Lines and Unmatched are JSON, as sent to the client.
+	Lines     []byte  `db:"lines"`
+	Unmatched []byte  `db:"unmatched"`
+	Total     float64 `db:"total"`
 }
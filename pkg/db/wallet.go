@@ -0,0 +1,130 @@
+/*
+Copyright © 2014–6 Brad Ackerman.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package db
+
+import (
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/backerman/evego"
+	"github.com/backerman/eveindy/pkg/xmlapi"
+)
+
+func (d *dbInterface) GetAPIWallet(key XMLAPIKey, charID int) error {
+	k := &evego.XMLKey{
+		KeyID:            key.ID,
+		VerificationCode: key.VerificationCode,
+	}
+	tx, err := d.db.Beginx()
+	if err != nil {
+		return err
+	}
+	// Walk backwards through the transactions until we reach ones that we've
+	// already stored or the API runs out.
+	insertStmt := tx.Stmtx(d.insertTransactionStmt)
+	var fromID int64
+	for {
+		txns, err := d.charAPI.WalletTransactions(k, charID, fromID)
+		d.recordKeyResult(key.ID, charID, stepWallet, err)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		caughtUp := len(txns) < xmlapi.WalletPageSize
+		for _, t := range txns {
+			res, err := insertStmt.Exec(t.TransactionID, charID, key.ID, t.Date, t.TypeID,
+				t.Quantity, t.Price, t.ClientID, t.ClientName, t.StationID, t.TransactionType,
+				t.TransactionFor, t.JournalRefID)
+			if err != nil {
+				log.Printf("Failed to insert wallet transaction %+v", t)
+				tx.Rollback()
+				return err
+			}
+			if n, _ := res.RowsAffected(); n == 0 {
+				caughtUp = true
+			}
+			if fromID == 0 || t.TransactionID < fromID {
+				fromID = t.TransactionID
+			}
+		}
+		if caughtUp {
+			break
+		}
+	}
+
+	// Same for the journal.
+	insertStmt = tx.Stmtx(d.insertJournalEntryStmt)
+	fromID = 0
+	for {
+		entries, err := d.charAPI.WalletJournal(k, charID, fromID)
+		d.recordKeyResult(key.ID, charID, stepWallet, err)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		caughtUp := len(entries) < xmlapi.WalletPageSize
+		for _, e := range entries {
+			res, err := insertStmt.Exec(charID, e.RefID, key.ID, e.Date, e.RefTypeID,
+				e.OwnerID1, e.OwnerName1, e.OwnerID2, e.OwnerName2, e.ArgID1, e.ArgName1,
+				e.Amount, e.Balance, e.Reason)
+			if err != nil {
+				log.Printf("Failed to insert wallet journal entry %+v", e)
+				tx.Rollback()
+				return err
+			}
+			if n, _ := res.RowsAffected(); n == 0 {
+				caughtUp = true
+			}
+			if fromID == 0 || e.RefID < fromID {
+				fromID = e.RefID
+			}
+		}
+		if caughtUp {
+			break
+		}
+	}
+	return tx.Commit()
+}
+
+func (d *dbInterface) CharacterTransactions(userID, charID int, until time.Time) ([]WalletTransaction, error) {
+	rows, err := d.getTransactionsStmt.Queryx(userID, charID, until)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	txns := make([]WalletTransaction, 0, 100)
+	for rows.Next() {
+		t := WalletTransaction{}
+		err = rows.StructScan(&t)
+		if err != nil {
+			return nil, err
+		}
+		txns = append(txns, t)
+	}
+	return txns, nil
+}
+
+func (d *dbInterface) CharacterMarketFees(userID, charID int, from, until time.Time) (*MarketFees, error) {
+	fees := &MarketFees{}
+	err := d.getMarketFeesStmt.QueryRowx(userID, charID, from, until).StructScan(fees)
+	if err != nil {
+		return nil, err
+	}
+	return fees, nil
+}
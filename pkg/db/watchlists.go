@@ -0,0 +1,220 @@
+/*
+Copyright © 2014–6 Brad Ackerman.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// maxUserAlerts is the number of a user's alerts listed.
+const maxUserAlerts = 100
+
+// ErrUnknownWatchedItem is returned when a watchlist being saved refers to an
+// item that isn't already in it.
+var ErrUnknownWatchedItem = errors.New("Watched item is not in this watchlist")
+
+// nullFloat converts an invalid NullFloat64 to NULL.
+func nullFloat(f sql.NullFloat64) interface{} {
+	if !f.Valid {
+		return nil
+	}
+	return f.Float64
+}
+
+func (d *dbInterface) watchedItems(stmt *sqlx.Stmt, args ...interface{}) ([]WatchedItem, error) {
+	rows, err := stmt.Queryx(args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := make([]WatchedItem, 0, 20)
+	for rows.Next() {
+		i := WatchedItem{}
+		err = rows.StructScan(&i)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	return items, nil
+}
+
+func (d *dbInterface) Watchlists(userID int) ([]Watchlist, error) {
+	lists := []Watchlist{}
+	err := d.getWatchlistsStmt.Select(&lists, userID)
+	if err != nil {
+		return nil, err
+	}
+	items, err := d.watchedItems(d.getUserWatchedItemsStmt, userID)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[int]*Watchlist)
+	for i := range lists {
+		lists[i].Items = []WatchedItem{}
+		byID[lists[i].ID] = &lists[i]
+	}
+	for _, item := range items {
+		if list, found := byID[item.WatchlistID]; found {
+			list.Items = append(list.Items, item)
+		}
+	}
+	return lists, nil
+}
+
+func (d *dbInterface) SaveWatchlist(list *Watchlist) error {
+	tx, err := d.db.Beginx()
+	if err != nil {
+		return err
+	}
+	if list.ID == 0 {
+		err = tx.Stmtx(d.insertWatchlistStmt).QueryRowx(list.UserID, list.Name,
+			list.Webhook).Scan(&list.ID)
+	} else {
+		var res sql.Result
+		res, err = tx.Stmtx(d.updateWatchlistStmt).Exec(list.ID, list.UserID,
+			list.Name, list.Webhook)
+		if err == nil {
+			var updated int64
+			updated, err = res.RowsAffected()
+			if err == nil && updated == 0 {
+				err = sql.ErrNoRows
+			}
+		}
+	}
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	var kept []int64
+	for _, item := range list.Items {
+		if item.ID != 0 {
+			kept = append(kept, int64(item.ID))
+		}
+	}
+	_, err = tx.Stmtx(d.clearWatchedItemsStmt).Exec(list.ID, int64ArrayString(kept))
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	insertStmt := tx.Stmtx(d.insertWatchedItemStmt)
+	updateStmt := tx.Stmtx(d.updateWatchedItemStmt)
+	for i := range list.Items {
+		item := &list.Items[i]
+		item.WatchlistID = list.ID
+		if item.ID == 0 {
+			err = insertStmt.QueryRowx(list.ID, item.TypeID, item.StationID,
+				item.Side, nullFloat(item.Below), nullFloat(item.Above)).Scan(&item.ID)
+		} else {
+			var res sql.Result
+			res, err = updateStmt.Exec(item.ID, list.ID, item.TypeID, item.StationID,
+				item.Side, nullFloat(item.Below), nullFloat(item.Above))
+			if err == nil {
+				var updated int64
+				updated, err = res.RowsAffected()
+				if err == nil && updated != 1 {
+					err = ErrUnknownWatchedItem
+				}
+			}
+		}
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (d *dbInterface) DeleteWatchlist(userID, watchlistID int) error {
+	_, err := d.deleteWatchlistStmt.Exec(watchlistID, userID)
+	return err
+}
+
+func (d *dbInterface) AllWatchedItems() ([]WatchedItem, error) {
+	return d.watchedItems(d.getAllWatchedItemsStmt)
+}
+
+func (d *dbInterface) SetWatchAlerting(watchedItemID int, alerting bool) error {
+	_, err := d.setWatchAlertingStmt.Exec(watchedItemID, alerting)
+	return err
+}
+
+func (d *dbInterface) RecordAlert(alert *Alert) error {
+	tx, err := d.db.Beginx()
+	if err != nil {
+		return err
+	}
+	err = tx.Stmtx(d.insertAlertStmt).QueryRowx(alert.WatchedItemID, alert.Price,
+		alert.Threshold, alert.Direction).Scan(&alert.ID, &alert.Triggered)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	_, err = tx.Stmtx(d.setWatchAlertingStmt).Exec(alert.WatchedItemID, true)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (d *dbInterface) MarkAlertsDelivered(alertIDs []int64) error {
+	_, err := d.markAlertsDeliveredStmt.Exec(int64ArrayString(alertIDs))
+	return err
+}
+
+func (d *dbInterface) UndeliveredAlerts(since time.Time) ([]UndeliveredAlert, error) {
+	rows, err := d.getUndeliveredAlertsStmt.Queryx(since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	alerts := make([]UndeliveredAlert, 0, 10)
+	for rows.Next() {
+		a := UndeliveredAlert{}
+		err = rows.StructScan(&a)
+		if err != nil {
+			return nil, err
+		}
+		alerts = append(alerts, a)
+	}
+	return alerts, nil
+}
+
+func (d *dbInterface) UserAlerts(userID int) ([]Alert, error) {
+	rows, err := d.getUserAlertsStmt.Queryx(userID, maxUserAlerts)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	alerts := make([]Alert, 0, 20)
+	for rows.Next() {
+		a := Alert{}
+		err = rows.StructScan(&a)
+		if err != nil {
+			return nil, err
+		}
+		alerts = append(alerts, a)
+	}
+	return alerts, nil
+}
@@ -38,16 +38,30 @@ func (d *dbInterface) APIKeys(userID int) ([]XMLAPIKey, error) {
 	if err != nil {
 		return nil, err
 	}
+	return d.scanAPIKeys(rows)
+}
+
+func (d *dbInterface) AllAPIKeys() ([]XMLAPIKey, error) {
+	rows, err := d.getAllAPIKeysStmt.Unsafe().Queryx()
+	if err != nil {
+		return nil, err
+	}
+	return d.scanAPIKeys(rows)
+}
+
+// scanAPIKeys reads API keys from the passed result set and populates each
+// with the characters it provides.
+func (d *dbInterface) scanAPIKeys(rows *sqlx.Rows) ([]XMLAPIKey, error) {
 	defer rows.Close()
 	results := make([]XMLAPIKey, 0, 2)
 	for rows.Next() {
 		key := XMLAPIKey{}
-		err = rows.StructScan(&key)
+		err := rows.StructScan(&key)
 		if err != nil {
 			return nil, err
 		}
 		// Get the characters on this key.
-		charRows, err := d.apiKeyListToonsStmt.Queryx(userID, key.ID)
+		charRows, err := d.apiKeyListToonsStmt.Queryx(key.User, key.ID)
 		if err != nil {
 			return nil, err
 		}
@@ -60,11 +74,22 @@ func (d *dbInterface) APIKeys(userID int) ([]XMLAPIKey, error) {
 			}
 			key.Characters = append(key.Characters, char)
 		}
+		key.Steps = []KeyStep{}
+		err = d.getAPIKeyStepsStmt.Select(&key.Steps, key.ID)
+		if err != nil {
+			return nil, err
+		}
 		results = append(results, key)
 	}
 	return results, nil
 }
 
+func (d *dbInterface) UserCharacterIDs(userID int) ([]int, error) {
+	ids := []int{}
+	err := d.getUserCharacterIDsStmt.Select(&ids, userID)
+	return ids, err
+}
+
 func (d *dbInterface) DeleteAPIKey(userID, keyID int) error {
 	_, err := d.deleteAPIKeyStmt.Exec(userID, keyID)
 	return err
@@ -82,6 +107,7 @@ func (d *dbInterface) GetAPICharacters(userid int, key XMLAPIKey) ([]evego.Chara
 	}
 	// Using the EVE XML API, get the characters on this account.
 	toons, err := d.xmlAPI.AccountCharacters(k)
+	d.recordKeyResult(key.ID, 0, stepCharacters, err)
 	if err != nil {
 		return nil, err
 	}
@@ -140,6 +166,7 @@ func (d *dbInterface) GetAPISkills(key XMLAPIKey, charID int) error {
 		VerificationCode: key.VerificationCode,
 	}
 	charsheet, err := d.xmlAPI.CharacterSheet(k, charID)
+	d.recordKeyResult(key.ID, charID, stepSkills, err)
 	if err != nil {
 		return err
 	}
@@ -168,6 +195,7 @@ func (d *dbInterface) GetAPIStandings(key XMLAPIKey, charID int) error {
 		VerificationCode: key.VerificationCode,
 	}
 	standings, err := d.xmlAPI.CharacterStandings(k, charID)
+	d.recordKeyResult(key.ID, charID, stepStandings, err)
 	if err != nil {
 		return err
 	}
@@ -176,13 +204,14 @@ func (d *dbInterface) GetAPIStandings(key XMLAPIKey, charID int) error {
 		return err
 	}
 	// Clear standings before inserting the API's information.
-	_, err = tx.Stmtx(d.apiKeyClearCorpStandingsStmt).Exec(charID)
-	if err != nil {
-		return err
-	}
-	_, err = tx.Stmtx(d.apiKeyClearFacStandingsStmt).Exec(charID)
-	if err != nil {
-		return err
+	clearStmts := []*sqlx.Stmt{d.apiKeyClearCorpStandingsStmt,
+		d.apiKeyClearFacStandingsStmt, d.apiKeyClearAgentStandingsStmt}
+	for _, stmt := range clearStmts {
+		_, err = tx.Stmtx(stmt).Exec(charID)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
 	}
 	var insertStmt *sqlx.Stmt
 	for _, standing := range standings {
@@ -191,8 +220,10 @@ func (d *dbInterface) GetAPIStandings(key XMLAPIKey, charID int) error {
 			insertStmt = d.apiKeyInsertCorpStandingsStmt
 		case evego.NPCFaction:
 			insertStmt = d.apiKeyInsertFacStandingsStmt
+		case evego.NPCAgent:
+			insertStmt = d.apiKeyInsertAgentStandingsStmt
 		default:
-			// Agent standings - we don't handle those, so skip.
+			// Not a kind of standing we use.
 			continue
 		}
 		insertStmt = tx.Stmtx(insertStmt)
@@ -210,6 +241,24 @@ func (d *dbInterface) CharacterStandings(userID, charID, corpID int) (corpStandi
 	return
 }
 
+func (d *dbInterface) Agents(userID, npcCorpID, level int, division string) ([]Agent, error) {
+	rows, err := d.getAgentsStmt.Queryx(userID, npcCorpID, level, division)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	agents := make([]Agent, 0, 50)
+	for rows.Next() {
+		agent := Agent{}
+		err = rows.StructScan(&agent)
+		if err != nil {
+			return nil, err
+		}
+		agents = append(agents, agent)
+	}
+	return agents, nil
+}
+
 func (d *dbInterface) CharacterSkill(userID, charID, skillID int) (int, error) {
 	var skillLevel int
 	err := d.getSkillStmt.QueryRow(userID, charID, skillID).Scan(&skillLevel)
@@ -288,6 +337,7 @@ func (d *dbInterface) GetAssetsBlueprints(key XMLAPIKey, charID int) error {
 	assetParent := make(map[int]int)
 
 	assets, err := d.xmlAPI.Assets(k, charID)
+	d.recordKeyResult(key.ID, charID, stepAssets, err)
 	if err != nil {
 		log.Printf("Unable to obtain assets for character %v: %v", charID, err)
 		return err
@@ -331,10 +381,9 @@ func (d *dbInterface) GetAssetsBlueprints(key XMLAPIKey, charID int) error {
 	}
 
 	blueprints, err := d.xmlAPI.Blueprints(k, charID, assets)
+	d.recordKeyResult(key.ID, charID, stepAssets, err)
 	if err != nil {
-		return err
-	}
-	if err != nil {
+		tx.Rollback()
 		return err
 	}
 	// Clear blueprints before inserting the API's information.
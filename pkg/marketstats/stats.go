@@ -0,0 +1,237 @@
+/*
+Copyright © 2014–6 Brad Ackerman.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+// Package marketstats computes summary statistics over market orders.
+package marketstats
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/backerman/evego"
+)
+
+// DefaultTopPercent is the share of volume averaged over by the top-average
+// statistics unless another is requested.
+const DefaultTopPercent = 5.0
+
+// OutlierFactor is how far (as a multiple in either direction) an order's
+// price may be from the volume-weighted median of its side of the market
+// before Filter considers it an outlier.
+const OutlierFactor = 3.0
+
+// PriceMethod is a way of deriving a single buy and sell price from a market.
+type PriceMethod string
+
+// The available pricing methods.
+const (
+	// MethodBest uses the best buy and sell orders.
+	MethodBest PriceMethod = "best"
+	// MethodPercentile uses the 95th percentile buy and 5th percentile sell
+	// prices, by volume.
+	MethodPercentile PriceMethod = "percentile"
+	// MethodTopAverage uses the volume-weighted average of the best orders
+	// making up the top N% of volume.
+	MethodTopAverage PriceMethod = "topavg"
+	// MethodMedian uses the volume-weighted median prices.
+	MethodMedian PriceMethod = "median"
+)
+
+// ParseMethod returns the pricing method with the passed name; the empty
+// string means MethodBest.
+func ParseMethod(name string) (PriceMethod, error) {
+	switch m := PriceMethod(name); m {
+	case "":
+		return MethodBest, nil
+	case MethodBest, MethodPercentile, MethodTopAverage, MethodMedian:
+		return m, nil
+	}
+	return "", fmt.Errorf("Unknown pricing method %v", name)
+}
+
+// Summary describes the state of the market for an item at one location.
+type Summary struct {
+	BestBuy    float64 `json:"bestBuy"`
+	BestSell   float64 `json:"bestSell"`
+	BuyVolume  int64   `json:"buyVolume"`
+	SellVolume int64   `json:"sellVolume"`
+	BuyOrders  int     `json:"buyOrders"`
+	SellOrders int     `json:"sellOrders"`
+	// Volume-weighted percentile prices. SellP5 is the price at which the
+	// cheapest 5% of the items for sale can be bought; BuyP95 is the price at
+	// which the top 5% of buy demand can be filled. Unlike the best prices,
+	// they aren't moved by a single tiny order.
+	SellP5     float64 `json:"sellP5"`
+	SellMedian float64 `json:"sellMedian"`
+	BuyP95     float64 `json:"buyP95"`
+	BuyMedian  float64 `json:"buyMedian"`
+	// The volume-weighted average prices of the best orders making up
+	// TopPercent of each side's volume.
+	TopPercent     float64 `json:"topPercent"`
+	BuyTopAverage  float64 `json:"buyTopAverage"`
+	SellTopAverage float64 `json:"sellTopAverage"`
+}
+
+// Prices returns the buy and sell prices according to the passed method.
+func (s *Summary) Prices(method PriceMethod) (buy, sell float64) {
+	switch method {
+	case MethodPercentile:
+		return s.BuyP95, s.SellP5
+	case MethodTopAverage:
+		return s.BuyTopAverage, s.SellTopAverage
+	case MethodMedian:
+		return s.BuyMedian, s.SellMedian
+	}
+	return s.BestBuy, s.BestSell
+}
+
+type priceVolume struct {
+	price  float64
+	volume int64
+}
+
+type byPrice []priceVolume
+
+func (p byPrice) Len() int           { return len(p) }
+func (p byPrice) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+func (p byPrice) Less(i, j int) bool { return p[i].price < p[j].price }
+
+// weightedPercentile returns the lowest price at which the cumulative volume
+// of the passed orders (sorted by ascending price) reaches pct percent of
+// their total volume.
+func weightedPercentile(orders byPrice, total int64, pct float64) float64 {
+	if len(orders) == 0 {
+		return 0
+	}
+	target := float64(total) * pct / 100.0
+	var cumulative int64
+	for _, o := range orders {
+		cumulative += o.volume
+		if float64(cumulative) >= target {
+			return o.price
+		}
+	}
+	return orders[len(orders)-1].price
+}
+
+// topAverage returns the volume-weighted average price of the first orders
+// (in the order passed) making up pct percent of the total volume.
+func topAverage(orders byPrice, total int64, pct float64, reverse bool) float64 {
+	target := float64(total) * pct / 100.0
+	if len(orders) == 0 || target <= 0 {
+		return 0
+	}
+	var volume, value float64
+	for i := range orders {
+		o := orders[i]
+		if reverse {
+			o = orders[len(orders)-1-i]
+		}
+		take := float64(o.volume)
+		if volume+take > target {
+			take = target - volume
+		}
+		volume += take
+		value += take * o.price
+		if volume >= target {
+			break
+		}
+	}
+	if volume == 0 {
+		return 0
+	}
+	return value / volume
+}
+
+// sides splits orders into buy and sell orders, each sorted by ascending
+// price.
+func sides(orders []evego.Order) (buys, sells byPrice) {
+	for _, o := range orders {
+		pv := priceVolume{o.Price, int64(o.Quantity)}
+		switch o.Type {
+		case evego.Buy:
+			buys = append(buys, pv)
+		case evego.Sell:
+			sells = append(sells, pv)
+		}
+	}
+	sort.Sort(buys)
+	sort.Sort(sells)
+	return
+}
+
+func totalVolume(orders byPrice) int64 {
+	var total int64
+	for _, o := range orders {
+		total += o.volume
+	}
+	return total
+}
+
+// Filter removes orders that can't sensibly be used to price a quantity of
+// an item: buy orders whose minimum quantity exceeds it (unless quantity is
+// zero or less) and orders whose price is more than OutlierFactor away from
+// the volume-weighted median of their side of the market. It returns the
+// remaining orders and the number removed.
+func Filter(orders []evego.Order, quantity int) ([]evego.Order, int) {
+	buys, sells := sides(orders)
+	buyMedian := weightedPercentile(buys, totalVolume(buys), 50)
+	sellMedian := weightedPercentile(sells, totalVolume(sells), 50)
+	kept := make([]evego.Order, 0, len(orders))
+	for _, o := range orders {
+		median := sellMedian
+		if o.Type == evego.Buy {
+			if quantity > 0 && o.MinQuantity > quantity {
+				continue
+			}
+			median = buyMedian
+		}
+		if median > 0 && (o.Price > median*OutlierFactor || o.Price < median/OutlierFactor) {
+			continue
+		}
+		kept = append(kept, o)
+	}
+	return kept, len(orders) - len(kept)
+}
+
+// Summarize computes a Summary of the passed orders, with top averages taken
+// over topPct percent of volume.
+func Summarize(orders []evego.Order, topPct float64) Summary {
+	s := Summary{TopPercent: topPct}
+	buys, sells := sides(orders)
+	for _, o := range buys {
+		s.BuyVolume += o.volume
+		s.BuyOrders++
+	}
+	for _, o := range sells {
+		s.SellVolume += o.volume
+		s.SellOrders++
+	}
+	if len(buys) > 0 {
+		s.BestBuy = buys[len(buys)-1].price
+	}
+	if len(sells) > 0 {
+		s.BestSell = sells[0].price
+	}
+	s.SellP5 = weightedPercentile(sells, s.SellVolume, 5)
+	s.SellMedian = weightedPercentile(sells, s.SellVolume, 50)
+	s.BuyP95 = weightedPercentile(buys, s.BuyVolume, 95)
+	s.BuyMedian = weightedPercentile(buys, s.BuyVolume, 50)
+	s.SellTopAverage = topAverage(sells, s.SellVolume, topPct, false)
+	s.BuyTopAverage = topAverage(buys, s.BuyVolume, topPct, true)
+	return s
+}
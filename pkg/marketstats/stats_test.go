@@ -0,0 +1,188 @@
+/*
+Copyright © 2014–6 Brad Ackerman.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package marketstats
+
+import (
+	"testing"
+
+	"github.com/backerman/evego"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func order(t evego.OrderType, price float64, quantity, minQuantity int) evego.Order {
+	return evego.Order{Type: t, Price: price, Quantity: quantity, MinQuantity: minQuantity}
+}
+
+// ladder is a side of a market with 10 units: 1 at 10, 3 at 20 and 6 at 30.
+var ladder = byPrice{{10, 1}, {20, 3}, {30, 6}}
+
+func TestFilter(t *testing.T) {
+	Convey("Verify that unusable orders are filtered out", t, func() {
+		Convey("An empty market has nothing to filter", func() {
+			kept, removed := Filter(nil, 0)
+			So(kept, ShouldBeEmpty)
+			So(removed, ShouldEqual, 0)
+		})
+
+		Convey("A single order is its own median and is kept", func() {
+			kept, removed := Filter([]evego.Order{order(evego.Sell, 100, 10, 1)}, 0)
+			So(kept, ShouldHaveLength, 1)
+			So(removed, ShouldEqual, 0)
+		})
+
+		Convey("Orders more than OutlierFactor from their side's median are removed", func() {
+			// The sell median is 100 and the buy median 50.
+			orders := []evego.Order{
+				order(evego.Sell, 33, 1, 1),
+				order(evego.Sell, 34, 1, 1),
+				order(evego.Sell, 100, 10, 1),
+				order(evego.Sell, 100, 10, 1),
+				order(evego.Sell, 300, 1, 1),
+				order(evego.Sell, 301, 1, 1),
+				order(evego.Buy, 16, 1, 1),
+				order(evego.Buy, 50, 10, 1),
+				order(evego.Buy, 150, 1, 1),
+				order(evego.Buy, 200, 1, 1),
+			}
+			kept, removed := Filter(orders, 0)
+			So(removed, ShouldEqual, 4)
+			prices := make([]float64, len(kept))
+			for i := range kept {
+				prices[i] = kept[i].Price
+			}
+			So(prices, ShouldResemble, []float64{34, 100, 100, 300, 50, 150})
+		})
+
+		Convey("Buy orders with a minimum above the quantity are removed", func() {
+			orders := []evego.Order{
+				order(evego.Buy, 50, 10, 1),
+				order(evego.Buy, 50, 10, 100),
+			}
+			kept, removed := Filter(orders, 10)
+			So(removed, ShouldEqual, 1)
+			So(kept[0].MinQuantity, ShouldEqual, 1)
+
+			_, removed = Filter(orders, 100)
+			So(removed, ShouldEqual, 0)
+
+			Convey("unless no quantity is given", func() {
+				_, removed := Filter(orders, 0)
+				So(removed, ShouldEqual, 0)
+			})
+		})
+	})
+}
+
+func TestWeightedPercentile(t *testing.T) {
+	Convey("Verify volume-weighted percentiles", t, func() {
+		Convey("An empty side has no price", func() {
+			So(weightedPercentile(nil, 0, 50), ShouldEqual, 0.0)
+		})
+
+		Convey("A single order sets every percentile", func() {
+			single := byPrice{{100, 10}}
+			So(weightedPercentile(single, 10, 5), ShouldEqual, 100.0)
+			So(weightedPercentile(single, 10, 95), ShouldEqual, 100.0)
+		})
+
+		Convey("The price is that at which the cumulative volume is reached", func() {
+			So(weightedPercentile(ladder, 10, 5), ShouldEqual, 10.0)
+			So(weightedPercentile(ladder, 10, 10), ShouldEqual, 10.0)
+			So(weightedPercentile(ladder, 10, 40), ShouldEqual, 20.0)
+			So(weightedPercentile(ladder, 10, 50), ShouldEqual, 30.0)
+			So(weightedPercentile(ladder, 10, 100), ShouldEqual, 30.0)
+		})
+	})
+}
+
+func TestTopAverage(t *testing.T) {
+	Convey("Verify averages over the top of the market", t, func() {
+		Convey("An empty side or share has no price", func() {
+			So(topAverage(nil, 0, 5, false), ShouldEqual, 0.0)
+			So(topAverage(ladder, 10, 0, false), ShouldEqual, 0.0)
+		})
+
+		Convey("A single order is its own average", func() {
+			So(topAverage(byPrice{{100, 10}}, 10, 5, false), ShouldEqual, 100.0)
+		})
+
+		Convey("Only the volume up to the cut-off is averaged", func() {
+			So(topAverage(ladder, 10, 10, false), ShouldEqual, 10.0)
+			So(topAverage(ladder, 10, 30, false), ShouldAlmostEqual, 50.0/3.0)
+			So(topAverage(ladder, 10, 100, false), ShouldEqual, 25.0)
+		})
+
+		Convey("Reversed, the most expensive orders are averaged", func() {
+			So(topAverage(ladder, 10, 30, true), ShouldEqual, 30.0)
+			So(topAverage(ladder, 10, 80, true), ShouldEqual, 27.5)
+		})
+
+		Convey("A share over 100% averages the whole side", func() {
+			So(topAverage(ladder, 10, 150, false), ShouldEqual, 25.0)
+		})
+	})
+}
+
+func TestSummarize(t *testing.T) {
+	Convey("Verify market summaries", t, func() {
+		Convey("An empty market has only its top percentage", func() {
+			So(Summarize(nil, DefaultTopPercent), ShouldResemble, Summary{TopPercent: DefaultTopPercent})
+		})
+
+		Convey("A single sell order sets every sell price", func() {
+			s := Summarize([]evego.Order{order(evego.Sell, 100, 10, 1)}, DefaultTopPercent)
+			So(s, ShouldResemble, Summary{
+				BestSell:       100,
+				SellVolume:     10,
+				SellOrders:     1,
+				SellP5:         100,
+				SellMedian:     100,
+				TopPercent:     DefaultTopPercent,
+				SellTopAverage: 100,
+			})
+		})
+
+		Convey("Both sides of a market are summarized", func() {
+			orders := []evego.Order{
+				order(evego.Sell, 30, 6, 1),
+				order(evego.Sell, 10, 1, 1),
+				order(evego.Sell, 20, 3, 1),
+				order(evego.Buy, 5, 5, 1),
+				order(evego.Buy, 8, 5, 1),
+			}
+			s := Summarize(orders, 30)
+			So(s.BestBuy, ShouldEqual, 8.0)
+			So(s.BestSell, ShouldEqual, 10.0)
+			So(s.BuyVolume, ShouldEqual, int64(10))
+			So(s.SellVolume, ShouldEqual, int64(10))
+			So(s.BuyOrders, ShouldEqual, 2)
+			So(s.SellOrders, ShouldEqual, 3)
+			So(s.SellP5, ShouldEqual, 10.0)
+			So(s.SellMedian, ShouldEqual, 30.0)
+			So(s.BuyP95, ShouldEqual, 8.0)
+			So(s.BuyMedian, ShouldEqual, 5.0)
+			So(s.SellTopAverage, ShouldAlmostEqual, 50.0/3.0)
+			So(s.BuyTopAverage, ShouldEqual, 8.0)
+
+			all := Summarize(orders, 100)
+			So(all.SellTopAverage, ShouldEqual, 25.0)
+			So(all.BuyTopAverage, ShouldEqual, 6.5)
+		})
+	})
+}
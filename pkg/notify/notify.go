@@ -0,0 +1,36 @@
+/*
+Copyright © 2014–6 Brad Ackerman.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+// Package notify delivers price alerts to the users whose watchlists
+// triggered them.
+package notify
+
+import (
+	"errors"
+
+	"github.com/backerman/eveindy/pkg/db"
+)
+
+// ErrNoTarget is returned by a Notifier when a watchlist doesn't say where its
+// alerts should be delivered.
+var ErrNoTarget = errors.New("No notification target for watchlist")
+
+// Notifier is a way of delivering alerts.
+type Notifier interface {
+	// Notify delivers alerts triggered by items in the passed watchlist.
+	Notify(list db.Watchlist, alerts []db.Alert) error
+}
@@ -0,0 +1,165 @@
+/*
+Copyright © 2014–6 Brad Ackerman.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/backerman/eveindy/pkg/db"
+)
+
+// ErrPrivateTarget is returned when a webhook's host is on a loopback,
+// private or otherwise non-public network.
+var ErrPrivateTarget = errors.New("Webhook target is not a public address")
+
+// nonPublicNets are the networks that webhooks may not be delivered to.
+var nonPublicNets []*net.IPNet
+
+func init() {
+	for _, cidr := range []string{
+		"0.0.0.0/8",      // "this" network
+		"10.0.0.0/8",     // private
+		"100.64.0.0/10",  // carrier-grade NAT
+		"127.0.0.0/8",    // loopback
+		"169.254.0.0/16", // link-local
+		"172.16.0.0/12",  // private
+		"192.168.0.0/16", // private
+		"198.18.0.0/15",  // benchmarking
+		"224.0.0.0/4",    // multicast
+		"240.0.0.0/4",    // reserved and broadcast
+		"::/128",         // unspecified
+		"::1/128",        // loopback
+		"64:ff9b::/96",   // NAT64, which can reach private IPv4 addresses
+		"fc00::/7",       // unique local
+		"fe80::/10",      // link-local
+		"ff00::/8",       // multicast
+	} {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		nonPublicNets = append(nonPublicNets, n)
+	}
+}
+
+// isPublic returns true if ip is an address that webhooks may be delivered to.
+func isPublic(ip net.IP) bool {
+	for _, n := range nonPublicNets {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// resolvePublic looks up host and returns its addresses, or ErrPrivateTarget
+// if any of them isn't public.
+func resolvePublic(host string) ([]net.IP, error) {
+	ips, err := net.LookupIP(host)
+	if err != nil {
+		return nil, err
+	}
+	for _, ip := range ips {
+		if !isPublic(ip) {
+			return nil, ErrPrivateTarget
+		}
+	}
+	return ips, nil
+}
+
+// ValidateWebhook checks that a webhook URL is one we're willing to post to:
+// http or https, to a host that resolves only to public addresses.
+func ValidateWebhook(rawURL string) error {
+	hook, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	if hook.Scheme != "http" && hook.Scheme != "https" {
+		return fmt.Errorf("Unsupported webhook scheme %q", hook.Scheme)
+	}
+	if hook.Host == "" {
+		return errors.New("Webhook URL has no host")
+	}
+	_, err = resolvePublic(hook.Hostname())
+	return err
+}
+
+type webhook struct {
+	client *http.Client
+}
+
+// Webhook returns a Notifier that posts alerts as JSON to the watchlist's
+// webhook URL. Connections are only made to public addresses; the check is
+// made when dialing so that it also covers redirects and DNS changes since
+// the watchlist was saved.
+func Webhook(timeout time.Duration) Notifier {
+	dialer := &net.Dialer{Timeout: timeout}
+	dial := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		ips, err := resolvePublic(host)
+		if err != nil {
+			return nil, err
+		}
+		return dialer.DialContext(ctx, network, net.JoinHostPort(ips[0].String(), port))
+	}
+	return &webhook{
+		client: &http.Client{
+			Timeout:   timeout,
+			Transport: &http.Transport{DialContext: dial},
+		},
+	}
+}
+
+// webhookPayload is the body of a webhook request.
+type webhookPayload struct {
+	Watchlist string     `json:"watchlist"`
+	Alerts    []db.Alert `json:"alerts"`
+}
+
+func (h *webhook) Notify(list db.Watchlist, alerts []db.Alert) error {
+	if list.Webhook == "" {
+		return ErrNoTarget
+	}
+	body, err := json.Marshal(&webhookPayload{
+		Watchlist: list.Name,
+		Alerts:    alerts,
+	})
+	if err != nil {
+		return err
+	}
+	resp, err := h.client.Post(list.Webhook, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("Webhook %v returned status %v", list.Webhook, resp.Status)
+	}
+	return nil
+}
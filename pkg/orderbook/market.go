@@ -0,0 +1,169 @@
+/*
+Copyright © 2014–6 Brad Ackerman.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+// Package orderbook provides a market backed by order-book snapshots stored in
+// the local database.
+package orderbook
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/backerman/evego"
+	"github.com/backerman/eveindy/pkg/db"
+	"github.com/backerman/eveindy/pkg/xmlapi"
+)
+
+type localMarket struct {
+	localdb db.LocalDB
+	sde     evego.Database
+	router  evego.Router
+	xmlAPI  evego.XMLAPI
+}
+
+// LocalMarket returns an evego.Market that answers queries from the order-book
+// snapshots imported into the local database. The router is used to
+// determine which buy orders reach a given station.
+func LocalMarket(localdb db.LocalDB, sde evego.Database, router evego.Router, xmlAPI evego.XMLAPI) evego.Market {
+	return &localMarket{
+		localdb: localdb,
+		sde:     sde,
+		router:  router,
+		xmlAPI:  xmlAPI,
+	}
+}
+
+func (m *localMarket) Close() error {
+	return nil
+}
+
+// stationCache looks up the stations referenced by a set of orders, only
+// hitting the database once for each.
+type stationCache struct {
+	m        *localMarket
+	stations map[int64]*evego.Station
+}
+
+func (s *stationCache) station(o *db.SnapshotOrder) *evego.Station {
+	stn, found := s.stations[o.StationID]
+	if found {
+		return stn
+	}
+	stn, err := s.m.sde.StationForID(int(o.StationID))
+	if err != nil {
+		// Not a station; try outposts.
+		stn, err = s.m.xmlAPI.OutpostForID(int(o.StationID))
+	}
+	if err != nil {
+		// Neither a station nor an outpost (probably a player-owned
+		// structure), so make do with what the snapshot told us.
+		stn = &evego.Station{
+			Name:     fmt.Sprintf("Structure %d", o.StationID),
+			ID:       int(o.StationID),
+			SystemID: o.SystemID,
+			RegionID: o.RegionID,
+		}
+	}
+	s.stations[o.StationID] = stn
+	return stn
+}
+
+func (m *localMarket) toOrders(item *evego.Item, snapshot []db.SnapshotOrder, include func(*evego.Order) bool) *[]evego.Order {
+	cache := &stationCache{m: m, stations: make(map[int64]*evego.Station)}
+	orders := make([]evego.Order, 0, len(snapshot))
+	for i := range snapshot {
+		o := &snapshot[i]
+		order := evego.Order{
+			Type:        evego.Sell,
+			Item:        item,
+			Quantity:    o.VolRemaining,
+			Station:     cache.station(o),
+			Price:       o.Price,
+			MinQuantity: o.MinVolume,
+			Expiration:  o.Issued.Add(time.Duration(o.Duration) * 24 * time.Hour),
+		}
+		if o.IsBuy {
+			order.Type = evego.Buy
+			switch o.Range {
+			case xmlapi.RangeStation:
+				order.JumpRange = evego.BuyStation
+			case xmlapi.RangeSystem:
+				order.JumpRange = evego.BuySystem
+			case xmlapi.RangeRegion:
+				order.JumpRange = evego.BuyRegion
+			default:
+				order.JumpRange = evego.BuyNumberJumps
+				order.NumJumps = o.Range
+			}
+		}
+		if !include(&order) {
+			continue
+		}
+		orders = append(orders, order)
+	}
+	return &orders
+}
+
+func (m *localMarket) OrdersForItem(item *evego.Item, location string, orderType evego.OrderType) (*[]evego.Order, error) {
+	var regionID, systemID int
+	region, err := m.sde.RegionForName(location)
+	if err == nil {
+		regionID = region.ID
+	} else {
+		system, err := m.sde.SolarSystemForName(location)
+		if err != nil {
+			return nil, fmt.Errorf("Unknown region or system %v", location)
+		}
+		regionID, systemID = system.RegionID, system.ID
+	}
+	snapshot, err := m.localdb.SnapshotOrders(item.ID, regionID, systemID)
+	if err != nil {
+		return nil, err
+	}
+	return m.toOrders(item, snapshot, func(o *evego.Order) bool {
+		switch orderType {
+		case evego.Buy, evego.Sell:
+			return o.Type == orderType
+		}
+		return true
+	}), nil
+}
+
+func (m *localMarket) BuyInStation(item *evego.Item, location *evego.Station) (*[]evego.Order, error) {
+	snapshot, err := m.localdb.SnapshotOrders(item.ID, location.RegionID, 0)
+	if err != nil {
+		return nil, err
+	}
+	reach := NewReach(location, m.router)
+	return m.toOrders(item, snapshot, func(o *evego.Order) bool {
+		return o.Type == evego.Buy && reach.BuyOrderReaches(o)
+	}), nil
+}
+
+func (m *localMarket) OrdersInStation(item *evego.Item, location *evego.Station) (*[]evego.Order, error) {
+	snapshot, err := m.localdb.SnapshotOrders(item.ID, location.RegionID, 0)
+	if err != nil {
+		return nil, err
+	}
+	reach := NewReach(location, m.router)
+	return m.toOrders(item, snapshot, func(o *evego.Order) bool {
+		if o.Type == evego.Buy {
+			return reach.BuyOrderReaches(o)
+		}
+		return o.Station.ID == location.ID
+	}), nil
+}
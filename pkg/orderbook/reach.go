@@ -0,0 +1,89 @@
+/*
+Copyright © 2014–6 Brad Ackerman.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package orderbook
+
+import (
+	"sync"
+
+	"github.com/backerman/evego"
+)
+
+// Reach works out which buy orders can be filled at a station. The distance
+// to the station from each system that orders are placed in is looked up
+// once and cached, and a Reach may be shared between goroutines.
+type Reach struct {
+	station *evego.Station
+	router  evego.Router
+
+	jumpsLock sync.Mutex
+	jumps     map[int]int
+}
+
+// NewReach returns a Reach for the passed station. If router is nil, orders
+// with a range in jumps only reach the station from within its own system.
+func NewReach(station *evego.Station, router evego.Router) *Reach {
+	return &Reach{
+		station: station,
+		router:  router,
+		jumps:   make(map[int]int),
+	}
+}
+
+// jumpsFrom returns the number of jumps from the passed system to the
+// station, or -1 if there's no known route. The lock isn't held while the
+// router is asked, so concurrent callers may look up the same route twice.
+func (r *Reach) jumpsFrom(systemID int) int {
+	r.jumpsLock.Lock()
+	jumps, found := r.jumps[systemID]
+	r.jumpsLock.Unlock()
+	if found {
+		return jumps
+	}
+	jumps = -1
+	if r.router != nil {
+		n, err := r.router.NumJumpsID(systemID, r.station.SystemID, evego.PreferShortest)
+		if err == nil {
+			jumps = n
+		}
+	}
+	r.jumpsLock.Lock()
+	r.jumps[systemID] = jumps
+	r.jumpsLock.Unlock()
+	return jumps
+}
+
+// BuyOrderReaches returns true iff a buy order can be filled at the station.
+func (r *Reach) BuyOrderReaches(order *evego.Order) bool {
+	station := r.station
+	if order.Station.RegionID != station.RegionID {
+		return false
+	}
+	switch order.JumpRange {
+	case evego.BuyStation:
+		return order.Station.ID == station.ID
+	case evego.BuySystem:
+		return order.Station.SystemID == station.SystemID
+	case evego.BuyRegion:
+		return true
+	}
+	if order.Station.SystemID == station.SystemID {
+		return true
+	}
+	jumps := r.jumpsFrom(order.Station.SystemID)
+	return jumps >= 0 && jumps <= order.NumJumps
+}
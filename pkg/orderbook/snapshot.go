@@ -0,0 +1,258 @@
+/*
+Copyright © 2014–6 Brad Ackerman.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package orderbook
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/backerman/evego"
+	"github.com/backerman/eveindy/pkg/db"
+	"github.com/backerman/eveindy/pkg/xmlapi"
+)
+
+// Snapshot file formats.
+const (
+	FormatCSV  = "csv"
+	FormatJSON = "json"
+)
+
+// snapshotOrder is an order as it appears in a snapshot file. The fields
+// follow ESI's market order format; region_id and system_id may be omitted
+// and will be looked up from the order's location.
+type snapshotOrder struct {
+	OrderID      int64     `json:"order_id"`
+	TypeID       int       `json:"type_id"`
+	RegionID     int       `json:"region_id"`
+	SystemID     int       `json:"system_id"`
+	LocationID   int64     `json:"location_id"`
+	IsBuyOrder   bool      `json:"is_buy_order"`
+	Price        float64   `json:"price"`
+	VolumeRemain int       `json:"volume_remain"`
+	MinVolume    int       `json:"min_volume"`
+	Range        string    `json:"range"`
+	Issued       time.Time `json:"issued"`
+	Duration     int       `json:"duration"`
+}
+
+// FormatForFile guesses a snapshot file's format from its name.
+func FormatForFile(filename string) (string, error) {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".csv":
+		return FormatCSV, nil
+	case ".json":
+		return FormatJSON, nil
+	}
+	return "", fmt.Errorf("Unable to determine format of %v", filename)
+}
+
+// parseRange converts a range as found in a snapshot ("station",
+// "solarsystem", "region", or a number of jumps) to the XML API's
+// representation.
+func parseRange(r string) (int, error) {
+	switch strings.ToLower(r) {
+	case "station", "":
+		return xmlapi.RangeStation, nil
+	case "solarsystem", "system":
+		return xmlapi.RangeSystem, nil
+	case "region":
+		return xmlapi.RangeRegion, nil
+	}
+	jumps, err := strconv.Atoi(r)
+	if err != nil || jumps < 0 {
+		return 0, fmt.Errorf("Invalid order range %v", r)
+	}
+	return jumps, nil
+}
+
+// csvColumns maps the header of a CSV snapshot to the setter for each column.
+var csvColumns = map[string]func(o *snapshotOrder, val string) error{
+	"order_id": func(o *snapshotOrder, val string) (err error) {
+		o.OrderID, err = strconv.ParseInt(val, 10, 64)
+		return
+	},
+	"type_id": func(o *snapshotOrder, val string) (err error) {
+		o.TypeID, err = strconv.Atoi(val)
+		return
+	},
+	"region_id": func(o *snapshotOrder, val string) (err error) {
+		o.RegionID, err = strconv.Atoi(val)
+		return
+	},
+	"system_id": func(o *snapshotOrder, val string) (err error) {
+		o.SystemID, err = strconv.Atoi(val)
+		return
+	},
+	"location_id": func(o *snapshotOrder, val string) (err error) {
+		o.LocationID, err = strconv.ParseInt(val, 10, 64)
+		return
+	},
+	"is_buy_order": func(o *snapshotOrder, val string) (err error) {
+		o.IsBuyOrder, err = strconv.ParseBool(val)
+		return
+	},
+	"price": func(o *snapshotOrder, val string) (err error) {
+		o.Price, err = strconv.ParseFloat(val, 64)
+		return
+	},
+	"volume_remain": func(o *snapshotOrder, val string) (err error) {
+		o.VolumeRemain, err = strconv.Atoi(val)
+		return
+	},
+	"min_volume": func(o *snapshotOrder, val string) (err error) {
+		o.MinVolume, err = strconv.Atoi(val)
+		return
+	},
+	"range": func(o *snapshotOrder, val string) error {
+		o.Range = val
+		return nil
+	},
+	"issued": func(o *snapshotOrder, val string) (err error) {
+		o.Issued, err = time.Parse(time.RFC3339, val)
+		return
+	},
+	"duration": func(o *snapshotOrder, val string) (err error) {
+		o.Duration, err = strconv.Atoi(val)
+		return
+	},
+}
+
+func readCSV(r io.Reader) ([]snapshotOrder, error) {
+	reader := csv.NewReader(r)
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	setters := make([]func(*snapshotOrder, string) error, len(header))
+	for i, col := range header {
+		col = strings.ToLower(strings.TrimSpace(col))
+		if col == "station_id" {
+			col = "location_id"
+		}
+		// Unknown columns are ignored.
+		setters[i] = csvColumns[col]
+	}
+	var orders []snapshotOrder
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		o := snapshotOrder{}
+		for i, val := range record {
+			if setters[i] == nil {
+				continue
+			}
+			err = setters[i](&o, strings.TrimSpace(val))
+			if err != nil {
+				return nil, fmt.Errorf("Line %d, column %v: %v", line, header[i], err)
+			}
+		}
+		orders = append(orders, o)
+	}
+	return orders, nil
+}
+
+// ReadSnapshot parses an order-book snapshot in the given format and returns
+// its orders, ready for import. The SDE is used to fill in any missing
+// system and region IDs. An order that appears more than once, as happens
+// when it moves between ESI pages while they're fetched, is imported once,
+// as last seen.
+func ReadSnapshot(r io.Reader, format string, sde evego.Database) ([]db.SnapshotOrder, error) {
+	var (
+		raw []snapshotOrder
+		err error
+	)
+	switch format {
+	case FormatCSV:
+		raw, err = readCSV(r)
+	case FormatJSON:
+		err = json.NewDecoder(r).Decode(&raw)
+	default:
+		err = fmt.Errorf("Unknown snapshot format %v", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	stations := make(map[int64]*evego.Station)
+	systems := make(map[int]*evego.SolarSystem)
+	orders := make([]db.SnapshotOrder, 0, len(raw))
+	seen := make(map[int64]int)
+	for _, o := range raw {
+		if o.OrderID == 0 || o.TypeID == 0 || o.LocationID == 0 {
+			return nil, fmt.Errorf("Order %v is missing its ID, type, or location", o.OrderID)
+		}
+		orderRange, err := parseRange(o.Range)
+		if err != nil {
+			return nil, fmt.Errorf("Order %v: %v", o.OrderID, err)
+		}
+		if o.SystemID == 0 {
+			stn, found := stations[o.LocationID]
+			if !found {
+				stn, err = sde.StationForID(int(o.LocationID))
+				if err != nil {
+					return nil, fmt.Errorf("Order %v: unable to find station %v", o.OrderID, o.LocationID)
+				}
+				stations[o.LocationID] = stn
+			}
+			o.SystemID, o.RegionID = stn.SystemID, stn.RegionID
+		}
+		if o.RegionID == 0 {
+			system, found := systems[o.SystemID]
+			if !found {
+				system, err = sde.SolarSystemForID(o.SystemID)
+				if err != nil {
+					return nil, fmt.Errorf("Order %v: unable to find system %v", o.OrderID, o.SystemID)
+				}
+				systems[o.SystemID] = system
+			}
+			o.RegionID = system.RegionID
+		}
+		order := db.SnapshotOrder{
+			OrderID:      o.OrderID,
+			TypeID:       o.TypeID,
+			RegionID:     o.RegionID,
+			SystemID:     o.SystemID,
+			StationID:    o.LocationID,
+			IsBuy:        o.IsBuyOrder,
+			Price:        o.Price,
+			VolRemaining: o.VolumeRemain,
+			MinVolume:    o.MinVolume,
+			Range:        orderRange,
+			Issued:       o.Issued,
+			Duration:     o.Duration,
+		}
+		if i, found := seen[o.OrderID]; found {
+			orders[i] = order
+			continue
+		}
+		seen[o.OrderID] = len(orders)
+		orders = append(orders, order)
+	}
+	return orders, nil
+}
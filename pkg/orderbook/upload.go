@@ -0,0 +1,285 @@
+/*
+Copyright © 2014–6 Brad Ackerman.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package orderbook
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/backerman/evego"
+	"github.com/backerman/eveindy/pkg/db"
+)
+
+// maxClockSkew is how far in the future an upload's generation time may be
+// before we reject it.
+const maxClockSkew = 5 * time.Minute
+
+// UploadKey identifies the uploader of a set of orders to a service.
+type UploadKey struct {
+	Name string `json:"name"`
+	Key  string `json:"key"`
+}
+
+// upload is an order upload in the unified uploader format: each rowset
+// holds all of the orders for one type in one region, as rows of values in
+// the order given by columns.
+type upload struct {
+	ResultType  string      `json:"resultType"`
+	Version     string      `json:"version"`
+	UploadKeys  []UploadKey `json:"uploadKeys"`
+	CurrentTime time.Time   `json:"currentTime"`
+	Columns     []string    `json:"columns"`
+	Rowsets     []struct {
+		GeneratedAt time.Time       `json:"generatedAt"`
+		RegionID    int             `json:"regionID"`
+		TypeID      int             `json:"typeID"`
+		Rows        [][]interface{} `json:"rows"`
+	} `json:"rowsets"`
+}
+
+// Upload is a decoded order upload. Its rowsets aren't checked until Rowsets
+// is called, so that the uploader can be authenticated from its keys first.
+type Upload struct {
+	// Keys are the upload keys included in the upload itself.
+	Keys []UploadKey
+	raw  upload
+}
+
+// uploadColumns maps the columns of an upload to the setter for each.
+var uploadColumns = map[string]func(o *db.SnapshotOrder, val interface{}) error{
+	"price": func(o *db.SnapshotOrder, val interface{}) (err error) {
+		o.Price, err = uploadFloat(val)
+		return
+	},
+	"volRemaining": func(o *db.SnapshotOrder, val interface{}) (err error) {
+		o.VolRemaining, err = uploadInt(val)
+		return
+	},
+	"range": func(o *db.SnapshotOrder, val interface{}) (err error) {
+		o.Range, err = uploadInt(val)
+		return
+	},
+	"orderID": func(o *db.SnapshotOrder, val interface{}) error {
+		id, err := uploadFloat(val)
+		o.OrderID = int64(id)
+		return err
+	},
+	"minVolume": func(o *db.SnapshotOrder, val interface{}) (err error) {
+		o.MinVolume, err = uploadInt(val)
+		return
+	},
+	"bid": func(o *db.SnapshotOrder, val interface{}) error {
+		bid, ok := val.(bool)
+		if !ok {
+			return fmt.Errorf("%v is not true or false", val)
+		}
+		o.IsBuy = bid
+		return nil
+	},
+	"issueDate": func(o *db.SnapshotOrder, val interface{}) error {
+		s, ok := val.(string)
+		if !ok {
+			return fmt.Errorf("%v is not a date", val)
+		}
+		var err error
+		o.Issued, err = time.Parse(time.RFC3339, s)
+		return err
+	},
+	"duration": func(o *db.SnapshotOrder, val interface{}) (err error) {
+		o.Duration, err = uploadInt(val)
+		return
+	},
+	"stationID": func(o *db.SnapshotOrder, val interface{}) error {
+		id, err := uploadFloat(val)
+		o.StationID = int64(id)
+		return err
+	},
+	"solarSystemID": func(o *db.SnapshotOrder, val interface{}) (err error) {
+		o.SystemID, err = uploadInt(val)
+		return
+	},
+}
+
+// requiredColumns are the columns an upload must include; solarSystemID is
+// looked up from the station if it's missing.
+var requiredColumns = []string{"price", "volRemaining", "range", "orderID",
+	"minVolume", "bid", "issueDate", "duration", "stationID"}
+
+func uploadFloat(val interface{}) (float64, error) {
+	f, ok := val.(float64)
+	if !ok {
+		return 0, fmt.Errorf("%v is not a number", val)
+	}
+	return f, nil
+}
+
+func uploadInt(val interface{}) (int, error) {
+	f, err := uploadFloat(val)
+	if err != nil {
+		return 0, err
+	}
+	if f != float64(int(f)) {
+		return 0, fmt.Errorf("%v is not a whole number", val)
+	}
+	return int(f), nil
+}
+
+// DecodeUpload decodes an order upload in the unified uploader format.
+func DecodeUpload(r io.Reader) (*Upload, error) {
+	u := &Upload{}
+	err := json.NewDecoder(r).Decode(&u.raw)
+	if err != nil {
+		return nil, err
+	}
+	if u.raw.ResultType != "orders" {
+		return nil, fmt.Errorf("Unsupported result type %q", u.raw.ResultType)
+	}
+	u.Keys = u.raw.UploadKeys
+	return u, nil
+}
+
+// Rowsets validates an upload's rowsets and returns their orders. Each
+// rowset is stamped with the time it was generated, which is the upload's
+// current time if the rowset doesn't have its own, or now if neither is
+// given. An order may only appear once in an upload, and a type and region
+// only once.
+func (u *Upload) Rowsets(sde evego.Database) ([]db.MarketOrderSet, error) {
+	var err error
+	raw := &u.raw
+	setters := make([]func(*db.SnapshotOrder, interface{}) error, len(raw.Columns))
+	present := make(map[string]bool)
+	for i, col := range raw.Columns {
+		// Unknown columns (volEntered, for example) are ignored.
+		setters[i] = uploadColumns[col]
+		present[col] = true
+	}
+	for _, col := range requiredColumns {
+		if !present[col] {
+			return nil, fmt.Errorf("Upload is missing the %v column", col)
+		}
+	}
+
+	type itemRegion struct {
+		typeID, regionID int
+	}
+	now := time.Now()
+	stations := make(map[int64]*evego.Station)
+	systems := make(map[int]*evego.SolarSystem)
+	items := make(map[int]bool)
+	seenSets := make(map[itemRegion]bool)
+	seenOrders := make(map[int64]bool)
+	result := make([]db.MarketOrderSet, 0, len(raw.Rowsets))
+	for n, rs := range raw.Rowsets {
+		if rs.TypeID == 0 || rs.RegionID == 0 {
+			return nil, fmt.Errorf("Rowset %d is missing its type or region", n)
+		}
+		ir := itemRegion{rs.TypeID, rs.RegionID}
+		if seenSets[ir] {
+			return nil, fmt.Errorf("Rowset %d: type %v in region %v appears more than once",
+				n, rs.TypeID, rs.RegionID)
+		}
+		seenSets[ir] = true
+		if !items[rs.TypeID] {
+			_, err = sde.ItemForID(rs.TypeID)
+			if err != nil {
+				return nil, fmt.Errorf("Rowset %d: unknown type %v", n, rs.TypeID)
+			}
+			items[rs.TypeID] = true
+		}
+		rowset := db.MarketOrderSet{
+			TypeID:      rs.TypeID,
+			RegionID:    rs.RegionID,
+			GeneratedAt: rs.GeneratedAt,
+			Orders:      make([]db.SnapshotOrder, 0, len(rs.Rows)),
+		}
+		if rowset.GeneratedAt.IsZero() {
+			rowset.GeneratedAt = raw.CurrentTime
+		}
+		if rowset.GeneratedAt.IsZero() {
+			rowset.GeneratedAt = now
+		}
+		if rowset.GeneratedAt.After(now.Add(maxClockSkew)) {
+			return nil, fmt.Errorf("Rowset %d was generated in the future", n)
+		}
+		for _, row := range rs.Rows {
+			if len(row) != len(raw.Columns) {
+				return nil, fmt.Errorf("Rowset %d: row has %d values for %d columns",
+					n, len(row), len(raw.Columns))
+			}
+			o := db.SnapshotOrder{TypeID: rs.TypeID, RegionID: rs.RegionID}
+			for i, val := range row {
+				if setters[i] == nil {
+					continue
+				}
+				err = setters[i](&o, val)
+				if err != nil {
+					return nil, fmt.Errorf("Rowset %d, column %v: %v", n, raw.Columns[i], err)
+				}
+			}
+			if o.OrderID <= 0 || o.StationID <= 0 {
+				return nil, fmt.Errorf("Rowset %d: order %v is missing its ID or station", n, o.OrderID)
+			}
+			if seenOrders[o.OrderID] {
+				return nil, fmt.Errorf("Rowset %d: order %v appears more than once", n, o.OrderID)
+			}
+			seenOrders[o.OrderID] = true
+			if o.Price <= 0 || o.VolRemaining < 0 || o.MinVolume < 0 || o.Duration < 0 {
+				return nil, fmt.Errorf("Rowset %d: order %v has an invalid price, volume or duration",
+					n, o.OrderID)
+			}
+			// Check that the order is where the rowset says it is.
+			regionID := 0
+			if stn, found := stations[o.StationID]; found {
+				regionID = stn.RegionID
+				if o.SystemID == 0 {
+					o.SystemID = stn.SystemID
+				}
+			} else if stn, err := sde.StationForID(int(o.StationID)); err == nil {
+				stations[o.StationID] = stn
+				regionID = stn.RegionID
+				if o.SystemID == 0 {
+					o.SystemID = stn.SystemID
+				}
+			} else if o.SystemID == 0 {
+				return nil, fmt.Errorf("Rowset %d: order %v: unable to find station %v",
+					n, o.OrderID, o.StationID)
+			}
+			if regionID == 0 {
+				system, found := systems[o.SystemID]
+				if !found {
+					system, err = sde.SolarSystemForID(o.SystemID)
+					if err != nil {
+						return nil, fmt.Errorf("Rowset %d: order %v: unable to find system %v",
+							n, o.OrderID, o.SystemID)
+					}
+					systems[o.SystemID] = system
+				}
+				regionID = system.RegionID
+			}
+			if regionID != rs.RegionID {
+				return nil, fmt.Errorf("Rowset %d: order %v is not in region %v",
+					n, o.OrderID, rs.RegionID)
+			}
+			rowset.Orders = append(rowset.Orders, o)
+		}
+		result = append(result, rowset)
+	}
+	return result, nil
+}
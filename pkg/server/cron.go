@@ -22,17 +22,36 @@ import (
 
 	log "github.com/Sirupsen/logrus"
 
+	"github.com/backerman/evego"
 	"github.com/backerman/eveindy/pkg/db"
+	"github.com/backerman/eveindy/pkg/marketstats"
+	"github.com/backerman/eveindy/pkg/notify"
 	"github.com/robfig/cron"
 )
 
-// StartJobs starts the background jobs to update universe information.
-func StartJobs(localdb db.LocalDB) {
+// PriceHistoryConfig lists the items whose prices are recorded each day, and
+// the stations at which they are recorded.
+type PriceHistoryConfig struct {
+	Items    []string
+	Stations []int
+}
+
+// alertRetryPeriod is how long delivery of an alert is retried before it's
+// given up on.
+const alertRetryPeriod = 24 * time.Hour
+
+// StartJobs starts the background jobs to update universe information. Price
+// alerts triggered by users' watchlists are delivered through the notifier.
+func StartJobs(localdb db.LocalDB, sde evego.Database, xmlAPI evego.XMLAPI, mkt evego.Market,
+	history PriceHistoryConfig, notifier notify.Notifier) {
 	jobs := []struct {
 		cronSpec string
 		job      func()
 	}{
 		{"@every 1h", func() { updateOutposts(localdb) }},
+		{"@every 6h", func() { refreshAPIKeys(localdb) }},
+		{"@daily", func() { recordPriceHistory(localdb, sde, xmlAPI, mkt, history) }},
+		{"@every 15m", func() { checkWatchlists(localdb, sde, xmlAPI, mkt, notifier) }},
 	}
 	c := cron.New()
 	for _, j := range jobs {
@@ -61,3 +80,254 @@ func updateOutposts(localdb db.LocalDB) {
 		log.Printf("Finished outpost update in %.0f ms", duration.Seconds()*1000.0)
 	}
 }
+
+// refreshAPIKeys re-imports character information for every registered API
+// key. Keys that CCP has told us are dead are skipped until their owner
+// refreshes them by hand.
+func refreshAPIKeys(localdb db.LocalDB) {
+	log.Printf("Starting API key refresh")
+	start := time.Now()
+	keys, err := localdb.AllAPIKeys()
+	if err != nil {
+		log.Printf("Error retrieving API keys: %v", err)
+		return
+	}
+	var refreshed, skipped, failed int
+	for _, key := range keys {
+		if !key.Status.IsUsable() {
+			skipped++
+			continue
+		}
+		_, err := localdb.RefreshAPIKey(key)
+		if err != nil {
+			failed++
+			log.Printf("Error refreshing API key %v: %v", key.ID, err)
+		} else {
+			refreshed++
+		}
+	}
+	duration := time.Now().Sub(start)
+	log.Printf("Finished API key refresh in %.0f ms: %d refreshed, %d failed, %d skipped",
+		duration.Seconds()*1000.0, refreshed, failed, skipped)
+}
+
+// recordPriceHistory snapshots the market for the configured items at the
+// configured stations.
+func recordPriceHistory(localdb db.LocalDB, sde evego.Database, xmlAPI evego.XMLAPI, mkt evego.Market,
+	history PriceHistoryConfig) {
+	log.Printf("Starting price history snapshot")
+	start := time.Now()
+	items := make([]*evego.Item, 0, len(history.Items))
+	for _, name := range history.Items {
+		item, err := sde.ItemForName(name)
+		if err != nil {
+			log.Printf("Unable to find item %v for price history: %v", name, err)
+			continue
+		}
+		items = append(items, item)
+	}
+	var recorded, failed int
+	for _, stationID := range history.Stations {
+		station, err := FindStation(sde, xmlAPI, stationID)
+		if err != nil {
+			log.Printf("Unable to find station %v for price history: %v", stationID, err)
+			continue
+		}
+		for _, item := range items {
+			orders, err := mkt.OrdersInStation(item, station)
+			if err != nil {
+				failed++
+				log.Printf("Error getting orders for %v in %v: %v", item.Name, station.Name, err)
+				continue
+			}
+			filtered, _ := marketstats.Filter(*orders, 0)
+			stats := marketstats.Summarize(filtered, marketstats.DefaultTopPercent)
+			err = localdb.RecordPriceHistory(db.PriceHistoryEntry{
+				TypeID:     item.ID,
+				StationID:  station.ID,
+				Day:        start,
+				BestBuy:    stats.BestBuy,
+				BestSell:   stats.BestSell,
+				BuyVolume:  stats.BuyVolume,
+				SellVolume: stats.SellVolume,
+				BuyOrders:  stats.BuyOrders,
+				SellOrders: stats.SellOrders,
+				SellP5:     stats.SellP5,
+				SellMedian: stats.SellMedian,
+				BuyP95:     stats.BuyP95,
+				BuyMedian:  stats.BuyMedian,
+			})
+			if err != nil {
+				failed++
+				log.Printf("Error recording price history for %v in %v: %v", item.Name, station.Name, err)
+				continue
+			}
+			recorded++
+		}
+	}
+	duration := time.Now().Sub(start)
+	log.Printf("Finished price history snapshot in %.0f ms: %d recorded, %d failed",
+		duration.Seconds()*1000.0, recorded, failed)
+}
+
+// crossedThreshold returns the direction in which a watched item's price is
+// past one of its thresholds and the threshold crossed, or an empty direction
+// if it isn't.
+func crossedThreshold(item *db.WatchedItem, price float64) (direction string, threshold float64) {
+	switch {
+	case item.Below.Valid && price < item.Below.Float64:
+		return db.AlertBelow, item.Below.Float64
+	case item.Above.Valid && price > item.Above.Float64:
+		return db.AlertAbove, item.Above.Float64
+	}
+	return "", 0
+}
+
+// watchedMarket identifies the market for an item at a station.
+type watchedMarket struct {
+	typeID, stationID int
+}
+
+// marketSummary returns the state of the market for an item at a station, or
+// nil if it can't be determined.
+func marketSummary(sde evego.Database, xmlAPI evego.XMLAPI, mkt evego.Market, m watchedMarket) *marketstats.Summary {
+	item, err := sde.ItemForID(m.typeID)
+	if err != nil {
+		log.Printf("Unable to find watched item %v: %v", m.typeID, err)
+		return nil
+	}
+	station, err := FindStation(sde, xmlAPI, m.stationID)
+	if err != nil {
+		log.Printf("Unable to find station %v for watched item: %v", m.stationID, err)
+		return nil
+	}
+	orders, err := mkt.OrdersInStation(item, station)
+	if err != nil {
+		log.Printf("Error getting orders for %v in %v: %v", item.Name, station.Name, err)
+		return nil
+	}
+	filtered, _ := marketstats.Filter(*orders, 0)
+	stats := marketstats.Summarize(filtered, marketstats.DefaultTopPercent)
+	return &stats
+}
+
+// checkWatchlists compares the current price of each watched item with its
+// thresholds, records an alert for each that has crossed one since the last
+// check, and delivers them along with any earlier alerts whose delivery
+// failed.
+func checkWatchlists(localdb db.LocalDB, sde evego.Database, xmlAPI evego.XMLAPI, mkt evego.Market,
+	notifier notify.Notifier) {
+	log.Printf("Starting watchlist check")
+	start := time.Now()
+	items, err := localdb.AllWatchedItems()
+	if err != nil {
+		log.Printf("Error retrieving watched items: %v", err)
+		return
+	}
+	markets := make(map[watchedMarket]*marketstats.Summary)
+	lists := make(map[int]db.Watchlist)
+	pending := make(map[int][]db.Alert)
+	var triggered, retried, failed int
+
+	// Alerts that couldn't be delivered last time are tried again.
+	undelivered, err := localdb.UndeliveredAlerts(start.Add(-alertRetryPeriod))
+	if err != nil {
+		log.Printf("Error retrieving undelivered alerts: %v", err)
+	}
+	for _, a := range undelivered {
+		lists[a.WatchlistID] = db.Watchlist{
+			ID:      a.WatchlistID,
+			UserID:  a.UserID,
+			Name:    a.Watchlist,
+			Webhook: a.Webhook,
+		}
+		pending[a.WatchlistID] = append(pending[a.WatchlistID], a.Alert)
+		retried++
+	}
+
+	for i := range items {
+		item := &items[i]
+		m := watchedMarket{item.TypeID, item.StationID}
+		stats, found := markets[m]
+		if !found {
+			stats = marketSummary(sde, xmlAPI, mkt, m)
+			markets[m] = stats
+		}
+		if stats == nil {
+			failed++
+			continue
+		}
+		price := stats.BestSell
+		if item.Side == db.WatchBuy {
+			price = stats.BestBuy
+		}
+		if price == 0 {
+			// No orders on this side of the market.
+			continue
+		}
+		direction, threshold := crossedThreshold(item, price)
+		if direction == "" {
+			if item.Alerting {
+				err = localdb.SetWatchAlerting(item.ID, false)
+				if err != nil {
+					log.Printf("Error resetting alert state of watched item %v: %v", item.ID, err)
+				}
+			}
+			continue
+		}
+		if item.Alerting {
+			// Already alerted on this crossing.
+			continue
+		}
+		alert := db.Alert{
+			WatchedItemID: item.ID,
+			Watchlist:     item.Watchlist,
+			TypeID:        item.TypeID,
+			TypeName:      item.TypeName,
+			StationID:     item.StationID,
+			Side:          item.Side,
+			Price:         price,
+			Threshold:     threshold,
+			Direction:     direction,
+		}
+		err = localdb.RecordAlert(&alert)
+		if err != nil {
+			failed++
+			log.Printf("Error recording alert for watched item %v: %v", item.ID, err)
+			continue
+		}
+		triggered++
+		lists[item.WatchlistID] = db.Watchlist{
+			ID:      item.WatchlistID,
+			UserID:  item.UserID,
+			Name:    item.Watchlist,
+			Webhook: item.Webhook,
+		}
+		pending[item.WatchlistID] = append(pending[item.WatchlistID], alert)
+	}
+
+	var delivered int
+	for listID, alerts := range pending {
+		err = notifier.Notify(lists[listID], alerts)
+		if err == notify.ErrNoTarget {
+			continue
+		}
+		if err != nil {
+			log.Printf("Error delivering alerts for watchlist %v: %v", listID, err)
+			continue
+		}
+		alertIDs := make([]int64, len(alerts))
+		for i := range alerts {
+			alertIDs[i] = alerts[i].ID
+		}
+		err = localdb.MarkAlertsDelivered(alertIDs)
+		if err != nil {
+			log.Printf("Error marking alerts for watchlist %v delivered: %v", listID, err)
+			continue
+		}
+		delivered += len(alerts)
+	}
+	duration := time.Now().Sub(start)
+	log.Printf("Finished watchlist check in %.0f ms: %d triggered, %d retried, %d delivered, %d failed",
+		duration.Seconds()*1000.0, triggered, retried, delivered, failed)
+}
@@ -32,3 +32,14 @@ func GetRandomness(numBytes int) (string, error) {
 	}
 	return base64.StdEncoding.EncodeToString(b), err
 }
+
+// RandomID returns a random identifier of numBytes bytes, encoded so that it
+// is safe to use in a URL.
+func RandomID(numBytes int) (string, error) {
+	b := make([]byte, numBytes)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
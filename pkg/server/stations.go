@@ -0,0 +1,30 @@
+/*
+Copyright © 2014–6 Brad Ackerman.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package server
+
+import "github.com/backerman/evego"
+
+// FindStation returns the station or outpost with the passed ID.
+func FindStation(sde evego.Database, xmlAPI evego.XMLAPI, stationID int) (*evego.Station, error) {
+	station, err := sde.StationForID(stationID)
+	if err != nil {
+		// Not a station; should be an outpost.
+		station, err = xmlAPI.OutpostForID(stationID)
+	}
+	return station, err
+}
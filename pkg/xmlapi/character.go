@@ -0,0 +1,137 @@
+/*
+Copyright © 2014–6 Brad Ackerman.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package xmlapi
+
+import (
+	"time"
+
+	"github.com/backerman/evego"
+)
+
+// QueuedSkill is an entry in a character's skill queue.
+type QueuedSkill struct {
+	// Position is this entry's position in the queue, starting at 0.
+	Position int `xml:"queuePosition,attr"`
+
+	// TypeID is the skill's type ID.
+	TypeID int `xml:"typeID,attr"`
+
+	// Level is the level to which this entry trains the skill.
+	Level int `xml:"level,attr"`
+
+	// StartSP and EndSP are the skill's skillpoints at the start and end of
+	// this entry's training.
+	StartSP int `xml:"startSP,attr"`
+	EndSP   int `xml:"endSP,attr"`
+
+	// StartTime and EndTime are the times at which training of this entry
+	// starts and ends. They are zero if the queue is paused.
+	StartTime time.Time `xml:"-"`
+	EndTime   time.Time `xml:"-"`
+}
+
+// Attributes is a character's attributes (not including implants), the
+// implants that they have plugged in, and their total skillpoints.
+type Attributes struct {
+	Intelligence int
+	Memory       int
+	Charisma     int
+	Perception   int
+	Willpower    int
+
+	// SkillPoints is the total number of skillpoints in the character's
+	// trained skills.
+	SkillPoints int64
+
+	// FreeSkillPoints is the number of unallocated skillpoints.
+	FreeSkillPoints int
+
+	// Implants is the type IDs of the character's active implants.
+	Implants []int
+}
+
+type skillQueueResult struct {
+	Rows []struct {
+		QueuedSkill
+		StartTime apiTime `xml:"startTime,attr"`
+		EndTime   apiTime `xml:"endTime,attr"`
+	} `xml:"result>rowset>row"`
+}
+
+func (x *xmlAPI) SkillQueue(key *evego.XMLKey, characterID int) ([]QueuedSkill, error) {
+	var result skillQueueResult
+	err := x.get("/char/SkillQueue.xml.aspx", keyParams(key, characterID), &result)
+	if err != nil {
+		return nil, err
+	}
+	queue := make([]QueuedSkill, 0, len(result.Rows))
+	for _, row := range result.Rows {
+		skill := row.QueuedSkill
+		skill.StartTime = row.StartTime.Time
+		skill.EndTime = row.EndTime.Time
+		queue = append(queue, skill)
+	}
+	return queue, nil
+}
+
+type characterSheetResult struct {
+	Intelligence    int `xml:"result>attributes>intelligence"`
+	Memory          int `xml:"result>attributes>memory"`
+	Charisma        int `xml:"result>attributes>charisma"`
+	Perception      int `xml:"result>attributes>perception"`
+	Willpower       int `xml:"result>attributes>willpower"`
+	FreeSkillPoints int `xml:"result>freeSkillPoints"`
+	// The character sheet has several rowsets; we only care about two.
+	Rowsets []struct {
+		Name string `xml:"name,attr"`
+		Rows []struct {
+			TypeID      int   `xml:"typeID,attr"`
+			SkillPoints int64 `xml:"skillpoints,attr"`
+		} `xml:"row"`
+	} `xml:"result>rowset"`
+}
+
+func (x *xmlAPI) CharacterAttributes(key *evego.XMLKey, characterID int) (*Attributes, error) {
+	var sheet characterSheetResult
+	err := x.get("/char/CharacterSheet.xml.aspx", keyParams(key, characterID), &sheet)
+	if err != nil {
+		return nil, err
+	}
+	attrs := &Attributes{
+		Intelligence:    sheet.Intelligence,
+		Memory:          sheet.Memory,
+		Charisma:        sheet.Charisma,
+		Perception:      sheet.Perception,
+		Willpower:       sheet.Willpower,
+		FreeSkillPoints: sheet.FreeSkillPoints,
+		Implants:        make([]int, 0, 10),
+	}
+	for _, rowset := range sheet.Rowsets {
+		switch rowset.Name {
+		case "skills":
+			for _, row := range rowset.Rows {
+				attrs.SkillPoints += row.SkillPoints
+			}
+		case "implants":
+			for _, row := range rowset.Rows {
+				attrs.Implants = append(attrs.Implants, row.TypeID)
+			}
+		}
+	}
+	return attrs, nil
+}
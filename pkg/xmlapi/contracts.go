@@ -0,0 +1,125 @@
+/*
+Copyright © 2014–6 Brad Ackerman.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package xmlapi
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/backerman/evego"
+)
+
+// Contract types, as returned by the XML API.
+const (
+	ContractItemExchange = "ItemExchange"
+	ContractCourier      = "Courier"
+	ContractAuction      = "Auction"
+	ContractLoan         = "Loan"
+)
+
+// Contract statuses, as returned by the XML API.
+const (
+	ContractOutstanding = "Outstanding"
+	ContractInProgress  = "InProgress"
+	ContractCompleted   = "Completed"
+	ContractFailed      = "Failed"
+	ContractRejected    = "Rejected"
+	ContractDeleted     = "Deleted"
+)
+
+// Contract is a contract issued by or to a character.
+type Contract struct {
+	ContractID     int64   `xml:"contractID,attr"`
+	IssuerID       int     `xml:"issuerID,attr"`
+	IssuerCorpID   int     `xml:"issuerCorpID,attr"`
+	AssigneeID     int     `xml:"assigneeID,attr"`
+	AcceptorID     int     `xml:"acceptorID,attr"`
+	StartStationID int     `xml:"startStationID,attr"`
+	EndStationID   int     `xml:"endStationID,attr"`
+	Type           string  `xml:"type,attr"`
+	Status         string  `xml:"status,attr"`
+	Title          string  `xml:"title,attr"`
+	ForCorp        int     `xml:"forCorp,attr"`
+	Availability   string  `xml:"availability,attr"`
+	NumDays        int     `xml:"numDays,attr"`
+	Price          float64 `xml:"price,attr"`
+	Reward         float64 `xml:"reward,attr"`
+	Collateral     float64 `xml:"collateral,attr"`
+	Buyout         float64 `xml:"buyout,attr"`
+	Volume         float64 `xml:"volume,attr"`
+
+	DateIssued    time.Time `xml:"-"`
+	DateExpired   time.Time `xml:"-"`
+	DateAccepted  time.Time `xml:"-"`
+	DateCompleted time.Time `xml:"-"`
+}
+
+// ContractItem is an item included in or requested by a contract.
+type ContractItem struct {
+	RecordID int64 `xml:"recordID,attr"`
+	TypeID   int   `xml:"typeID,attr"`
+	Quantity int64 `xml:"quantity,attr"`
+	// Singleton is 1 if the item is assembled.
+	Singleton int `xml:"singleton,attr"`
+	// Included is 1 if the issuer is providing the item, or 0 if the issuer
+	// is asking for it.
+	Included int `xml:"included,attr"`
+}
+
+type contractsResult struct {
+	Rows []struct {
+		Contract
+		DateIssued    apiTime `xml:"dateIssued,attr"`
+		DateExpired   apiTime `xml:"dateExpired,attr"`
+		DateAccepted  apiTime `xml:"dateAccepted,attr"`
+		DateCompleted apiTime `xml:"dateCompleted,attr"`
+	} `xml:"result>rowset>row"`
+}
+
+type contractItemsResult struct {
+	Rows []ContractItem `xml:"result>rowset>row"`
+}
+
+func (x *xmlAPI) Contracts(key *evego.XMLKey, characterID int) ([]Contract, error) {
+	var result contractsResult
+	err := x.get("/char/Contracts.xml.aspx", keyParams(key, characterID), &result)
+	if err != nil {
+		return nil, err
+	}
+	contracts := make([]Contract, 0, len(result.Rows))
+	for _, row := range result.Rows {
+		contract := row.Contract
+		contract.DateIssued = row.DateIssued.Time
+		contract.DateExpired = row.DateExpired.Time
+		contract.DateAccepted = row.DateAccepted.Time
+		contract.DateCompleted = row.DateCompleted.Time
+		contracts = append(contracts, contract)
+	}
+	return contracts, nil
+}
+
+func (x *xmlAPI) ContractItems(key *evego.XMLKey, characterID int, contractID int64) ([]ContractItem, error) {
+	var result contractItemsResult
+	params := keyParams(key, characterID)
+	params.Set("contractID", strconv.FormatInt(contractID, 10))
+	err := x.get("/char/ContractItems.xml.aspx", params, &result)
+	if err != nil {
+		return nil, err
+	}
+	return result.Rows, nil
+}
@@ -0,0 +1,89 @@
+/*
+Copyright © 2014–6 Brad Ackerman.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package xmlapi
+
+import (
+	"time"
+
+	"github.com/backerman/evego"
+)
+
+// Industry job statuses, as returned by the XML API.
+const (
+	JobActive    = 1
+	JobPaused    = 2
+	JobReady     = 3
+	JobDelivered = 101
+	JobCancelled = 102
+	JobReverted  = 103
+)
+
+// IndustryJob is an industry job installed by a character or corporation.
+type IndustryJob struct {
+	JobID           int64  `xml:"jobID,attr"`
+	InstallerID     int    `xml:"installerID,attr"`
+	InstallerName   string `xml:"installerName,attr"`
+	FacilityID      int64  `xml:"facilityID,attr"`
+	SolarSystemID   int    `xml:"solarSystemID,attr"`
+	StationID       int64  `xml:"stationID,attr"`
+	ActivityID      int    `xml:"activityID,attr"`
+	BlueprintID     int64  `xml:"blueprintID,attr"`
+	BlueprintTypeID int    `xml:"blueprintTypeID,attr"`
+	Runs            int    `xml:"runs,attr"`
+	LicensedRuns    int    `xml:"licensedRuns,attr"`
+	ProductTypeID   int    `xml:"productTypeID,attr"`
+	Status          int    `xml:"status,attr"`
+
+	StartDate     time.Time `xml:"-"`
+	EndDate       time.Time `xml:"-"`
+	CompletedDate time.Time `xml:"-"`
+}
+
+type industryJobsResult struct {
+	Rows []struct {
+		IndustryJob
+		StartDate     apiTime `xml:"startDate,attr"`
+		EndDate       apiTime `xml:"endDate,attr"`
+		CompletedDate apiTime `xml:"completedDate,attr"`
+	} `xml:"result>rowset>row"`
+}
+
+func (x *xmlAPI) industryJobs(path string, key *evego.XMLKey, characterID int) ([]IndustryJob, error) {
+	var result industryJobsResult
+	err := x.get(path, keyParams(key, characterID), &result)
+	if err != nil {
+		return nil, err
+	}
+	jobs := make([]IndustryJob, 0, len(result.Rows))
+	for _, row := range result.Rows {
+		job := row.IndustryJob
+		job.StartDate = row.StartDate.Time
+		job.EndDate = row.EndDate.Time
+		job.CompletedDate = row.CompletedDate.Time
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+func (x *xmlAPI) IndustryJobs(key *evego.XMLKey, characterID int) ([]IndustryJob, error) {
+	return x.industryJobs("/char/IndustryJobs.xml.aspx", key, characterID)
+}
+
+func (x *xmlAPI) CorporationIndustryJobs(key *evego.XMLKey) ([]IndustryJob, error) {
+	return x.industryJobs("/corp/IndustryJobs.xml.aspx", key, 0)
+}
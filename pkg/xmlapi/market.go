@@ -0,0 +1,82 @@
+/*
+Copyright © 2014–6 Brad Ackerman.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package xmlapi
+
+import (
+	"time"
+
+	"github.com/backerman/evego"
+)
+
+// Market order states, as returned by the XML API.
+const (
+	OrderOpen      = 0
+	OrderClosed    = 1
+	OrderExpired   = 2
+	OrderCancelled = 3
+	OrderPending   = 4
+	OrderDeleted   = 5
+)
+
+// Special values of a market order's range.
+const (
+	RangeStation = -1
+	RangeSystem  = 0
+	RangeRegion  = 32767
+)
+
+// MarketOrder is a market order placed by a character.
+type MarketOrder struct {
+	OrderID      int64   `xml:"orderID,attr"`
+	StationID    int     `xml:"stationID,attr"`
+	VolEntered   int     `xml:"volEntered,attr"`
+	VolRemaining int     `xml:"volRemaining,attr"`
+	MinVolume    int     `xml:"minVolume,attr"`
+	OrderState   int     `xml:"orderState,attr"`
+	TypeID       int     `xml:"typeID,attr"`
+	Range        int     `xml:"range,attr"`
+	Duration     int     `xml:"duration,attr"`
+	Escrow       float64 `xml:"escrow,attr"`
+	Price        float64 `xml:"price,attr"`
+	// Bid is 1 for buy orders and 0 for sell orders.
+	Bid int `xml:"bid,attr"`
+
+	Issued time.Time `xml:"-"`
+}
+
+type marketOrdersResult struct {
+	Rows []struct {
+		MarketOrder
+		Issued apiTime `xml:"issued,attr"`
+	} `xml:"result>rowset>row"`
+}
+
+func (x *xmlAPI) MarketOrders(key *evego.XMLKey, characterID int) ([]MarketOrder, error) {
+	var result marketOrdersResult
+	err := x.get("/char/MarketOrders.xml.aspx", keyParams(key, characterID), &result)
+	if err != nil {
+		return nil, err
+	}
+	orders := make([]MarketOrder, 0, len(result.Rows))
+	for _, row := range result.Rows {
+		order := row.MarketOrder
+		order.Issued = row.Issued.Time
+		orders = append(orders, order)
+	}
+	return orders, nil
+}
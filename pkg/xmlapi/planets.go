@@ -0,0 +1,150 @@
+/*
+Copyright © 2014–6 Brad Ackerman.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package xmlapi
+
+import (
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/backerman/evego"
+)
+
+// Colony is a character's planetary colony.
+type Colony struct {
+	SolarSystemID int    `xml:"solarSystemID,attr"`
+	PlanetID      int    `xml:"planetID,attr"`
+	PlanetName    string `xml:"planetName,attr"`
+	PlanetTypeID  int    `xml:"planetTypeID,attr"`
+	UpgradeLevel  int    `xml:"upgradeLevel,attr"`
+	NumberOfPins  int    `xml:"numberOfPins,attr"`
+
+	LastUpdate time.Time `xml:"-"`
+}
+
+// Pin is a structure in a planetary colony.
+type Pin struct {
+	PinID       int64 `xml:"pinID,attr"`
+	TypeID      int   `xml:"typeID,attr"`
+	SchematicID int   `xml:"schematicID,attr"`
+	// CycleTime is the length of an extractor's cycle in minutes.
+	CycleTime        int `xml:"cycleTime,attr"`
+	QuantityPerCycle int `xml:"quantityPerCycle,attr"`
+	// ContentTypeID and ContentQuantity describe what the pin contains; for
+	// an extractor, the content type is the resource being extracted.
+	ContentTypeID   int `xml:"contentTypeID,attr"`
+	ContentQuantity int `xml:"contentQuantity,attr"`
+
+	LastLaunchTime time.Time `xml:"-"`
+	InstallTime    time.Time `xml:"-"`
+	ExpiryTime     time.Time `xml:"-"`
+}
+
+// Route is a link along which a colony moves commodities between pins.
+type Route struct {
+	RouteID          int64 `xml:"routeID,attr"`
+	SourcePinID      int64 `xml:"sourcePinID,attr"`
+	DestinationPinID int64 `xml:"destinationPinID,attr"`
+	ContentTypeID    int   `xml:"contentTypeID,attr"`
+	Quantity         int   `xml:"quantity,attr"`
+	Waypoint1        int64 `xml:"waypoint1,attr"`
+	Waypoint2        int64 `xml:"waypoint2,attr"`
+	Waypoint3        int64 `xml:"waypoint3,attr"`
+	Waypoint4        int64 `xml:"waypoint4,attr"`
+	Waypoint5        int64 `xml:"waypoint5,attr"`
+}
+
+// Waypoints returns the pins that a route passes through, in order.
+func (r *Route) Waypoints() []int64 {
+	waypoints := make([]int64, 0, 5)
+	for _, w := range []int64{r.Waypoint1, r.Waypoint2, r.Waypoint3, r.Waypoint4, r.Waypoint5} {
+		if w != 0 {
+			waypoints = append(waypoints, w)
+		}
+	}
+	return waypoints
+}
+
+type coloniesResult struct {
+	Rows []struct {
+		Colony
+		LastUpdate apiTime `xml:"lastUpdate,attr"`
+	} `xml:"result>rowset>row"`
+}
+
+type pinsResult struct {
+	Rows []struct {
+		Pin
+		LastLaunchTime apiTime `xml:"lastLaunchTime,attr"`
+		InstallTime    apiTime `xml:"installTime,attr"`
+		ExpiryTime     apiTime `xml:"expiryTime,attr"`
+	} `xml:"result>rowset>row"`
+}
+
+type routesResult struct {
+	Rows []Route `xml:"result>rowset>row"`
+}
+
+// planetParams returns the query parameters for a call about one of a
+// character's planets.
+func planetParams(key *evego.XMLKey, characterID, planetID int) url.Values {
+	params := keyParams(key, characterID)
+	params.Set("planetID", strconv.Itoa(planetID))
+	return params
+}
+
+func (x *xmlAPI) PlanetaryColonies(key *evego.XMLKey, characterID int) ([]Colony, error) {
+	var result coloniesResult
+	err := x.get("/char/PlanetaryColonies.xml.aspx", keyParams(key, characterID), &result)
+	if err != nil {
+		return nil, err
+	}
+	colonies := make([]Colony, 0, len(result.Rows))
+	for _, row := range result.Rows {
+		colony := row.Colony
+		colony.LastUpdate = row.LastUpdate.Time
+		colonies = append(colonies, colony)
+	}
+	return colonies, nil
+}
+
+func (x *xmlAPI) PlanetaryPins(key *evego.XMLKey, characterID, planetID int) ([]Pin, error) {
+	var result pinsResult
+	err := x.get("/char/PlanetaryPins.xml.aspx", planetParams(key, characterID, planetID), &result)
+	if err != nil {
+		return nil, err
+	}
+	pins := make([]Pin, 0, len(result.Rows))
+	for _, row := range result.Rows {
+		pin := row.Pin
+		pin.LastLaunchTime = row.LastLaunchTime.Time
+		pin.InstallTime = row.InstallTime.Time
+		pin.ExpiryTime = row.ExpiryTime.Time
+		pins = append(pins, pin)
+	}
+	return pins, nil
+}
+
+func (x *xmlAPI) PlanetaryRoutes(key *evego.XMLKey, characterID, planetID int) ([]Route, error) {
+	var result routesResult
+	err := x.get("/char/PlanetaryRoutes.xml.aspx", planetParams(key, characterID, planetID), &result)
+	if err != nil {
+		return nil, err
+	}
+	return result.Rows, nil
+}
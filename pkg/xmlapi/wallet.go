@@ -0,0 +1,125 @@
+/*
+Copyright © 2014–6 Brad Ackerman.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package xmlapi
+
+import (
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/backerman/evego"
+)
+
+// WalletPageSize is the maximum number of rows returned by a wallet call.
+const WalletPageSize = 2560
+
+// WalletTransaction is a market transaction from a character's wallet.
+type WalletTransaction struct {
+	TransactionID int64   `xml:"transactionID,attr"`
+	Quantity      int     `xml:"quantity,attr"`
+	TypeID        int     `xml:"typeID,attr"`
+	Price         float64 `xml:"price,attr"`
+	ClientID      int     `xml:"clientID,attr"`
+	ClientName    string  `xml:"clientName,attr"`
+	StationID     int64   `xml:"stationID,attr"`
+	// TransactionType is "buy" or "sell".
+	TransactionType string `xml:"transactionType,attr"`
+	// TransactionFor is "personal" or "corporation".
+	TransactionFor string `xml:"transactionFor,attr"`
+	JournalRefID   int64  `xml:"journalTransactionID,attr"`
+
+	Date time.Time `xml:"-"`
+}
+
+// Journal reference types that we're interested in.
+const (
+	RefTypeBrokerFee      = 46
+	RefTypeTransactionTax = 54
+)
+
+// JournalEntry is an entry in a character's wallet journal.
+type JournalEntry struct {
+	RefID      int64   `xml:"refID,attr"`
+	RefTypeID  int     `xml:"refTypeID,attr"`
+	OwnerID1   int     `xml:"ownerID1,attr"`
+	OwnerName1 string  `xml:"ownerName1,attr"`
+	OwnerID2   int     `xml:"ownerID2,attr"`
+	OwnerName2 string  `xml:"ownerName2,attr"`
+	ArgID1     int64   `xml:"argID1,attr"`
+	ArgName1   string  `xml:"argName1,attr"`
+	Amount     float64 `xml:"amount,attr"`
+	Balance    float64 `xml:"balance,attr"`
+	Reason     string  `xml:"reason,attr"`
+
+	Date time.Time `xml:"-"`
+}
+
+// walletParams returns the parameters for a wallet call; if fromID is
+// nonzero, only rows older than it will be returned.
+func walletParams(key *evego.XMLKey, characterID int, fromID int64) url.Values {
+	params := keyParams(key, characterID)
+	params.Set("rowCount", strconv.Itoa(WalletPageSize))
+	if fromID != 0 {
+		params.Set("fromID", strconv.FormatInt(fromID, 10))
+	}
+	return params
+}
+
+type walletTransactionsResult struct {
+	Rows []struct {
+		WalletTransaction
+		Date apiTime `xml:"transactionDateTime,attr"`
+	} `xml:"result>rowset>row"`
+}
+
+func (x *xmlAPI) WalletTransactions(key *evego.XMLKey, characterID int, fromID int64) ([]WalletTransaction, error) {
+	var result walletTransactionsResult
+	err := x.get("/char/WalletTransactions.xml.aspx", walletParams(key, characterID, fromID), &result)
+	if err != nil {
+		return nil, err
+	}
+	txns := make([]WalletTransaction, 0, len(result.Rows))
+	for _, row := range result.Rows {
+		txn := row.WalletTransaction
+		txn.Date = row.Date.Time
+		txns = append(txns, txn)
+	}
+	return txns, nil
+}
+
+type walletJournalResult struct {
+	Rows []struct {
+		JournalEntry
+		Date apiTime `xml:"date,attr"`
+	} `xml:"result>rowset>row"`
+}
+
+func (x *xmlAPI) WalletJournal(key *evego.XMLKey, characterID int, fromID int64) ([]JournalEntry, error) {
+	var result walletJournalResult
+	err := x.get("/char/WalletJournal.xml.aspx", walletParams(key, characterID, fromID), &result)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]JournalEntry, 0, len(result.Rows))
+	for _, row := range result.Rows {
+		entry := row.JournalEntry
+		entry.Date = row.Date.Time
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
@@ -0,0 +1,228 @@
+/*
+Copyright © 2014–6 Brad Ackerman.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+// Package xmlapi provides access to the EVE XML API calls that evego does not
+// implement.
+package xmlapi
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/backerman/evego"
+)
+
+// API is an interface to the XML API calls that we need and evego doesn't
+// provide.
+type API interface {
+	// SkillQueue returns a character's skill queue.
+	SkillQueue(key *evego.XMLKey, characterID int) ([]QueuedSkill, error)
+
+	// CharacterAttributes returns a character's attributes and total
+	// skillpoints.
+	CharacterAttributes(key *evego.XMLKey, characterID int) (*Attributes, error)
+
+	// IndustryJobs returns a character's running and recently completed
+	// industry jobs.
+	IndustryJobs(key *evego.XMLKey, characterID int) ([]IndustryJob, error)
+
+	// CorporationIndustryJobs returns the industry jobs of the corporation to
+	// which a corporation key belongs. It will fail for character keys.
+	CorporationIndustryJobs(key *evego.XMLKey) ([]IndustryJob, error)
+
+	// WalletTransactions returns up to WalletPageSize of a character's market
+	// transactions, newest first. If fromID is nonzero, only transactions
+	// older than that ID are returned.
+	WalletTransactions(key *evego.XMLKey, characterID int, fromID int64) ([]WalletTransaction, error)
+
+	// WalletJournal returns up to WalletPageSize of a character's wallet
+	// journal entries, newest first. If fromID is nonzero, only entries older
+	// than that ID are returned.
+	WalletJournal(key *evego.XMLKey, characterID int, fromID int64) ([]JournalEntry, error)
+
+	// MarketOrders returns a character's open market orders and those that
+	// have recently closed.
+	MarketOrders(key *evego.XMLKey, characterID int) ([]MarketOrder, error)
+
+	// Contracts returns the contracts issued by or to a character in the last
+	// month, along with any that are still outstanding.
+	Contracts(key *evego.XMLKey, characterID int) ([]Contract, error)
+
+	// ContractItems returns the items included in or requested by one of a
+	// character's contracts.
+	ContractItems(key *evego.XMLKey, characterID int, contractID int64) ([]ContractItem, error)
+
+	// PlanetaryColonies returns a character's planetary colonies.
+	PlanetaryColonies(key *evego.XMLKey, characterID int) ([]Colony, error)
+
+	// PlanetaryPins returns the structures in one of a character's colonies.
+	PlanetaryPins(key *evego.XMLKey, characterID, planetID int) ([]Pin, error)
+
+	// PlanetaryRoutes returns the routes in one of a character's colonies.
+	PlanetaryRoutes(key *evego.XMLKey, characterID, planetID int) ([]Route, error)
+}
+
+type xmlAPI struct {
+	endpoint *url.URL
+	cache    evego.Cache
+	client   *http.Client
+}
+
+// XML returns an interface to the XML API at the provided endpoint. Results
+// will be cached in the passed cache for as long as CCP says they're valid.
+func XML(endpoint string, cache evego.Cache) API {
+	endpointURL, err := url.Parse(endpoint)
+	if err != nil {
+		panic(fmt.Sprintf("Invalid XML API endpoint %v: %v", endpoint, err))
+	}
+	return &xmlAPI{
+		endpoint: endpointURL,
+		cache:    cache,
+		client:   &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// APIError is an error returned by the XML API itself (as opposed to, e.g.,
+// a network error).
+type APIError struct {
+	Code    int    `xml:"code,attr"`
+	Message string `xml:",chardata"`
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("XML API error %d: %s", e.Code, strings.TrimSpace(e.Message))
+}
+
+// XML API error codes returned when a key can't be used for a corporation
+// page: either it's a character key, or its owner lacks the roles needed.
+const (
+	errCodeCorpRoles   = 220
+	errCodeIllegalPage = 221
+)
+
+// IsNotCorporationKey returns true iff err is the XML API's refusal to serve
+// a corporation page to the key that requested it.
+func IsNotCorporationKey(err error) bool {
+	apiErr, ok := err.(*APIError)
+	if !ok {
+		return false
+	}
+	return apiErr.Code == errCodeCorpRoles || apiErr.Code == errCodeIllegalPage
+}
+
+// apiTime is a timestamp in the XML API's format, which is always UTC.
+type apiTime struct {
+	time.Time
+}
+
+const apiTimeFormat = "2006-01-02 15:04:05"
+
+func parseAPITime(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, nil
+	}
+	return time.ParseInLocation(apiTimeFormat, s, time.UTC)
+}
+
+func (t *apiTime) UnmarshalXMLAttr(attr xml.Attr) error {
+	parsed, err := parseAPITime(attr.Value)
+	t.Time = parsed
+	return err
+}
+
+func (t *apiTime) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var s string
+	err := d.DecodeElement(&s, &start)
+	if err != nil {
+		return err
+	}
+	parsed, err := parseAPITime(s)
+	t.Time = parsed
+	return err
+}
+
+// envelope is the wrapper around every XML API response.
+type envelope struct {
+	CachedUntil apiTime   `xml:"cachedUntil"`
+	Error       *APIError `xml:"error"`
+}
+
+// keyParams returns the query parameters identifying the key and character
+// for a call.
+func keyParams(key *evego.XMLKey, characterID int) url.Values {
+	params := url.Values{}
+	params.Set("keyID", strconv.Itoa(key.KeyID))
+	params.Set("vCode", key.VerificationCode)
+	if characterID != 0 {
+		params.Set("characterID", strconv.Itoa(characterID))
+	}
+	return params
+}
+
+// cacheKey returns the key under which a call's response is cached. The
+// parameters are hashed so that verification codes don't end up in the
+// cache in plain text.
+func cacheKey(path string, params url.Values) string {
+	sum := sha256.Sum256([]byte(path + "?" + params.Encode()))
+	return "xmlapi:" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// get calls the specified XML API page and unmarshals the response into
+// result.
+func (x *xmlAPI) get(path string, params url.Values, result interface{}) error {
+	key := cacheKey(path, params)
+	body, found := x.cache.Get(key)
+	if !found {
+		callURL := *x.endpoint
+		callURL.Path = strings.TrimRight(callURL.Path, "/") + path
+		callURL.RawQuery = params.Encode()
+		resp, err := x.client.Get(callURL.String())
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		body, err = ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return err
+		}
+		// The XML API returns errors with non-200 statuses but still provides
+		// an XML body describing the error, so only bail out here if there's
+		// nothing to parse.
+		if resp.StatusCode != http.StatusOK && len(body) == 0 {
+			return fmt.Errorf("XML API returned status %v for %v", resp.Status, path)
+		}
+		var env envelope
+		err = xml.Unmarshal(body, &env)
+		if err != nil {
+			return err
+		}
+		if env.Error != nil {
+			return env.Error
+		}
+		x.cache.Put(key, body, env.CachedUntil.Time)
+	}
+	return xml.Unmarshal(body, result)
+}
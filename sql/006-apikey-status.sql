@@ -0,0 +1,41 @@
+-- Copyright © 2014–6 Brad Ackerman.
+--
+-- Licensed under the Apache License, Version 2.0 (the "License");
+-- you may not use this file except in compliance with the License.
+-- You may obtain a copy of the License at
+--
+-- http://www.apache.org/licenses/LICENSE-2.0
+--
+-- Unless required by applicable law or agreed to in writing, software
+-- distributed under the License is distributed on an "AS IS" BASIS,
+-- WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+-- See the License for the specific language governing permissions and
+-- limitations under the License.
+
+-- apikeys: track the health of each key so that broken keys can be reported
+-- to their owners and skipped by the background refresher.
+ALTER TABLE eveindy.apikeys
+  -- status is one of 'ok', 'expired', 'invalid', 'insufficient', or
+  -- 'transient'; the last indicates a retryable failure (e.g. API downtime).
+  ADD COLUMN status text NOT NULL DEFAULT 'ok',
+  -- lastError is the error message returned by the most recent failed sync.
+  ADD COLUMN lastError text,
+  -- lastSuccess is the time at which this key was last used successfully.
+  ADD COLUMN lastSuccess timestamp with time zone,
+  ADD CHECK (status IN ('ok', 'expired', 'invalid', 'insufficient', 'transient'));
+
+-- apikeySteps: the outcome of each step of the most recent sync of each
+-- character on a key. Only failures in the core steps (characters, skills and
+-- assets) are reflected in the key's own status.
+CREATE TABLE eveindy.apikeySteps (
+  keyid integer NOT NULL REFERENCES eveindy.apikeys(id) ON DELETE CASCADE DEFERRABLE,
+  -- charid is 0 for the characters step, which covers the whole key.
+  charid integer NOT NULL,
+  step text NOT NULL,
+  status text NOT NULL
+    CHECK (status IN ('ok', 'expired', 'invalid', 'insufficient', 'transient')),
+  lastError text,
+  updated timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+  PRIMARY KEY (keyid, charid, step)
+);
@@ -0,0 +1,58 @@
+-- Copyright © 2014–6 Brad Ackerman.
+--
+-- Licensed under the Apache License, Version 2.0 (the "License");
+-- you may not use this file except in compliance with the License.
+-- You may obtain a copy of the License at
+--
+-- http://www.apache.org/licenses/LICENSE-2.0
+--
+-- Unless required by applicable law or agreed to in writing, software
+-- distributed under the License is distributed on an "AS IS" BASIS,
+-- WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+-- See the License for the specific language governing permissions and
+-- limitations under the License.
+
+-- skillQueue: a character's skill queue
+CREATE TABLE eveindy.skillQueue (
+  charID integer NOT NULL,
+  position integer NOT NULL,
+  typeID integer NOT NULL,
+  level integer NOT NULL CHECK (level >= 1 AND level <= 5),
+  startSP integer NOT NULL,
+  endSP integer NOT NULL,
+  -- startTime and endTime are null if the queue is paused.
+  startTime timestamp with time zone,
+  endTime timestamp with time zone,
+
+  PRIMARY KEY (charID, position),
+  FOREIGN KEY (charID) REFERENCES eveindy.characters (id)
+    ON DELETE CASCADE DEFERRABLE,
+  FOREIGN KEY (typeID) REFERENCES "invTypes" ("typeID") DEFERRABLE
+);
+
+-- characterAttributes: a character's attributes (before implants) and total
+-- skillpoints
+CREATE TABLE eveindy.characterAttributes (
+  charID integer NOT NULL PRIMARY KEY,
+  intelligence integer NOT NULL,
+  memory integer NOT NULL,
+  charisma integer NOT NULL,
+  perception integer NOT NULL,
+  willpower integer NOT NULL,
+  skillPoints bigint NOT NULL,
+  freeSkillPoints integer NOT NULL,
+
+  FOREIGN KEY (charID) REFERENCES eveindy.characters (id)
+    ON DELETE CASCADE DEFERRABLE
+);
+
+-- implants: a character's active implants
+CREATE TABLE eveindy.implants (
+  charID integer NOT NULL,
+  typeID integer NOT NULL,
+
+  PRIMARY KEY (charID, typeID),
+  FOREIGN KEY (charID) REFERENCES eveindy.characters (id)
+    ON DELETE CASCADE DEFERRABLE,
+  FOREIGN KEY (typeID) REFERENCES "invTypes" ("typeID") DEFERRABLE
+);
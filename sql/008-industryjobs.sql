@@ -0,0 +1,53 @@
+-- Copyright © 2014–6 Brad Ackerman.
+--
+-- Licensed under the Apache License, Version 2.0 (the "License");
+-- you may not use this file except in compliance with the License.
+-- You may obtain a copy of the License at
+--
+-- http://www.apache.org/licenses/LICENSE-2.0
+--
+-- Unless required by applicable law or agreed to in writing, software
+-- distributed under the License is distributed on an "AS IS" BASIS,
+-- WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+-- See the License for the specific language governing permissions and
+-- limitations under the License.
+
+-- industryJobs: industry jobs installed by characters (or their corporations)
+CREATE TABLE eveindy.industryJobs (
+  jobID bigint NOT NULL,
+  -- charID is the character whose API key provided this job; for
+  -- corporation jobs, it's not necessarily the installer, and each member
+  -- whose key sees the job keeps a copy.
+  charID integer NOT NULL,
+  apikey integer NOT NULL,
+  isCorporation boolean NOT NULL,
+  installerID integer NOT NULL,
+  installerName text NOT NULL,
+  facilityID bigint NOT NULL,
+  solarSystemID integer NOT NULL,
+  stationID bigint NOT NULL,
+  activityID integer NOT NULL,
+  blueprintID bigint NOT NULL,
+  blueprintTypeID integer NOT NULL,
+  runs integer NOT NULL,
+  licensedRuns integer NOT NULL,
+  productTypeID integer,
+  -- status: 1 active, 2 paused, 3 ready, 101 delivered, 102 cancelled,
+  -- 103 reverted, 199 gone (dropped off the API without a final status)
+  status integer NOT NULL,
+  startDate timestamp with time zone NOT NULL,
+  endDate timestamp with time zone NOT NULL,
+  completedDate timestamp with time zone,
+
+  FOREIGN KEY (charID) REFERENCES eveindy.characters (id)
+    ON DELETE CASCADE DEFERRABLE,
+  FOREIGN KEY (apikey) REFERENCES eveindy.apikeys (id)
+    ON DELETE CASCADE DEFERRABLE,
+  FOREIGN KEY (activityID) REFERENCES "ramActivities" ("activityID") DEFERRABLE,
+  FOREIGN KEY (blueprintTypeID) REFERENCES "invTypes" ("typeID") DEFERRABLE,
+  PRIMARY KEY (jobID, isCorporation, charID),
+  CHECK (runs > 0)
+);
+
+CREATE INDEX industryJobs_charid ON eveindy.industryJobs (charID);
+CREATE INDEX industryJobs_blueprintid ON eveindy.industryJobs (blueprintID);
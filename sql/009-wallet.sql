@@ -0,0 +1,71 @@
+-- Copyright © 2014–6 Brad Ackerman.
+--
+-- Licensed under the Apache License, Version 2.0 (the "License");
+-- you may not use this file except in compliance with the License.
+-- You may obtain a copy of the License at
+--
+-- http://www.apache.org/licenses/LICENSE-2.0
+--
+-- Unless required by applicable law or agreed to in writing, software
+-- distributed under the License is distributed on an "AS IS" BASIS,
+-- WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+-- See the License for the specific language governing permissions and
+-- limitations under the License.
+
+-- walletTransactions: characters' market transactions. A trade between two
+-- of a user's characters appears once for each of them.
+CREATE TABLE eveindy.walletTransactions (
+  transactionID bigint NOT NULL,
+  charID integer NOT NULL,
+  apikey integer NOT NULL,
+  transactionDate timestamp with time zone NOT NULL,
+  typeID integer NOT NULL,
+  quantity integer NOT NULL,
+  price double precision NOT NULL,
+  clientID integer NOT NULL,
+  clientName text NOT NULL,
+  stationID bigint NOT NULL,
+  transactionType text NOT NULL,
+  transactionFor text NOT NULL,
+  journalRefID bigint NOT NULL,
+
+  PRIMARY KEY (charID, transactionID),
+  FOREIGN KEY (charID) REFERENCES eveindy.characters (id)
+    ON DELETE CASCADE DEFERRABLE,
+  FOREIGN KEY (apikey) REFERENCES eveindy.apikeys (id)
+    ON DELETE CASCADE DEFERRABLE,
+  FOREIGN KEY (typeID) REFERENCES "invTypes" ("typeID") DEFERRABLE,
+  CHECK (quantity > 0),
+  CHECK (transactionType IN ('buy', 'sell')),
+  CHECK (transactionFor IN ('personal', 'corporation'))
+);
+
+CREATE INDEX walletTransactions_charid_date
+  ON eveindy.walletTransactions (charID, transactionDate);
+
+-- walletJournal: characters' wallet journal entries
+CREATE TABLE eveindy.walletJournal (
+  charID integer NOT NULL,
+  refID bigint NOT NULL,
+  apikey integer NOT NULL,
+  entryDate timestamp with time zone NOT NULL,
+  refTypeID integer NOT NULL,
+  ownerID1 integer NOT NULL,
+  ownerName1 text NOT NULL,
+  ownerID2 integer NOT NULL,
+  ownerName2 text NOT NULL,
+  argID1 bigint NOT NULL,
+  argName1 text NOT NULL,
+  amount double precision NOT NULL,
+  balance double precision NOT NULL,
+  reason text NOT NULL,
+
+  PRIMARY KEY (charID, refID),
+  FOREIGN KEY (charID) REFERENCES eveindy.characters (id)
+    ON DELETE CASCADE DEFERRABLE,
+  FOREIGN KEY (apikey) REFERENCES eveindy.apikeys (id)
+    ON DELETE CASCADE DEFERRABLE
+);
+
+CREATE INDEX walletJournal_charid_date
+  ON eveindy.walletJournal (charID, entryDate);
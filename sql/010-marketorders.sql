@@ -0,0 +1,43 @@
+-- Copyright © 2014–6 Brad Ackerman.
+--
+-- Licensed under the Apache License, Version 2.0 (the "License");
+-- you may not use this file except in compliance with the License.
+-- You may obtain a copy of the License at
+--
+-- http://www.apache.org/licenses/LICENSE-2.0
+--
+-- Unless required by applicable law or agreed to in writing, software
+-- distributed under the License is distributed on an "AS IS" BASIS,
+-- WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+-- See the License for the specific language governing permissions and
+-- limitations under the License.
+
+-- marketOrders: characters' market orders
+CREATE TABLE eveindy.marketOrders (
+  orderID bigint NOT NULL PRIMARY KEY,
+  charID integer NOT NULL,
+  apikey integer NOT NULL,
+  stationID integer NOT NULL,
+  volEntered integer NOT NULL,
+  volRemaining integer NOT NULL,
+  minVolume integer NOT NULL,
+  -- orderState: 0 open, 1 closed, 2 expired, 3 cancelled, 4 pending,
+  -- 5 deleted
+  orderState integer NOT NULL,
+  typeID integer NOT NULL,
+  -- orderRange: -1 station, 0 system, 32767 region, otherwise number of jumps
+  orderRange integer NOT NULL,
+  duration integer NOT NULL,
+  escrow double precision NOT NULL,
+  price double precision NOT NULL,
+  isBuy boolean NOT NULL,
+  issued timestamp with time zone NOT NULL,
+
+  FOREIGN KEY (charID) REFERENCES eveindy.characters (id)
+    ON DELETE CASCADE DEFERRABLE,
+  FOREIGN KEY (apikey) REFERENCES eveindy.apikeys (id)
+    ON DELETE CASCADE DEFERRABLE,
+  FOREIGN KEY (typeID) REFERENCES "invTypes" ("typeID") DEFERRABLE
+);
+
+CREATE INDEX marketOrders_charid ON eveindy.marketOrders (charID);
@@ -0,0 +1,23 @@
+-- Copyright © 2014–6 Brad Ackerman.
+--
+-- Licensed under the Apache License, Version 2.0 (the "License");
+-- you may not use this file except in compliance with the License.
+-- You may obtain a copy of the License at
+--
+-- http://www.apache.org/licenses/LICENSE-2.0
+--
+-- Unless required by applicable law or agreed to in writing, software
+-- distributed under the License is distributed on an "AS IS" BASIS,
+-- WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+-- See the License for the specific language governing permissions and
+-- limitations under the License.
+
+-- agentStandings: a character's standings with NPC agents (before skills).
+-- agent isn't a foreign key so that standings with agents added since our copy
+-- of the SDE was made aren't rejected; they're ignored when joined with it.
+CREATE TABLE eveindy.agentStandings (
+  charid integer REFERENCES eveindy.characters(id) ON DELETE CASCADE DEFERRABLE,
+  agent integer NOT NULL,
+  standing float NOT NULL CHECK (standing >= -10.0 AND standing <= 10.0),
+  PRIMARY KEY (charid, agent)
+);
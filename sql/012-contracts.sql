@@ -0,0 +1,69 @@
+-- Copyright © 2014–6 Brad Ackerman.
+--
+-- Licensed under the Apache License, Version 2.0 (the "License");
+-- you may not use this file except in compliance with the License.
+-- You may obtain a copy of the License at
+--
+-- http://www.apache.org/licenses/LICENSE-2.0
+--
+-- Unless required by applicable law or agreed to in writing, software
+-- distributed under the License is distributed on an "AS IS" BASIS,
+-- WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+-- See the License for the specific language governing permissions and
+-- limitations under the License.
+
+-- contracts: contracts issued by or to characters
+CREATE TABLE eveindy.contracts (
+  contractID bigint NOT NULL,
+  charID integer NOT NULL,
+  apikey integer NOT NULL,
+  issuerID integer NOT NULL,
+  issuerCorpID integer NOT NULL,
+  assigneeID integer NOT NULL,
+  acceptorID integer NOT NULL,
+  startStationID integer NOT NULL,
+  endStationID integer NOT NULL,
+  -- contractType: ItemExchange, Courier, Auction, or Loan
+  contractType text NOT NULL,
+  -- status: Outstanding, InProgress, Completed, Failed, Rejected, Deleted, etc.
+  status text NOT NULL,
+  title text NOT NULL,
+  forCorp boolean NOT NULL,
+  availability text NOT NULL,
+  dateIssued timestamp with time zone NOT NULL,
+  dateExpired timestamp with time zone NOT NULL,
+  dateAccepted timestamp with time zone,
+  dateCompleted timestamp with time zone,
+  numDays integer NOT NULL,
+  price double precision NOT NULL,
+  reward double precision NOT NULL,
+  collateral double precision NOT NULL,
+  buyout double precision NOT NULL,
+  volume double precision NOT NULL,
+
+  PRIMARY KEY (contractID, charID),
+  FOREIGN KEY (charID) REFERENCES eveindy.characters (id)
+    ON DELETE CASCADE DEFERRABLE,
+  FOREIGN KEY (apikey) REFERENCES eveindy.apikeys (id)
+    ON DELETE CASCADE DEFERRABLE
+);
+
+CREATE INDEX contracts_charid ON eveindy.contracts (charID);
+
+-- contractItems: the items included in or requested by a contract
+CREATE TABLE eveindy.contractItems (
+  contractID bigint NOT NULL,
+  charID integer NOT NULL,
+  recordID bigint NOT NULL,
+  typeID integer NOT NULL,
+  quantity bigint NOT NULL,
+  singleton boolean NOT NULL,
+  -- included is true if the issuer provides the item, and false if the
+  -- issuer is asking for it.
+  included boolean NOT NULL,
+
+  PRIMARY KEY (contractID, charID, recordID),
+  FOREIGN KEY (contractID, charID) REFERENCES eveindy.contracts (contractID, charID)
+    ON DELETE CASCADE DEFERRABLE,
+  FOREIGN KEY (typeID) REFERENCES "invTypes" ("typeID") DEFERRABLE
+);
@@ -0,0 +1,76 @@
+-- Copyright © 2014–6 Brad Ackerman.
+--
+-- Licensed under the Apache License, Version 2.0 (the "License");
+-- you may not use this file except in compliance with the License.
+-- You may obtain a copy of the License at
+--
+-- http://www.apache.org/licenses/LICENSE-2.0
+--
+-- Unless required by applicable law or agreed to in writing, software
+-- distributed under the License is distributed on an "AS IS" BASIS,
+-- WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+-- See the License for the specific language governing permissions and
+-- limitations under the License.
+
+-- planetaryColonies: characters' planetary interaction colonies
+CREATE TABLE eveindy.planetaryColonies (
+  charID integer NOT NULL,
+  apikey integer NOT NULL,
+  planetID integer NOT NULL,
+  solarSystemID integer NOT NULL,
+  planetName text NOT NULL,
+  planetTypeID integer NOT NULL,
+  upgradeLevel integer NOT NULL,
+  numberOfPins integer NOT NULL,
+  lastUpdate timestamp with time zone NOT NULL,
+
+  PRIMARY KEY (charID, planetID),
+  FOREIGN KEY (charID) REFERENCES eveindy.characters (id)
+    ON DELETE CASCADE DEFERRABLE,
+  FOREIGN KEY (apikey) REFERENCES eveindy.apikeys (id)
+    ON DELETE CASCADE DEFERRABLE,
+  FOREIGN KEY (solarSystemID) REFERENCES "mapSolarSystems" ("solarSystemID") DEFERRABLE,
+  FOREIGN KEY (planetTypeID) REFERENCES "invTypes" ("typeID") DEFERRABLE
+);
+
+-- planetaryPins: the structures in a colony
+CREATE TABLE eveindy.planetaryPins (
+  charID integer NOT NULL,
+  planetID integer NOT NULL,
+  pinID bigint NOT NULL,
+  typeID integer NOT NULL,
+  -- schematicID is zero for anything that isn't a factory.
+  schematicID integer NOT NULL,
+  -- cycleTime is in minutes.
+  cycleTime integer NOT NULL,
+  quantityPerCycle integer NOT NULL,
+  -- contentTypeID is null if the pin is empty.
+  contentTypeID integer,
+  contentQuantity integer NOT NULL,
+  lastLaunchTime timestamp with time zone,
+  installTime timestamp with time zone,
+  expiryTime timestamp with time zone,
+
+  PRIMARY KEY (charID, pinID),
+  FOREIGN KEY (charID, planetID) REFERENCES eveindy.planetaryColonies (charID, planetID)
+    ON DELETE CASCADE DEFERRABLE,
+  FOREIGN KEY (typeID) REFERENCES "invTypes" ("typeID") DEFERRABLE,
+  FOREIGN KEY (contentTypeID) REFERENCES "invTypes" ("typeID") DEFERRABLE
+);
+
+-- planetaryRoutes: the routes between structures in a colony
+CREATE TABLE eveindy.planetaryRoutes (
+  charID integer NOT NULL,
+  planetID integer NOT NULL,
+  routeID bigint NOT NULL,
+  sourcePinID bigint NOT NULL,
+  destinationPinID bigint NOT NULL,
+  contentTypeID integer NOT NULL,
+  quantity integer NOT NULL,
+  waypoints bigint[] NOT NULL,
+
+  PRIMARY KEY (charID, routeID),
+  FOREIGN KEY (charID, planetID) REFERENCES eveindy.planetaryColonies (charID, planetID)
+    ON DELETE CASCADE DEFERRABLE,
+  FOREIGN KEY (contentTypeID) REFERENCES "invTypes" ("typeID") DEFERRABLE
+);
@@ -0,0 +1,36 @@
+-- Copyright © 2014–6 Brad Ackerman.
+--
+-- Licensed under the Apache License, Version 2.0 (the "License");
+-- you may not use this file except in compliance with the License.
+-- You may obtain a copy of the License at
+--
+-- http://www.apache.org/licenses/LICENSE-2.0
+--
+-- Unless required by applicable law or agreed to in writing, software
+-- distributed under the License is distributed on an "AS IS" BASIS,
+-- WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+-- See the License for the specific language governing permissions and
+-- limitations under the License.
+
+-- marketSnapshot: market orders imported from order-book snapshots, used by
+-- the local market provider.
+CREATE TABLE eveindy.marketSnapshot (
+  orderID bigint NOT NULL PRIMARY KEY,
+  typeID integer NOT NULL,
+  regionID integer NOT NULL,
+  systemID integer NOT NULL,
+  stationID bigint NOT NULL,
+  isBuy boolean NOT NULL,
+  price double precision NOT NULL,
+  volRemaining integer NOT NULL,
+  minVolume integer NOT NULL,
+  -- orderRange: -1 station, 0 system, 32767 region, otherwise number of jumps
+  orderRange integer NOT NULL,
+  issued timestamp with time zone NOT NULL,
+  duration integer NOT NULL,
+  importedAt timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+  FOREIGN KEY (typeID) REFERENCES "invTypes" ("typeID") DEFERRABLE
+);
+
+CREATE INDEX marketSnapshot_type_region ON eveindy.marketSnapshot (typeID, regionID);
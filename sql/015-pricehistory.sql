@@ -0,0 +1,34 @@
+-- Copyright © 2014–6 Brad Ackerman.
+--
+-- Licensed under the Apache License, Version 2.0 (the "License");
+-- you may not use this file except in compliance with the License.
+-- You may obtain a copy of the License at
+--
+-- http://www.apache.org/licenses/LICENSE-2.0
+--
+-- Unless required by applicable law or agreed to in writing, software
+-- distributed under the License is distributed on an "AS IS" BASIS,
+-- WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+-- See the License for the specific language governing permissions and
+-- limitations under the License.
+
+-- priceHistory: daily snapshots of the market for selected items at
+-- selected stations
+CREATE TABLE eveindy.priceHistory (
+  typeID integer NOT NULL,
+  stationID integer NOT NULL,
+  day date NOT NULL,
+  bestBuy double precision NOT NULL,
+  bestSell double precision NOT NULL,
+  buyVolume bigint NOT NULL,
+  sellVolume bigint NOT NULL,
+  buyOrders integer NOT NULL,
+  sellOrders integer NOT NULL,
+  sellP5 double precision NOT NULL,
+  sellMedian double precision NOT NULL,
+  buyP95 double precision NOT NULL,
+  buyMedian double precision NOT NULL,
+
+  PRIMARY KEY (typeID, stationID, day),
+  FOREIGN KEY (typeID) REFERENCES "invTypes" ("typeID") DEFERRABLE
+);
@@ -0,0 +1,37 @@
+-- Copyright © 2014–6 Brad Ackerman.
+--
+-- Licensed under the Apache License, Version 2.0 (the "License");
+-- you may not use this file except in compliance with the License.
+-- You may obtain a copy of the License at
+--
+-- http://www.apache.org/licenses/LICENSE-2.0
+--
+-- Unless required by applicable law or agreed to in writing, software
+-- distributed under the License is distributed on an "AS IS" BASIS,
+-- WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+-- See the License for the specific language governing permissions and
+-- limitations under the License.
+
+-- appraisals: saved valuations of item lists, shareable by ID
+CREATE TABLE eveindy.appraisals (
+  id text NOT NULL PRIMARY KEY,
+  -- userid is null for appraisals made by users who weren't logged in.
+  userid integer REFERENCES eveindy.users(id) ON DELETE SET NULL,
+  created timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  -- The pricing location: stationID is null if the items were priced in a
+  -- region. location is a human-readable description of either.
+  stationID integer,
+  region text NOT NULL DEFAULT '',
+  location text NOT NULL,
+  method text NOT NULL,
+  topPercent double precision NOT NULL,
+  -- items is the parsed paste, prices the prices returned for it, and
+  -- unmatched the lines that couldn't be priced, all as sent to the client.
+  items json NOT NULL,
+  prices json NOT NULL,
+  unmatched json NOT NULL DEFAULT '[]',
+  buyTotal double precision NOT NULL,
+  sellTotal double precision NOT NULL
+);
+
+CREATE INDEX appraisals_userid ON eveindy.appraisals (userid, created);
@@ -0,0 +1,62 @@
+-- Copyright © 2014–6 Brad Ackerman.
+--
+-- Licensed under the Apache License, Version 2.0 (the "License");
+-- you may not use this file except in compliance with the License.
+-- You may obtain a copy of the License at
+--
+-- http://www.apache.org/licenses/LICENSE-2.0
+--
+-- Unless required by applicable law or agreed to in writing, software
+-- distributed under the License is distributed on an "AS IS" BASIS,
+-- WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+-- See the License for the specific language governing permissions and
+-- limitations under the License.
+
+-- watchlists: named lists of items whose prices a user wants to be alerted
+-- about
+CREATE TABLE eveindy.watchlists (
+  id SERIAL PRIMARY KEY,
+  userid integer NOT NULL REFERENCES eveindy.users(id) ON DELETE CASCADE DEFERRABLE,
+  name text NOT NULL,
+  -- webhook is the URL to which alerts are posted; empty if alerts are only
+  -- to be recorded.
+  webhook text NOT NULL DEFAULT '',
+
+  UNIQUE (userid, name)
+);
+
+-- watchedItems: the items in each watchlist, with the prices that trigger an
+-- alert
+CREATE TABLE eveindy.watchedItems (
+  id SERIAL PRIMARY KEY,
+  watchlistID integer NOT NULL
+    REFERENCES eveindy.watchlists(id) ON DELETE CASCADE DEFERRABLE,
+  typeID integer NOT NULL REFERENCES "invTypes" ("typeID") DEFERRABLE,
+  stationID integer NOT NULL,
+  -- side is whether the best buy or best sell price is watched.
+  side text NOT NULL CHECK (side IN ('buy', 'sell')),
+  -- An alert is triggered when the price goes below or above these; either
+  -- may be null.
+  below double precision,
+  above double precision,
+  -- alerting is true while the price is past a threshold, so that each
+  -- crossing only triggers one alert.
+  alerting boolean NOT NULL DEFAULT false
+);
+
+CREATE INDEX watchedItems_watchlistID ON eveindy.watchedItems (watchlistID);
+
+-- alerts: the record of each threshold crossing
+CREATE TABLE eveindy.alerts (
+  id BIGSERIAL PRIMARY KEY,
+  watchedItemID integer NOT NULL
+    REFERENCES eveindy.watchedItems(id) ON DELETE CASCADE DEFERRABLE,
+  triggered timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  price double precision NOT NULL,
+  threshold double precision NOT NULL,
+  -- direction is 'below' or 'above'.
+  direction text NOT NULL,
+  delivered boolean NOT NULL DEFAULT false
+);
+
+CREATE INDEX alerts_watchedItemID ON eveindy.alerts (watchedItemID, triggered);
@@ -0,0 +1,25 @@
+-- Copyright © 2014–6 Brad Ackerman.
+--
+-- Licensed under the Apache License, Version 2.0 (the "License");
+-- you may not use this file except in compliance with the License.
+-- You may obtain a copy of the License at
+--
+-- http://www.apache.org/licenses/LICENSE-2.0
+--
+-- Unless required by applicable law or agreed to in writing, software
+-- distributed under the License is distributed on an "AS IS" BASIS,
+-- WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+-- See the License for the specific language governing permissions and
+-- limitations under the License.
+
+-- tickers: users' own market tickers, in addition to those configured on the
+-- server
+CREATE TABLE eveindy.tickers (
+  userid integer NOT NULL REFERENCES eveindy.users(id) ON DELETE CASCADE DEFERRABLE,
+  name text NOT NULL,
+  stationID integer NOT NULL,
+  -- items is a JSON array of item names.
+  items json NOT NULL,
+
+  PRIMARY KEY (userid, name)
+);
@@ -0,0 +1,72 @@
+-- Copyright © 2014–6 Brad Ackerman.
+--
+-- Licensed under the Apache License, Version 2.0 (the "License");
+-- you may not use this file except in compliance with the License.
+-- You may obtain a copy of the License at
+--
+-- http://www.apache.org/licenses/LICENSE-2.0
+--
+-- Unless required by applicable law or agreed to in writing, software
+-- distributed under the License is distributed on an "AS IS" BASIS,
+-- WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+-- See the License for the specific language governing permissions and
+-- limitations under the License.
+
+-- buybackPrograms: a user's buyback rule sets, priced at a station
+CREATE TABLE eveindy.buybackPrograms (
+  id SERIAL PRIMARY KEY,
+  -- userid is the program's administrator, the only user who may change it.
+  -- Deleting the user deletes their programs in the same way as the
+  -- administrator would (see buybackPrograms_orphan_check), keeping quotes.
+  userid integer REFERENCES eveindy.users(id) ON DELETE SET NULL DEFERRABLE,
+  name text NOT NULL,
+  stationID integer NOT NULL,
+  -- refineYield is the base yield, in percent, of the facility where items
+  -- priced at their reprocessed value are refined; the refiner is assumed to
+  -- have every reprocessing skill at level V.
+  refineYield double precision NOT NULL
+    CHECK (refineYield >= 0 AND refineYield <= 100),
+  -- rules is a JSON array of the program's pricing rules.
+  rules json NOT NULL,
+  -- Deleted programs are kept so that their quotes can still be looked up.
+  deleted boolean NOT NULL DEFAULT false,
+  CHECK (deleted OR userid IS NOT NULL)
+);
+
+-- trigger on update: a program that loses its administrator is deleted
+CREATE OR REPLACE FUNCTION buybackPrograms_orphan_check() RETURNS TRIGGER AS $$
+BEGIN
+  IF NEW.userid IS NULL
+  THEN
+    NEW.deleted := true;
+  END IF;
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER buybackPrograms_orphan BEFORE UPDATE ON eveindy.buybackPrograms
+FOR EACH ROW EXECUTE PROCEDURE buybackPrograms_orphan_check();
+
+CREATE UNIQUE INDEX buybackPrograms_userid_name
+  ON eveindy.buybackPrograms (userid, name) WHERE NOT deleted;
+
+-- buybackQuotes: pastes priced under a buyback program, referenced by ID in
+-- the contracts that fulfil them
+CREATE TABLE eveindy.buybackQuotes (
+  id text NOT NULL PRIMARY KEY,
+  programID integer NOT NULL
+    REFERENCES eveindy.buybackPrograms(id) ON DELETE RESTRICT DEFERRABLE,
+  -- userid is null for quotes made by users who weren't logged in.
+  userid integer REFERENCES eveindy.users(id) ON DELETE SET NULL,
+  created timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  expires timestamp with time zone NOT NULL,
+  -- location is where the items were priced, in human-readable form.
+  location text NOT NULL,
+  -- lines and unmatched are the priced and unpriced lines of the paste, as
+  -- sent to the client.
+  lines json NOT NULL,
+  unmatched json NOT NULL,
+  total double precision NOT NULL
+);
+
+CREATE INDEX buybackQuotes_programID ON eveindy.buybackQuotes (programID, created);
@@ -0,0 +1,27 @@
+-- Copyright © 2014–6 Brad Ackerman.
+--
+-- Licensed under the Apache License, Version 2.0 (the "License");
+-- you may not use this file except in compliance with the License.
+-- You may obtain a copy of the License at
+--
+-- http://www.apache.org/licenses/LICENSE-2.0
+--
+-- Unless required by applicable law or agreed to in writing, software
+-- distributed under the License is distributed on an "AS IS" BASIS,
+-- WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+-- See the License for the specific language governing permissions and
+-- limitations under the License.
+
+-- marketGenerations: when the orders for each item in each region uploaded to
+-- the local market were generated, so that older uploads don't replace newer
+-- ones.
+CREATE TABLE eveindy.marketGenerations (
+  typeID integer NOT NULL REFERENCES "invTypes" ("typeID") DEFERRABLE,
+  regionID integer NOT NULL,
+  generatedAt timestamp with time zone NOT NULL,
+  -- uploader is the name of the key the orders were uploaded with.
+  uploader text NOT NULL,
+  uploadedAt timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+  PRIMARY KEY (typeID, regionID)
+);